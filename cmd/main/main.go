@@ -1,20 +1,28 @@
 package main
 
 import (
+	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/database"
 	migrations "acourse-course-service/pkg/database/migration"
 	"acourse-course-service/pkg/http/controllers"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
 	dbrepo "acourse-course-service/pkg/repositories/database"
-	s3repo "acourse-course-service/pkg/repositories/storage"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	uploadrepo "acourse-course-service/pkg/repositories/upload"
 	"acourse-course-service/pkg/services"
 	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
-import "github.com/zhulik/go_mediainfo"
-
 func main() {
 
 	var ctx = context.Background()
@@ -43,32 +51,271 @@ func main() {
 	//Migrations
 	migration := migrations.ConstructMigration(&db)
 	migration.MigrateSettings()
+	migration.MigrateMaterialDurations()
+	migration.MigrateCourseRevisions()
 
 	//Setup MongoDB Repository
-	dbRepository := dbrepo.ConstructDBRepository(mongodb.GetConnection(), mongodb.GetCollection())
-
-	//Setup S3 Storage Repository
-	s3StorageRepository := s3repo.ConstructS3Repository(
-		os.Getenv("AWS_ACCESS_KEY_ID"),
-		os.Getenv("AWS_SECRET_ACCESS_KEY"),
-		os.Getenv("AWS_BUCKET_NAME"),
-		os.Getenv("AWS_BUCKET_REGION"),
-		[]string{"video/mp4", "video/x-matroska", "image/jpeg"},
-		int64(100*1024*1024), //MAX Filesize 100mb
-		3,
-	)
+	dbRepository := dbrepo.ConstructDBRepository(mongodb.GetConnection(), mongodb.GetCollection(), mongodb.GetRevisionCollection())
+
+	//Setup Audit Repository, audit events are only appended
+	auditRepository := dbrepo.ConstructAuditRepository(mongodb.GetAuditCollection())
+
+	//Setup Storage Repository, STORAGE_DRIVER selects between "s3" (default) and "local"
+	var storageRepository contracts.StorageRepository
+	var storageBaseUrl string
+
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "local":
+		localStorageUrl, err := url.Parse(os.Getenv("LOCAL_STORAGE_URL"))
+		if err != nil {
+			panic(err)
+		}
+
+		localStorageRepository := storagerepo.ConstructLocalStorageRepository(
+			os.Getenv("LOCAL_STORAGE_ROOT"),
+			localStorageUrl.String(),
+			os.Getenv("LOCAL_STORAGE_SECRET"),
+		)
+		storageRepository = localStorageRepository
+		storageBaseUrl = localStorageUrl.String()
+
+		//Serve Local Storage Files, downloads require signature when urls are signed with hmac
+		controllers.SetupLocalStorageHandler(engine, localStorageUrl.Path, os.Getenv("LOCAL_STORAGE_ROOT"), localStorageRepository, os.Getenv("URL_SIGNER") == "hmac")
+	default:
+		storageRepository = storagerepo.ConstructS3Repository(
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			os.Getenv("AWS_BUCKET_NAME"),
+			os.Getenv("AWS_BUCKET_REGION"),
+			int64(100*1024*1024), //MAX Filesize 100mb
+			int64(8*1024*1024),   //Part size 8mb
+			4,                    //Parts uploaded in parallel
+			3,
+		)
+		storageBaseUrl = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", os.Getenv("AWS_BUCKET_NAME"), os.Getenv("AWS_BUCKET_REGION"))
+	}
+
+	//Setup Url Signer, URL_SIGNER selects between "cdn", "cloudfront", "hmac" or raw storage urls (default)
+	var urlSigner contracts.UrlSigner
+
+	signedUrlTTL := time.Hour
+	if ttl := os.Getenv("SIGNED_URL_TTL"); ttl != "" {
+		signedUrlTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	switch os.Getenv("URL_SIGNER") {
+	case "cdn":
+		urlSigner = services.ConstructCdnUrlSigner(os.Getenv("CDN_BASE_URL"))
+	case "cloudfront":
+		urlSigner, err = services.ConstructCloudFrontUrlSigner(
+			os.Getenv("CDN_BASE_URL"),
+			os.Getenv("CLOUDFRONT_KEY_PAIR_ID"),
+			os.Getenv("CLOUDFRONT_PRIVATE_KEY_PATH"),
+			signedUrlTTL,
+		)
+		if err != nil {
+			panic(err)
+		}
+	case "hmac":
+		urlSigner, err = services.ConstructHmacUrlSigner(storageBaseUrl, os.Getenv("LOCAL_STORAGE_SECRET"), signedUrlTTL)
+		if err != nil {
+			panic(err)
+		}
+	default:
+		urlSigner = services.ConstructCdnUrlSigner(storageBaseUrl)
+	}
 
 	//Setup Storage CourseService
-	storageService := services.ConstructStorageService(&s3StorageRepository)
+	storageService := services.ConstructStorageService(&storageRepository)
+
+	//Setup MediaInfo Service, MEDIA_PROBE selects between "mediainfo" (default, libmediainfo) and "native" (pure go)
+	var mediaInfoService contracts.MediaInfoService
+
+	switch os.Getenv("MEDIA_PROBE") {
+	case "native":
+		mediaInfoService = services.ConstructGoMediaProbeService()
+	default:
+		mediaInfoService, err = services.NewLibMediaInfoService()
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	//Setup Media Upload Policy, empty settings don't restrict uploads
+	mediaPolicy := models.MediaPolicy{
+		AllowedContainers:  envList("MEDIA_ALLOWED_CONTAINERS"),
+		AllowedVideoCodecs: envList("MEDIA_ALLOWED_VIDEO_CODECS"),
+		AllowedAudioCodecs: envList("MEDIA_ALLOWED_AUDIO_CODECS"),
+	}
+
+	if minWidth := os.Getenv("MEDIA_MIN_WIDTH"); minWidth != "" {
+		mediaPolicy.MinWidth, err = strconv.Atoi(minWidth)
+		if err != nil {
+			panic(err)
+		}
+	}
 
-	//Setup MediaInfo Service
-	mediaInfoService := services.ConstructMediaInfoService(mediainfo.NewMediaInfo())
+	if minHeight := os.Getenv("MEDIA_MIN_HEIGHT"); minHeight != "" {
+		mediaPolicy.MinHeight, err = strconv.Atoi(minHeight)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	//Setup Resumable Upload Service, partial uploads are kept on disk until they're completed
+	uploadRepository := uploadrepo.ConstructDiskUploadRepository(os.Getenv("TUS_UPLOAD_DIR"))
+	uploadService := services.ConstructResumableUploadService(&uploadRepository, &storageService, &mediaInfoService, mediaPolicy, int64(100*1024*1024))
+
+	//Setup HLS Transcoding & Preview Service, disabled unless HLS_TRANSCODING is "true"
+	var transcodingService contracts.TranscodingService
+	transcodeWorkers := 1
+
+	if os.Getenv("HLS_TRANSCODING") == "true" {
+		ffmpegPath, ffprobePath := os.Getenv("FFMPEG_PATH"), os.Getenv("FFPROBE_PATH")
+		if ffmpegPath == "" {
+			ffmpegPath = "ffmpeg"
+		}
+		if ffprobePath == "" {
+			ffprobePath = "ffprobe"
+		}
+
+		if workers := os.Getenv("TRANSCODE_WORKERS"); workers != "" {
+			transcodeWorkers, err = strconv.Atoi(workers)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		transcoder := services.ConstructFfmpegTranscoder(ffmpegPath, ffprobePath, models.DefaultRenditions, 6)
+		transcodingService = services.ConstructTranscodingService(&dbRepository, &storageService, &transcoder, os.Getenv("TRANSCODE_DIR"), 100)
+	}
+
+	//Setup Access Policy, rules of POLICY_FILE decide which roles & scopes may perform which actions
+	policyFile := os.Getenv("POLICY_FILE")
+	if policyFile == "" {
+		policyFile = "policy.yaml"
+	}
+
+	accessPolicy, err := services.ConstructYamlAccessPolicy(policyFile)
+	if err != nil {
+		panic(err)
+	}
 
 	//Setup Course Services
-	courseService := services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService)
+	courseService := services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService, &uploadService, &urlSigner, &transcodingService, mediaPolicy, &accessPolicy, &auditRepository)
+
+	//Orphaned objects younger than REAPER_GRACE_PERIOD are kept, uploads may not be attached yet
+	reaperGracePeriod := 24 * time.Hour
+	if grace := os.Getenv("REAPER_GRACE_PERIOD"); grace != "" {
+		reaperGracePeriod, err = time.ParseDuration(grace)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	//Run Orphan Reaper Admin Command Instead of Http Server
+	if len(os.Args) > 1 && os.Args[1] == "reap-orphans" {
+		runReaperCommand(ctx, dbRepository, uploadRepository, storageService, reaperGracePeriod, os.Args[2:])
+		return
+	}
+
+	//Setup Orphan Reaper Job, disabled when REAPER_INTERVAL is empty
+	if interval := os.Getenv("REAPER_INTERVAL"); interval != "" {
+		reaperInterval, err := time.ParseDuration(interval)
+		if err != nil {
+			panic(err)
+		}
+
+		reaperService := services.ConstructOrphanReaperService(&dbRepository, &uploadRepository, &storageService, reaperGracePeriod)
+		reaperService.Schedule(ctx, reaperInterval, os.Getenv("REAPER_DRY_RUN") != "false")
+	}
+
+	//Setup Release Scheduler, scheduled publish & unpublish are applied within RELEASE_SCHEDULER_INTERVAL
+	releaseInterval := time.Minute
+	if interval := os.Getenv("RELEASE_SCHEDULER_INTERVAL"); interval != "" {
+		releaseInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	releaseScheduler := services.ConstructReleaseSchedulerService(&dbRepository, &auditRepository)
+	releaseScheduler.Schedule(ctx, releaseInterval)
+
+	//Setup Trash Purge Job, trashed items older than TRASH_RETENTION are removed with their files
+	if interval := os.Getenv("TRASH_PURGE_INTERVAL"); interval != "" {
+		purgeInterval, err := time.ParseDuration(interval)
+		if err != nil {
+			panic(err)
+		}
+
+		trashRetention := 30 * 24 * time.Hour
+		if retention := os.Getenv("TRASH_RETENTION"); retention != "" {
+			trashRetention, err = time.ParseDuration(retention)
+			if err != nil {
+				panic(err)
+			}
+		}
+
+		purgeService := services.ConstructTrashPurgeService(&dbRepository, &storageService, &auditRepository, trashRetention)
+		purgeService.Schedule(ctx, purgeInterval)
+	}
+
+	//Start Transcoding Workers & Resume Interrupted Jobs
+	if transcodingService != nil {
+		transcodingService.Start(ctx, transcodeWorkers)
+		err = transcodingService.Recover(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	//Setup Authentication, bearer JWTs are verified against JWT_JWKS_FILE or JWT_JWKS_URL.
+	//X-User-* headers are trusted only when AUTH_TRUSTED_GATEWAY is "true", the gateway in front has to set them itself
+	var authenticators []middleware.Authenticator
+
+	if jwksFile, jwksUrl := os.Getenv("JWT_JWKS_FILE"), os.Getenv("JWT_JWKS_URL"); jwksFile != "" || jwksUrl != "" {
+		var keySet contracts.VerificationKeySet
+
+		if jwksFile != "" {
+			keySet, err = services.ConstructFileJwksKeySet(jwksFile)
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			jwksCacheTTL := time.Hour
+			if ttl := os.Getenv("JWT_JWKS_CACHE_TTL"); ttl != "" {
+				jwksCacheTTL, err = time.ParseDuration(ttl)
+				if err != nil {
+					panic(err)
+				}
+			}
+			keySet = services.ConstructUrlJwksKeySet(jwksUrl, jwksCacheTTL)
+		}
+
+		jwtAuthenticator, err := services.ConstructJwtAuthenticator(&keySet, os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE"))
+		if err != nil {
+			panic(err)
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	if os.Getenv("AUTH_TRUSTED_GATEWAY") == "true" {
+		authenticators = append(authenticators, middleware.TrustedGatewayAuthenticator{})
+	}
+
+	if len(authenticators) == 0 {
+		panic("No authentication is configured, set JWT_JWKS_FILE, JWT_JWKS_URL or AUTH_TRUSTED_GATEWAY")
+	}
 
 	//Setup Course Devlivery/Http Controller
-	controllers.SetupCourseHandler(ctx, engine, courseService)
+	controllers.SetupCourseHandler(ctx, engine, courseService, authenticators, accessPolicy)
+
+	//Setup Resumable Upload Http Controller
+	controllers.SetupResumableUploadHandler(ctx, engine, uploadService, authenticators)
 
 	//Running App With Desired Port
 	if port := os.Getenv("APP_PORT"); port == "" {
@@ -84,3 +331,17 @@ func main() {
 	}
 
 }
+
+//Comma separated env value, empty when it's not set
+func envList(key string) []string {
+
+	var values []string
+
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/services"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+)
+
+//Admin command, usage: main reap-orphans [-dry-run=false] [-grace=24h]
+func runReaperCommand(ctx context.Context, dbRepository contracts.CourseDatabaseRepository, uploadRepository contracts.ResumableUploadRepository, storageService contracts.StorageService, gracePeriod time.Duration, args []string) {
+
+	command := flag.NewFlagSet("reap-orphans", flag.ExitOnError)
+	dryRun := command.Bool("dry-run", true, "only report orphaned objects without deleting them")
+	grace := command.Duration("grace", gracePeriod, "objects modified within this period are never reaped")
+
+	err := command.Parse(args)
+	if err != nil {
+		panic(err)
+	}
+
+	reaperService := services.ConstructOrphanReaperService(&dbRepository, &uploadRepository, &storageService, *grace)
+
+	report, err := reaperService.Reap(ctx, *dryRun)
+	if err != nil {
+		panic(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	err = encoder.Encode(report)
+	if err != nil {
+		panic(err)
+	}
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,287 @@
+package captions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Caption files above this size are rejected before parsing
+const MaxFileSize = 2 * 1024 * 1024
+
+type Cue struct {
+	ID       string
+	Start    time.Duration
+	End      time.Duration
+	Settings string
+	Text     string
+}
+
+//Parse SRT or WebVTT captions, the format is detected from the WEBVTT signature
+func Parse(data []byte) ([]Cue, error) {
+
+	text := string(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var cues []Cue
+	var err error
+
+	if strings.HasPrefix(text, "WEBVTT") {
+		cues, err = parseVtt(text)
+	} else {
+		cues, err = parseSrt(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cues) == 0 {
+		return nil, errors.New("Caption file doesn't contain any cue")
+	}
+
+	//Cues are written ordered by their start time
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+
+	return cues, nil
+}
+
+//Blocks are separated by blank lines
+func splitBlocks(text string) [][]string {
+
+	var blocks [][]string
+	var block []string
+
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			if len(block) > 0 {
+				blocks = append(blocks, block)
+				block = nil
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+
+	if len(block) > 0 {
+		blocks = append(blocks, block)
+	}
+
+	return blocks
+}
+
+func parseVtt(text string) ([]Cue, error) {
+
+	var cues []Cue
+
+	blocks := splitBlocks(text)
+
+	//First block is the WEBVTT header
+	for i, block := range blocks[1:] {
+
+		//Comments, styles & regions are not cues
+		if strings.HasPrefix(block[0], "NOTE") || strings.HasPrefix(block[0], "STYLE") || strings.HasPrefix(block[0], "REGION") {
+			continue
+		}
+
+		var id string
+		if !strings.Contains(block[0], "-->") {
+			id = block[0]
+			block = block[1:]
+		}
+
+		if len(block) == 0 {
+			return nil, errors.New(fmt.Sprintf("Cue %v has no timing", i+1))
+		}
+
+		cue, err := parseTiming(block[0], i+1)
+		if err != nil {
+			return nil, err
+		}
+
+		cue.ID = id
+		cue.Text = strings.Join(block[1:], "\n")
+		cues = append(cues, cue)
+	}
+
+	return cues, nil
+}
+
+func parseSrt(text string) ([]Cue, error) {
+
+	var cues []Cue
+
+	for i, block := range splitBlocks(text) {
+
+		//Numeric counter is optional in the wild
+		var id string
+		if !strings.Contains(block[0], "-->") {
+			id = strings.TrimSpace(block[0])
+			block = block[1:]
+		}
+
+		if len(block) == 0 {
+			return nil, errors.New(fmt.Sprintf("Cue %v has no timing", i+1))
+		}
+
+		cue, err := parseTiming(strings.ReplaceAll(block[0], ",", "."), i+1)
+		if err != nil {
+			return nil, err
+		}
+
+		//SRT coordinates (X1:... Y2:...) have no WebVTT equivalent
+		cue.Settings = ""
+		cue.ID = id
+		cue.Text = strings.Join(block[1:], "\n")
+		cues = append(cues, cue)
+	}
+
+	return cues, nil
+}
+
+//Timing line looks like "00:00:01.000 --> 00:00:04.000 align:start"
+func parseTiming(line string, index int) (Cue, error) {
+
+	parts := strings.SplitN(line, "-->", 2)
+	if len(parts) != 2 {
+		return Cue{}, errors.New(fmt.Sprintf("Cue %v has invalid timing %q", index, line))
+	}
+
+	start, err := parseTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Cue{}, errors.New(fmt.Sprintf("Cue %v has invalid start time, %v", index, err.Error()))
+	}
+
+	fields := strings.Fields(parts[1])
+	if len(fields) == 0 {
+		return Cue{}, errors.New(fmt.Sprintf("Cue %v has no end time", index))
+	}
+
+	end, err := parseTimestamp(fields[0])
+	if err != nil {
+		return Cue{}, errors.New(fmt.Sprintf("Cue %v has invalid end time, %v", index, err.Error()))
+	}
+
+	return Cue{Start: start, End: end, Settings: strings.Join(fields[1:], " ")}, nil
+}
+
+//Timestamp is [hh:]mm:ss.ttt
+func parseTimestamp(value string) (time.Duration, error) {
+
+	units := strings.Split(value, ":")
+	if len(units) < 2 || len(units) > 3 {
+		return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+	}
+
+	secondsPart := strings.SplitN(units[len(units)-1], ".", 2)
+	if len(secondsPart) != 2 || len(secondsPart[1]) != 3 {
+		return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+	}
+
+	var hours, minutes, seconds, milliseconds int
+	var err error
+
+	if len(units) == 3 {
+		hours, err = strconv.Atoi(units[0])
+		if err != nil || hours < 0 {
+			return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+		}
+	}
+
+	minutes, err = strconv.Atoi(units[len(units)-2])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+	}
+
+	seconds, err = strconv.Atoi(secondsPart[0])
+	if err != nil || seconds < 0 || seconds > 59 {
+		return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+	}
+
+	milliseconds, err = strconv.Atoi(secondsPart[1])
+	if err != nil || milliseconds < 0 {
+		return 0, errors.New(fmt.Sprintf("%q is not a timestamp", value))
+	}
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(milliseconds)*time.Millisecond, nil
+}
+
+//Validate cue timing, cues may not end after the media when its duration is known
+func Validate(cues []Cue, duration time.Duration, tolerance time.Duration) error {
+
+	for i, cue := range cues {
+
+		if cue.End <= cue.Start {
+			return errors.New(fmt.Sprintf("Cue %v ends before it starts (%v --> %v)", i+1, formatTimestamp(cue.Start), formatTimestamp(cue.End)))
+		}
+
+		if duration > 0 && cue.End > duration+tolerance {
+			return errors.New(fmt.Sprintf("Cue %v ends at %v, after the material ends at %v", i+1, formatTimestamp(cue.End), formatTimestamp(duration)))
+		}
+	}
+
+	return nil
+}
+
+//Render cues as WebVTT, the normalized format kept in storage
+func RenderVtt(cues []Cue) []byte {
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("WEBVTT\n")
+
+	for _, cue := range cues {
+
+		buffer.WriteString("\n")
+
+		if cue.ID != "" {
+			buffer.WriteString(cue.ID + "\n")
+		}
+
+		buffer.WriteString(formatTimestamp(cue.Start) + " --> " + formatTimestamp(cue.End))
+		if cue.Settings != "" {
+			buffer.WriteString(" " + cue.Settings)
+		}
+		buffer.WriteString("\n")
+
+		if cue.Text != "" {
+			buffer.WriteString(cue.Text + "\n")
+		}
+	}
+
+	return buffer.Bytes()
+}
+
+func formatTimestamp(duration time.Duration) string {
+
+	milliseconds := duration.Milliseconds()
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		milliseconds/3600000,
+		milliseconds/60000%60,
+		milliseconds/1000%60,
+		milliseconds%1000,
+	)
+}
+
+var languagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+//Caption language is a BCP 47 tag such as "en" or "pt-BR", kept lowercase so each language has one track
+func NormalizeLanguage(language string) (string, error) {
+
+	if !languagePattern.MatchString(language) {
+		return "", errors.New(fmt.Sprintf("%q is not a valid language tag", language))
+	}
+
+	return strings.ToLower(language), nil
+}
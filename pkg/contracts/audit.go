@@ -0,0 +1,12 @@
+package contracts
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+)
+
+//AuditRepository appends audit events, stored events are never changed nor removed
+type AuditRepository interface {
+	Record(ctx context.Context, event models.AuditEvent) (err error)
+	Find(ctx context.Context, filter models.AuditFilter, limit int64, skip int64) (res []models.AuditEvent, err error)
+}
@@ -0,0 +1,14 @@
+package contracts
+
+import "acourse-course-service/pkg/http/middleware"
+
+//VerificationKeySet resolves the key which verifies a token signed with the given key id & algorithm
+type VerificationKeySet interface {
+	VerificationKey(kid string, alg string) (interface{}, error)
+}
+
+//AccessPolicy decides which actions a requester may perform, on any course or only on courses they have a role on
+type AccessPolicy interface {
+	middleware.ActionPolicy
+	Allows(authorization middleware.Authorization, action string, courseRole string) bool
+}
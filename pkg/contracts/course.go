@@ -7,29 +7,89 @@ import (
 	"acourse-course-service/pkg/models"
 	"context"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 type CourseService interface {
 	CourseResourcePolicy
 	Fetch(ctx context.Context, excludedFields []string, pagination models.Pagination) ([]models.Course, error)
-	FetchById(ctx context.Context, id string, excludeFields []string) (models.Course, error)
+	FetchById(ctx context.Context, id string, excludeFields []string) (*models.Course, error)
 	Create(ctx context.Context, data requests.CreateCourseRequest) (interface{}, error)
 	Update(ctx context.Context, data requests.UpdateCourseRequest, course_id string) (*response.HttpResponse, error)
 	DeleteMaterials(ctx context.Context, course_id string, data requests.DeleteMaterialsRequest) (*response.HttpResponse, error)
 	DeleteCourse(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	CreateMaterialUploads(ctx context.Context, course_id string, data requests.CreateMaterialUploadsRequest) (*response.HttpResponse, error)
+	FinalizeMaterialUploads(ctx context.Context, course_id string, data requests.FinalizeMaterialUploadsRequest) (*response.HttpResponse, error)
+	ListCaptions(ctx context.Context, course_id string, material_id string) (*response.HttpResponse, error)
+	PutCaption(ctx context.Context, course_id string, material_id string, language string, data requests.PutCaptionRequest) (*response.HttpResponse, error)
+	DeleteCaption(ctx context.Context, course_id string, material_id string, language string) (*response.HttpResponse, error)
+	FetchMaterial(ctx context.Context, course_id string, material_id string) (*response.HttpResponse, error)
+	CreateMaterial(ctx context.Context, course_id string, data requests.CreateCourseMaterialRequest) (*response.HttpResponse, error)
+	UpdateMaterial(ctx context.Context, course_id string, material_id string, data requests.UpdateMaterialRequest) (*response.HttpResponse, error)
+	ReplaceMaterialFile(ctx context.Context, course_id string, material_id string, data requests.ReplaceMaterialFileRequest) (*response.HttpResponse, error)
+	ReorderMaterials(ctx context.Context, course_id string, data requests.ReorderMaterialsRequest) (*response.HttpResponse, error)
+	FetchTrash(ctx context.Context) (*response.HttpResponse, error)
+	RestoreCourse(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	RestoreMaterial(ctx context.Context, course_id string, material_id string) (*response.HttpResponse, error)
+	ScheduleRelease(ctx context.Context, course_id string, data requests.ReleaseScheduleRequest) (*response.HttpResponse, error)
+	CancelReleaseSchedule(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	ListSections(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	CreateSection(ctx context.Context, course_id string, data requests.CreateSectionRequest) (*response.HttpResponse, error)
+	UpdateSection(ctx context.Context, course_id string, section_id string, data requests.UpdateSectionRequest) (*response.HttpResponse, error)
+	DeleteSection(ctx context.Context, course_id string, section_id string) (*response.HttpResponse, error)
+	AddSectionMaterials(ctx context.Context, course_id string, section_id string, data requests.SectionMaterialsRequest) (*response.HttpResponse, error)
+	RemoveSectionMaterials(ctx context.Context, course_id string, section_id string, data requests.SectionMaterialsRequest) (*response.HttpResponse, error)
+	PublishCourse(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	ListRevisions(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	FetchRevision(ctx context.Context, course_id string, revision string) (*response.HttpResponse, error)
+	RollbackRevision(ctx context.Context, course_id string, revision string) (*response.HttpResponse, error)
+	ListCollaborators(ctx context.Context, course_id string) (*response.HttpResponse, error)
+	InviteCollaborator(ctx context.Context, course_id string, data requests.InviteCollaboratorRequest) (*response.HttpResponse, error)
+	UpdateCollaborator(ctx context.Context, course_id string, user_id string, data requests.UpdateCollaboratorRequest) (*response.HttpResponse, error)
+	RemoveCollaborator(ctx context.Context, course_id string, user_id string) (*response.HttpResponse, error)
+	TransferOwnership(ctx context.Context, course_id string, data requests.TransferOwnershipRequest) (*response.HttpResponse, error)
+	FetchAuditLog(ctx context.Context, data requests.AuditLogRequest) (*response.HttpResponse, error)
 }
 
 type CourseDatabaseRepository interface {
-	Fetch(ctx context.Context, excludeFields []string, limit int64, skip int64) (res []models.Course, err error)
+	Fetch(ctx context.Context, excludeFields []string, limit int64, skip int64, visibleTo int64) (res []models.Course, err error)
 	FetchById(ctx context.Context, id string, excludeFields []string) (res models.Course, err error)
 	FetchByUserId(ctx context.Context, user_id int64, excludeFields []string) (res *models.Course, err error)
+	FetchAllWithDeleted(ctx context.Context, excludeFields []string) (res []models.Course, err error)
 	Create(ctx context.Context, data *models.Course) (course_id primitive.ObjectID, err error)
-	Update(ctx context.Context, data models.Course, course_id string) (res bool, err error)
-	DeleteCourse(ctx context.Context, course_id string) (res bool, err error)
-	DeleteMaterials(ctx context.Context, course_id string, material_id []string) (res interface{}, err error)
+	Update(ctx context.Context, data *models.Course, course_id string) (res bool, err error)
+	UpdateMaterialFields(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}) (res bool, err error)
+	PushMaterial(ctx context.Context, course_id string, material models.Material) (res bool, err error)
+	PushMaterialAt(ctx context.Context, course_id string, version int, material models.Material) (res bool, err error)
+	UpdateMaterial(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}, durationDelta time.Duration) (res bool, err error)
+	CreateSection(ctx context.Context, course_id string, section models.Section) (res bool, err error)
+	UpdateSectionFields(ctx context.Context, course_id string, section_id string, fields map[string]interface{}) (res bool, err error)
+	DeleteSection(ctx context.Context, course_id string, version int, section_id string, placements []models.MaterialPlacement) (res bool, err error)
+	PlaceMaterials(ctx context.Context, course_id string, version int, placements []models.MaterialPlacement) (res bool, err error)
+	TrashCourse(ctx context.Context, course_id string, deletedAt time.Time) (res bool, err error)
+	RestoreCourse(ctx context.Context, course_id string) (res bool, err error)
+	FetchByIdWithDeleted(ctx context.Context, id string) (res *models.Course, err error)
+	FetchTrash(ctx context.Context, user_id int64) (res []models.Course, err error)
+	TrashMaterials(ctx context.Context, course_id string, materials []models.Material) (res bool, err error)
+	RestoreMaterial(ctx context.Context, course_id string, material models.Material) (res bool, err error)
+	PurgeCourse(ctx context.Context, course_id string, cutoff time.Time) (res bool, err error)
+	PurgeTrashedMaterial(ctx context.Context, course_id string, material_id primitive.ObjectID, cutoff time.Time) (res bool, err error)
+	SetReleaseSchedule(ctx context.Context, course_id string, publishAt *time.Time, unpublishAt *time.Time) (res bool, err error)
+	FetchDueReleases(ctx context.Context, now time.Time) (res []models.Course, err error)
+	ApplyScheduledRelease(ctx context.Context, course_id string, event models.ReleaseEvent) (res bool, err error)
+	SetCollaborators(ctx context.Context, course_id string, version int, user_id int64, collaborators []models.Collaborator) (res bool, err error)
+	CreateRevision(ctx context.Context, revision models.CourseRevision) (err error)
+	DeleteRevision(ctx context.Context, revision_id primitive.ObjectID) (err error)
+	PublishRevision(ctx context.Context, course_id string, previous int, revision int) (res bool, err error)
+	SetPublishedRevision(ctx context.Context, course_id string, revision int) (res bool, err error)
+	FetchRevisions(ctx context.Context, course_id string, withSnapshot bool) (res []models.CourseRevision, err error)
+	FetchRevision(ctx context.Context, course_id string, revision int) (res models.CourseRevision, err error)
+	FetchPublishedRevisions(ctx context.Context, revisions map[primitive.ObjectID]int) (res map[primitive.ObjectID]models.CourseRevision, err error)
+	FetchAllRevisions(ctx context.Context) (res []models.CourseRevision, err error)
+	DeleteRevisions(ctx context.Context, course_id string) (err error)
 	GenerateModelID() primitive.ObjectID
 }
 
 type CourseResourcePolicy interface {
-	AuthorizeResourceOwner(model *models.Course, authorization middleware.Authorization) (bool, error)
+	AuthorizeResourceOwner(model *models.Course, authorization middleware.Authorization, action string) (bool, error)
 }
@@ -9,5 +9,7 @@ type DBContract interface {
 
 type MongoDBContract interface {
 	GetCollection() *mongo.Collection
+	GetRevisionCollection() *mongo.Collection
+	GetAuditCollection() *mongo.Collection
 	DBContract
 }
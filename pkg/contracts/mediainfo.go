@@ -1,7 +1,20 @@
 package contracts
 
-import "mime/multipart"
+import (
+	"acourse-course-service/pkg/models"
+	"io"
+	"mime/multipart"
+)
 
 type MediaInfoService interface {
-	GetVideoDuration(file *multipart.FileHeader) (string, error)
+	GetVideoInfo(file *multipart.FileHeader) (models.MediaInfo, error)
+	GetReaderInfo(reader io.Reader) (models.MediaInfo, error)
+	NewProbe() (MediaProbe, error)
+}
+
+//MediaProbe receives file bytes while they are streamed somewhere else, media info is read once writing is done
+type MediaProbe interface {
+	io.Writer
+	Info() (models.MediaInfo, error)
+	Close() error
 }
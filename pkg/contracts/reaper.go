@@ -0,0 +1,12 @@
+package contracts
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+	"time"
+)
+
+type OrphanReaperService interface {
+	Reap(ctx context.Context, dryRun bool) (models.ReaperReport, error)
+	Schedule(ctx context.Context, interval time.Duration, dryRun bool)
+}
@@ -0,0 +1,11 @@
+package contracts
+
+import (
+	"context"
+	"time"
+)
+
+type ReleaseSchedulerService interface {
+	ReleaseDue(ctx context.Context) (int, error)
+	Schedule(ctx context.Context, interval time.Duration)
+}
@@ -0,0 +1,7 @@
+package contracts
+
+type UrlSigner interface {
+	SignUrl(objectKey string) (string, error)
+	//Url of objectKey which also grants every object under prefix, so relative references (HLS renditions & segments, thumbnail sprites) resolved from it are allowed
+	SignPrefixUrl(prefix string, objectKey string) (string, error)
+}
@@ -2,19 +2,39 @@ package contracts
 
 import (
 	"acourse-course-service/pkg/http/response"
-	"github.com/aws/aws-sdk-go/service/s3"
+	"io"
 	"mime/multipart"
 )
 
 type StorageRepository interface {
 	UploadFiles(files []*multipart.FileHeader, prefix string) ([]response.S3Response, error)
 	UploadFile(file *multipart.FileHeader, prefix string) (response.S3Response, error)
+	UploadReader(reader io.Reader, filename string, prefix string) (response.S3Response, error)
+	PutObject(reader io.Reader, objectKey string, contentType string) (response.S3Response, error)
+	CreatePresignedUpload(filename string, prefix string, contentType string, size int64) (response.PresignedUpload, error)
+	CompletePresignedUpload(objectKey string, uploadId string, parts []response.UploadedPart) (response.S3Response, error)
+	GetObject(objectKey string) (io.ReadCloser, error)
+	ListObjects(prefix string) ([]response.StorageObject, error)
 	DeleteObject(objectKey *string) error
-	GetClient() (*s3.S3, error)
+}
+
+type LocalStorageRepository interface {
+	StorageRepository
+	VerifyUploadPart(objectKey string, uploadId string, partNumber int64, expires int64, signature string) error
+	VerifyDownload(objectKey string, expires int64, signature string) error
+	VerifyPrefixDownload(objectKey string, expires int64, signature string) error
+	WriteUploadPart(uploadId string, partNumber int64, body io.Reader) (string, error)
 }
 
 type StorageService interface {
 	UploadFiles(files []*multipart.FileHeader, prefix string) ([]response.S3Response, error)
 	UploadFile(file *multipart.FileHeader, prefix string) (response.S3Response, error)
+	UploadReader(reader io.Reader, filename string, prefix string) (response.S3Response, error)
+	PutObject(reader io.Reader, objectKey string, contentType string) (response.S3Response, error)
+	CreatePresignedUpload(filename string, prefix string, contentType string, size int64) (response.PresignedUpload, error)
+	CompletePresignedUpload(objectKey string, uploadId string, parts []response.UploadedPart) (response.S3Response, error)
+	GetObject(objectKey string) (io.ReadCloser, error)
+	ListObjects(prefix string) ([]response.StorageObject, error)
 	Delete(objectKey string) error
+	DeletePrefix(prefix string) error
 }
@@ -0,0 +1,17 @@
+package contracts
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+)
+
+type Transcoder interface {
+	TranscodeHls(ctx context.Context, input string, outputDir string) error
+	ExtractPreviews(ctx context.Context, input string, outputDir string) error
+}
+
+type TranscodingService interface {
+	Enqueue(job models.TranscodeJob)
+	Start(ctx context.Context, workers int)
+	Recover(ctx context.Context) error
+}
@@ -0,0 +1,12 @@
+package contracts
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+	"time"
+)
+
+type TrashPurgeService interface {
+	Purge(ctx context.Context) (models.PurgeReport, error)
+	Schedule(ctx context.Context, interval time.Duration)
+}
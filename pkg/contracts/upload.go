@@ -0,0 +1,30 @@
+package contracts
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+	"io"
+)
+
+type ResumableUploadService interface {
+	MaxSize() int64
+	Create(ctx context.Context, length int64, metadata map[string]string) (models.Upload, error)
+	Find(ctx context.Context, upload_id string) (models.Upload, error)
+	WriteChunk(ctx context.Context, upload_id string, offset int64, reader io.Reader) (models.Upload, error)
+	Terminate(ctx context.Context, upload_id string) error
+	Resolve(ctx context.Context, upload_id string) (models.Upload, error)
+	Unclaim(upload_id string) error
+	Release(upload_id string) error
+}
+
+type ResumableUploadRepository interface {
+	Create(upload models.Upload) error
+	Find(upload_id string) (models.Upload, error)
+	List() ([]models.Upload, error)
+	Save(upload models.Upload) error
+	Lock(upload_id string) (func(), error)
+	WriteChunk(upload_id string, reader io.Reader) (int64, error)
+	Open(upload_id string) (io.ReadCloser, error)
+	RemoveData(upload_id string) error
+	Delete(upload_id string) error
+}
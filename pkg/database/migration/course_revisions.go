@@ -0,0 +1,59 @@
+package migration
+
+import (
+	"acourse-course-service/pkg/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+//Courses stored before revisions existed serve their current content as revision 1.
+//Courses which already have a revision are skipped, so running it again is harmless
+func (m Migration) MigrateCourseRevisions() {
+
+	ctx := context.Background()
+	collection := m.DB.GetCollection()
+	revisions := m.DB.GetRevisionCollection()
+
+	cursor, err := collection.Find(ctx, bson.M{"latest_revision": bson.M{"$in": bson.A{0, nil}}})
+	if err != nil {
+		panic(err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+
+	for cursor.Next(ctx) {
+
+		var course models.Course
+		err := cursor.Decode(&course)
+		if err != nil {
+			panic(err)
+		}
+
+		//1. Store revision 1, it may already exist when an earlier run stopped halfway
+		timeNow := time.Now()
+		_, err = revisions.InsertOne(ctx, models.CourseRevision{
+			ID:        primitive.NewObjectID(),
+			CourseID:  course.ID,
+			Revision:  1,
+			Snapshot:  course.Snapshot(),
+			CreatedAt: &timeNow,
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			panic(err)
+		}
+
+		//2. Serve it
+		_, err = collection.UpdateByID(ctx, course.ID, bson.M{"$set": bson.M{"published_revision": 1, "latest_revision": 1}})
+		if err != nil {
+			panic(err)
+		}
+		migrated++
+	}
+
+	log.Printf("Migrates Course Revisions Success, %v courses published", migrated)
+}
@@ -27,4 +27,24 @@ func (m Migration) CreateIndexes() {
 	if err != nil {
 		panic(err)
 	}
+
+	//Revision numbers are unique per course, concurrent publishes can't store the same revision
+	_, err = m.DB.GetRevisionCollection().Indexes().CreateOne(context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "course_id", Value: 1}, {Key: "revision", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+	if err != nil {
+		panic(err)
+	}
+
+	//Audit log is queried by course or actor within a time range
+	_, err = m.DB.GetAuditCollection().Indexes().CreateMany(context.Background(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "course_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "created_at", Value: -1}}},
+	})
+	if err != nil {
+		panic(err)
+	}
 }
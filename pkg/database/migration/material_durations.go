@@ -0,0 +1,72 @@
+package migration
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"strconv"
+	"time"
+)
+
+//Durations below one millisecond can only be whole seconds stored by older versions
+const legacyDurationLimit = int64(time.Millisecond)
+
+type legacyCourse struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Materials []struct {
+		MaterialID primitive.ObjectID `bson:"material_id"`
+		Duration   int64              `bson:"duration"`
+	} `bson:"materials"`
+}
+
+//Older versions stored material durations as seconds inside time.Duration, convert them to nanoseconds.
+//Only values which can't be real nanosecond durations are touched, so running it again is harmless
+func (m Migration) MigrateMaterialDurations() {
+
+	ctx := context.Background()
+	collection := m.DB.GetCollection()
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"materials": bson.M{"$elemMatch": bson.M{"duration": bson.M{"$gt": 0, "$lt": legacyDurationLimit}}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+
+	for cursor.Next(ctx) {
+
+		var course legacyCourse
+		err := cursor.Decode(&course)
+		if err != nil {
+			panic(err)
+		}
+
+		//1. Convert every legacy material duration
+		set := bson.M{}
+		var totalDuration int64
+
+		for i, material := range course.Materials {
+			duration := material.Duration
+			if duration > 0 && duration < legacyDurationLimit {
+				duration *= int64(time.Second)
+				set["materials."+strconv.Itoa(i)+".duration"] = duration
+			}
+			totalDuration += duration
+		}
+
+		//2. Total duration is the sum of its materials
+		set["total_duration"] = totalDuration
+
+		_, err = collection.UpdateByID(ctx, course.ID, bson.M{"$set": set})
+		if err != nil {
+			panic(err)
+		}
+		migrated++
+	}
+
+	log.Printf("Migrates Material Durations Success, %v courses converted", migrated)
+}
@@ -62,6 +62,16 @@ func (db *Database) GetCollection() *mongo.Collection {
 	return db.connection.Collection(db.DbCollection)
 }
 
+//Published revisions of the courses are kept next to the course collection
+func (db *Database) GetRevisionCollection() *mongo.Collection {
+	return db.connection.Collection(db.DbCollection + "_revisions")
+}
+
+//Audit events are appended to their own collection, next to the course collection
+func (db *Database) GetAuditCollection() *mongo.Collection {
+	return db.connection.Collection(db.DbCollection + "_audit")
+}
+
 func (db *Database) Dsn() string {
 	return fmt.Sprintf("mongodb://%s:%s@%s:%s/%s?authSource=admin", db.DbUsername, db.DBPassword, db.DbHost, db.DbPort, db.DbName)
 }
@@ -3,21 +3,67 @@ package controllers
 import (
 	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
 	"context"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupCourseHandler(ctx context.Context, router *gin.Engine, courseService contracts.CourseService) {
+//Context handed to services, carrying authorization & id of the request
+func requestContext(parent context.Context, c *gin.Context) context.Context {
+
+	authorization, _ := c.Get("authorization")
+
+	ctx := context.WithValue(parent, middleware.AuthorizationKey, authorization)
+	return context.WithValue(ctx, middleware.RequestIdKey, c.GetString("request_id"))
+}
+
+func SetupCourseHandler(ctx context.Context, router *gin.Engine, courseService contracts.CourseService, authenticators []middleware.Authenticator, accessPolicy contracts.AccessPolicy) {
 
 	handler := &CourseHanlder{CourseService: courseService, Context: ctx}
 
+	//Routes require an access rule for their action, conditions such as ownership are checked by the course service
+	can := func(action string) gin.HandlerFunc {
+		return middleware.CanPerformMiddleware(accessPolicy, action)
+	}
+
 	r := router.Group("/course/")
-	r.Use(middleware.AuthorizeRequestMiddleware)
-	r.GET("/list", handler.FetchAll)
-	r.GET("/show/:id", handler.Find)
-	r.POST("/create", middleware.CanCreateCourseMiddleware, handler.CreateCourse)
-	r.PUT("/update/:id", middleware.CanUpdateCourseMiddleware, handler.UpdateCourse)
-	r.DELETE("/delete-course/:id/material", middleware.CanDeleteCourseMiddleware, handler.DeleteMaterial)
-	r.DELETE("/delete-course/:id", middleware.CanDeleteCourseMiddleware, handler.DeleteCourse)
+	r.Use(middleware.RequestIdMiddleware, middleware.AuthorizeRequestMiddleware(authenticators...))
+	r.GET("/list", can(models.ActionCourseRead), handler.FetchAll)
+	r.GET("/show/:id", can(models.ActionCourseRead), handler.Find)
+	r.GET("/trash", handler.FetchTrash)
+	r.GET("/audit", can(models.ActionAuditRead), handler.FetchAuditLog)
+	r.POST("/create", can(models.ActionCourseCreate), handler.CreateCourse)
+	r.PUT("/update/:id", can(models.ActionCourseUpdate), handler.UpdateCourse)
+	r.DELETE("/delete-course/:id/material", can(models.ActionMaterialDelete), handler.DeleteMaterial)
+	r.DELETE("/delete-course/:id", can(models.ActionCourseDelete), handler.DeleteCourse)
+	r.PUT("/:id/release-schedule", can(models.ActionCoursePublish), handler.ScheduleRelease)
+	r.DELETE("/:id/release-schedule", can(models.ActionCoursePublish), handler.CancelReleaseSchedule)
+	r.POST("/:id/restore", can(models.ActionCourseRestore), handler.RestoreCourse)
+	r.POST("/:id/publish", can(models.ActionCoursePublish), handler.PublishCourse)
+	r.GET("/:id/revisions", can(models.ActionCoursePreview), handler.ListRevisions)
+	r.GET("/:id/revisions/:revision", can(models.ActionCoursePreview), handler.FetchRevision)
+	r.POST("/:id/revisions/:revision/rollback", can(models.ActionCoursePublish), handler.RollbackRevision)
+	r.GET("/:id/collaborators", can(models.ActionCoursePreview), handler.ListCollaborators)
+	r.POST("/:id/collaborators", can(models.ActionCourseShare), handler.InviteCollaborator)
+	r.PUT("/:id/collaborators/:user_id", can(models.ActionCourseShare), handler.UpdateCollaborator)
+	r.DELETE("/:id/collaborators/:user_id", can(models.ActionCourseShare), handler.RemoveCollaborator)
+	r.POST("/:id/transfer", can(models.ActionCourseTransfer), handler.TransferOwnership)
+	r.POST("/:id/uploads", can(models.ActionMaterialCreate), handler.CreateMaterialUploads)
+	r.POST("/:id/uploads/finalize", can(models.ActionMaterialCreate), handler.FinalizeMaterialUploads)
+	r.POST("/:id/materials", can(models.ActionMaterialCreate), handler.CreateMaterial)
+	r.GET("/:id/materials/:material_id", can(models.ActionCourseRead), handler.FetchMaterial)
+	r.PUT("/:id/materials/:material_id", can(models.ActionMaterialUpdate), handler.UpdateMaterial)
+	r.PUT("/:id/materials/:material_id/video", can(models.ActionMaterialUpdate), handler.ReplaceMaterialFile)
+	r.POST("/:id/materials/:material_id/restore", can(models.ActionMaterialRestore), handler.RestoreMaterial)
+	r.PATCH("/:id/materials/order", can(models.ActionCourseUpdate), handler.ReorderMaterials)
+	r.GET("/:id/materials/:material_id/captions", can(models.ActionCourseRead), handler.ListCaptions)
+	r.PUT("/:id/materials/:material_id/captions/:language", can(models.ActionMaterialUpdate), handler.PutCaption)
+	r.DELETE("/:id/materials/:material_id/captions/:language", can(models.ActionMaterialUpdate), handler.DeleteCaption)
+	r.GET("/:id/sections", can(models.ActionCourseRead), handler.ListSections)
+	r.POST("/:id/sections", can(models.ActionCourseUpdate), handler.CreateSection)
+	r.PUT("/:id/sections/:section_id", can(models.ActionCourseUpdate), handler.UpdateSection)
+	r.DELETE("/:id/sections/:section_id", can(models.ActionCourseUpdate), handler.DeleteSection)
+	r.POST("/:id/sections/:section_id/materials", can(models.ActionCourseUpdate), handler.AddSectionMaterials)
+	r.DELETE("/:id/sections/:section_id/materials", can(models.ActionCourseUpdate), handler.RemoveSectionMaterials)
 
 }
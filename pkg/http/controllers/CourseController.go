@@ -6,6 +6,7 @@ import (
 	"acourse-course-service/pkg/http/response"
 	"acourse-course-service/pkg/models"
 	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
@@ -18,7 +19,9 @@ type CourseHanlder struct {
 	Context       context.Context
 }
 
-func (hanlder *CourseHanlder) FetchAll(c *gin.Context) {
+func (handler *CourseHanlder) FetchAll(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
 
 	excludedField := []string{}
 	if c.Query("exclude") != "" {
@@ -40,7 +43,7 @@ func (hanlder *CourseHanlder) FetchAll(c *gin.Context) {
 		PerPage: 25,
 	}
 
-	courses, err := hanlder.CourseService.Fetch(hanlder.Context, excludedField, pagination)
+	courses, err := handler.CourseService.Fetch(authContext, excludedField, pagination)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -57,12 +60,14 @@ func (hanlder *CourseHanlder) FetchAll(c *gin.Context) {
 
 func (handler *CourseHanlder) Find(c *gin.Context) {
 
+	authContext := requestContext(handler.Context, c)
+
 	excludedField := []string{}
 	if c.Query("exclude") != "" {
 		excludedField = strings.Split(c.Query("exclude"), ",")
 	}
 
-	course, err := handler.CourseService.FetchById(handler.Context, c.Param("id"), excludedField)
+	course, err := handler.CourseService.FetchById(authContext, c.Param("id"), excludedField)
 
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -77,11 +82,14 @@ func (handler *CourseHanlder) Find(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", models.CourseVersion{ID: course.ID, Version: course.Version}.ETag())
 	c.JSON(http.StatusOK, course)
 }
 
 func (handler *CourseHanlder) CreateCourse(c *gin.Context) {
 
+	authContext := requestContext(handler.Context, c)
+
 	//Validate Request
 	var createCourseRequest requests.CreateCourseRequest
 
@@ -91,8 +99,19 @@ func (handler *CourseHanlder) CreateCourse(c *gin.Context) {
 		return
 	}
 
-	res, err := handler.CourseService.Create(handler.Context, createCourseRequest)
+	res, err := handler.CourseService.Create(authContext, createCourseRequest)
 	if err != nil {
+		//Partial failure, uploaded files are already compensated
+		var operationError *models.OperationError
+		if errors.As(err, &operationError) {
+			c.JSON(operationError.StatusCode, response.HttpResponse{
+				StatusCode: operationError.StatusCode,
+				Message:    operationError.Message,
+				Data:       operationError,
+			})
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -101,36 +120,41 @@ func (handler *CourseHanlder) CreateCourse(c *gin.Context) {
 	return
 }
 
-func (hanlder CourseHanlder) UpdateCourse(c *gin.Context) {
+func (handler CourseHanlder) UpdateCourse(c *gin.Context) {
 
-	val, _ := c.Get("authorization")
-	authContext := context.WithValue(hanlder.Context, "authorization", val)
+	authContext := requestContext(handler.Context, c)
 
 	//Validate Request
 	var updateCourseRequest requests.UpdateCourseRequest
 
 	err := c.ShouldBind(&updateCourseRequest)
+	if err == nil {
+		err = updateCourseRequest.ExpectVersion(c.GetHeader("If-Match"))
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	res, err := hanlder.CourseService.Update(authContext, updateCourseRequest, c.Param("id"))
+	res, err := handler.CourseService.Update(authContext, updateCourseRequest, c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	if version, ok := res.Data.(models.CourseVersion); ok {
+		c.Header("ETag", version.ETag())
+	}
+
 	c.JSON(res.StatusCode, res)
 	return
 }
 
-func (hanlder CourseHanlder) DeleteCourse(c *gin.Context) {
+func (handler CourseHanlder) DeleteCourse(c *gin.Context) {
 
-	val, _ := c.Get("authorization")
-	authContext := context.WithValue(hanlder.Context, "authorization", val)
+	authContext := requestContext(handler.Context, c)
 
-	res, err := hanlder.CourseService.DeleteCourse(authContext, c.Param("id"))
+	res, err := handler.CourseService.DeleteCourse(authContext, c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -140,10 +164,9 @@ func (hanlder CourseHanlder) DeleteCourse(c *gin.Context) {
 	return
 }
 
-func (hanlder CourseHanlder) DeleteMaterial(c *gin.Context) {
+func (handler CourseHanlder) DeleteMaterial(c *gin.Context) {
 
-	val, _ := c.Get("authorization")
-	authContext := context.WithValue(hanlder.Context, "authorization", val)
+	authContext := requestContext(handler.Context, c)
 
 	//Validate Request
 	var deleteMaterialRequest requests.DeleteMaterialsRequest
@@ -156,7 +179,272 @@ func (hanlder CourseHanlder) DeleteMaterial(c *gin.Context) {
 
 	//log.Println(val)
 
-	res, err := hanlder.CourseService.DeleteMaterials(authContext, c.Param("id"), deleteMaterialRequest)
+	res, err := handler.CourseService.DeleteMaterials(authContext, c.Param("id"), deleteMaterialRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+
+}
+
+func (handler CourseHanlder) ScheduleRelease(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var releaseScheduleRequest requests.ReleaseScheduleRequest
+
+	err := c.ShouldBind(&releaseScheduleRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.ScheduleRelease(authContext, c.Param("id"), releaseScheduleRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) CancelReleaseSchedule(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.CancelReleaseSchedule(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) FetchTrash(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.FetchTrash(authContext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) RestoreCourse(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.RestoreCourse(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) RestoreMaterial(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.RestoreMaterial(authContext, c.Param("id"), c.Param("material_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) PublishCourse(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.PublishCourse(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) ListRevisions(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.ListRevisions(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) FetchRevision(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.FetchRevision(authContext, c.Param("id"), c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) RollbackRevision(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.RollbackRevision(authContext, c.Param("id"), c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) CreateMaterialUploads(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var createMaterialUploadsRequest requests.CreateMaterialUploadsRequest
+
+	err := c.ShouldBind(&createMaterialUploadsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.CreateMaterialUploads(authContext, c.Param("id"), createMaterialUploadsRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) FinalizeMaterialUploads(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var finalizeMaterialUploadsRequest requests.FinalizeMaterialUploadsRequest
+
+	err := c.ShouldBind(&finalizeMaterialUploadsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.FinalizeMaterialUploads(authContext, c.Param("id"), finalizeMaterialUploadsRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) FetchMaterial(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.FetchMaterial(authContext, c.Param("id"), c.Param("material_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) CreateMaterial(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var createMaterialRequest requests.CreateCourseMaterialRequest
+
+	err := c.ShouldBind(&createMaterialRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.CreateMaterial(authContext, c.Param("id"), createMaterialRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) UpdateMaterial(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var updateMaterialRequest requests.UpdateMaterialRequest
+
+	err := c.ShouldBind(&updateMaterialRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.UpdateMaterial(authContext, c.Param("id"), c.Param("material_id"), updateMaterialRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) ReplaceMaterialFile(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var replaceMaterialFileRequest requests.ReplaceMaterialFileRequest
+
+	err := c.ShouldBind(&replaceMaterialFileRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.ReplaceMaterialFile(authContext, c.Param("id"), c.Param("material_id"), replaceMaterialFileRequest)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -164,5 +452,318 @@ func (hanlder CourseHanlder) DeleteMaterial(c *gin.Context) {
 
 	c.JSON(res.StatusCode, res)
 	return
+}
+
+func (handler CourseHanlder) ReorderMaterials(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var reorderMaterialsRequest requests.ReorderMaterialsRequest
+
+	err := c.ShouldBind(&reorderMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
+	res, err := handler.CourseService.ReorderMaterials(authContext, c.Param("id"), reorderMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) ListCaptions(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.ListCaptions(authContext, c.Param("id"), c.Param("material_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) PutCaption(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var putCaptionRequest requests.PutCaptionRequest
+
+	err := c.ShouldBind(&putCaptionRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.PutCaption(authContext, c.Param("id"), c.Param("material_id"), c.Param("language"), putCaptionRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) DeleteCaption(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.DeleteCaption(authContext, c.Param("id"), c.Param("material_id"), c.Param("language"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) ListSections(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.ListSections(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) CreateSection(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var createSectionRequest requests.CreateSectionRequest
+
+	err := c.ShouldBind(&createSectionRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.CreateSection(authContext, c.Param("id"), createSectionRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) UpdateSection(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var updateSectionRequest requests.UpdateSectionRequest
+
+	err := c.ShouldBind(&updateSectionRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.UpdateSection(authContext, c.Param("id"), c.Param("section_id"), updateSectionRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) DeleteSection(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.DeleteSection(authContext, c.Param("id"), c.Param("section_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) AddSectionMaterials(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var sectionMaterialsRequest requests.SectionMaterialsRequest
+
+	err := c.ShouldBind(&sectionMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.AddSectionMaterials(authContext, c.Param("id"), c.Param("section_id"), sectionMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) RemoveSectionMaterials(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var sectionMaterialsRequest requests.SectionMaterialsRequest
+
+	err := c.ShouldBind(&sectionMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.RemoveSectionMaterials(authContext, c.Param("id"), c.Param("section_id"), sectionMaterialsRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) ListCollaborators(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.ListCollaborators(authContext, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) InviteCollaborator(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var inviteCollaboratorRequest requests.InviteCollaboratorRequest
+
+	err := c.ShouldBind(&inviteCollaboratorRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.InviteCollaborator(authContext, c.Param("id"), inviteCollaboratorRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) UpdateCollaborator(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var updateCollaboratorRequest requests.UpdateCollaboratorRequest
+
+	err := c.ShouldBind(&updateCollaboratorRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.UpdateCollaborator(authContext, c.Param("id"), c.Param("user_id"), updateCollaboratorRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) RemoveCollaborator(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	res, err := handler.CourseService.RemoveCollaborator(authContext, c.Param("id"), c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) TransferOwnership(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var transferOwnershipRequest requests.TransferOwnershipRequest
+
+	err := c.ShouldBind(&transferOwnershipRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.TransferOwnership(authContext, c.Param("id"), transferOwnershipRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
+}
+
+func (handler CourseHanlder) FetchAuditLog(c *gin.Context) {
+
+	authContext := requestContext(handler.Context, c)
+
+	//Validate Request
+	var auditLogRequest requests.AuditLogRequest
+
+	err := c.ShouldBind(&auditLogRequest)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := handler.CourseService.FetchAuditLog(authContext, auditLogRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(res.StatusCode, res)
+	return
 }
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/signature"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type LocalStorageHandler struct {
+	StorageRepository contracts.LocalStorageRepository
+	routePath         string
+	fileServer        http.Handler
+}
+
+//Serve files stored by local storage repository and receive presigned upload parts, directory listing is disabled.
+//When signedDownloads is enabled every download must carry a valid HMAC signature.
+func SetupLocalStorageHandler(router *gin.Engine, routePath string, rootDir string, storageRepository contracts.LocalStorageRepository, signedDownloads bool) {
+
+	routePath = strings.TrimSuffix(routePath, "/")
+
+	handler := &LocalStorageHandler{
+		StorageRepository: storageRepository,
+		routePath:         routePath,
+		fileServer:        http.StripPrefix(routePath, http.FileServer(gin.Dir(rootDir, false))),
+	}
+
+	if signedDownloads {
+		router.GET(routePath+"/*filepath", handler.SignedDownload)
+		router.HEAD(routePath+"/*filepath", handler.SignedDownload)
+	} else {
+		router.StaticFS(routePath, gin.Dir(rootDir, false))
+	}
+	router.PUT(routePath+"/*filepath", handler.UploadPart)
+}
+
+func (handler *LocalStorageHandler) SignedDownload(c *gin.Context) {
+
+	objectKey := strings.TrimPrefix(c.Param("filepath"), "/")
+
+	//Prefix signed urls are /_signed/<expires>/<signature>/<object key>
+	segments := strings.SplitN(objectKey, "/", 4)
+	if len(segments) == 4 && segments[0] == signature.PrefixSegment {
+		handler.prefixSignedDownload(c, segments[1], segments[2], segments[3])
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid expiration"})
+		return
+	}
+
+	err = handler.StorageRepository.VerifyDownload(objectKey, expires, c.Query("signature"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	handler.fileServer.ServeHTTP(c.Writer, c.Request)
+}
+
+func (handler *LocalStorageHandler) prefixSignedDownload(c *gin.Context, expiration string, sign string, objectKey string) {
+
+	expires, err := strconv.ParseInt(expiration, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid expiration"})
+		return
+	}
+
+	err = handler.StorageRepository.VerifyPrefixDownload(objectKey, expires, sign)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	//Serve the object itself, without the signature segments
+	c.Request.URL.Path = handler.routePath + "/" + objectKey
+	c.Request.URL.RawPath = ""
+	handler.fileServer.ServeHTTP(c.Writer, c.Request)
+}
+
+func (handler *LocalStorageHandler) UploadPart(c *gin.Context) {
+
+	objectKey := strings.TrimPrefix(c.Param("filepath"), "/")
+	uploadId := c.Query("upload_id")
+
+	partNumber, err := strconv.ParseInt(c.Query("part_number"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid part number"})
+		return
+	}
+
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiration"})
+		return
+	}
+
+	err = handler.StorageRepository.VerifyUploadPart(objectKey, uploadId, partNumber, expires, c.Query("signature"))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	etag, err := handler.StorageRepository.WriteUploadPart(uploadId, partNumber, c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const tusVersion = "1.0.0"
+
+type ResumableUploadHandler struct {
+	UploadService contracts.ResumableUploadService
+	Context       context.Context
+}
+
+//Tus 1.0 compatible upload endpoint with creation & termination extensions
+func SetupResumableUploadHandler(ctx context.Context, router *gin.Engine, uploadService contracts.ResumableUploadService, authenticators []middleware.Authenticator) {
+
+	handler := &ResumableUploadHandler{UploadService: uploadService, Context: ctx}
+	authorizeRequest := middleware.AuthorizeRequestMiddleware(authenticators...)
+
+	r := router.Group("/course/uploads")
+	r.OPTIONS("", handler.Options)
+	r.OPTIONS("/:upload_id", handler.Options)
+	r.POST("", handler.TusResumable, authorizeRequest, handler.Create)
+	r.HEAD("/:upload_id", handler.TusResumable, authorizeRequest, handler.Status)
+	r.PATCH("/:upload_id", handler.TusResumable, authorizeRequest, handler.Patch)
+	r.DELETE("/:upload_id", handler.TusResumable, authorizeRequest, handler.Terminate)
+}
+
+//Every tus request except OPTIONS must use the supported protocol version
+func (handler *ResumableUploadHandler) TusResumable(c *gin.Context) {
+
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return
+	}
+	c.Next()
+}
+
+func (handler *ResumableUploadHandler) Options(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination")
+	c.Header("Tus-Max-Size", strconv.FormatInt(handler.UploadService.MaxSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *ResumableUploadHandler) Create(c *gin.Context) {
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Length"})
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	upload, err := handler.UploadService.Create(requestContext(handler.Context, c), length, metadata)
+	if err != nil {
+		handler.abortWithError(c, err)
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Status(http.StatusCreated)
+}
+
+func (handler *ResumableUploadHandler) Status(c *gin.Context) {
+
+	upload, err := handler.UploadService.Find(requestContext(handler.Context, c), c.Param("upload_id"))
+	if err != nil {
+		handler.abortWithError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	if len(upload.Metadata) > 0 {
+		c.Header("Upload-Metadata", encodeUploadMetadata(upload.Metadata))
+	}
+	c.Status(http.StatusOK)
+}
+
+func (handler *ResumableUploadHandler) Patch(c *gin.Context) {
+
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Offset"})
+		return
+	}
+
+	upload, err := handler.UploadService.WriteChunk(requestContext(handler.Context, c), c.Param("upload_id"), offset, c.Request.Body)
+
+	//Offset is reported even when writing fails so the client can resume
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	if err != nil {
+		handler.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *ResumableUploadHandler) Terminate(c *gin.Context) {
+
+	err := handler.UploadService.Terminate(requestContext(handler.Context, c), c.Param("upload_id"))
+	if err != nil {
+		handler.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (handler *ResumableUploadHandler) abortWithError(c *gin.Context, err error) {
+
+	status := http.StatusInternalServerError
+	var policyError *models.MediaPolicyError
+
+	switch {
+	case errors.Is(err, models.ErrUploadNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, models.ErrUploadForbidden):
+		status = http.StatusForbidden
+	case errors.Is(err, models.ErrUploadOffsetMismatch), errors.Is(err, models.ErrUploadClaimed):
+		status = http.StatusConflict
+	case errors.Is(err, models.ErrUploadTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.As(err, &policyError):
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+//Upload-Metadata is a comma separated list of "key base64(value)" pairs
+func parseUploadMetadata(header string) (map[string]string, error) {
+
+	metadata := make(map[string]string)
+
+	for _, pair := range strings.Split(header, ",") {
+
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, " ", 2)
+		if len(parts) == 1 {
+			metadata[parts[0]] = ""
+			continue
+		}
+
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.New("Invalid Upload-Metadata value for " + parts[0])
+		}
+		metadata[parts[0]] = string(value)
+	}
+
+	return metadata, nil
+}
+
+func encodeUploadMetadata(metadata map[string]string) string {
+
+	var pairs []string
+	for key, value := range metadata {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
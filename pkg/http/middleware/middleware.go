@@ -1,10 +1,21 @@
 package middleware
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
 	"net/http"
-	"strings"
+	"regexp"
+)
+
+//Keys of request values services read from their context
+type contextKey string
+
+const (
+	AuthorizationKey contextKey = "authorization"
+	RequestIdKey     contextKey = "request_id"
 )
 
 type Authorization struct {
@@ -13,63 +24,101 @@ type Authorization struct {
 	Role       string
 }
 
-func AuthorizeRequestMiddleware(c *gin.Context) {
-
-	userPermission := c.Request.Header.Get("X-User-Permission")
-	userRole := c.Request.Header.Get("X-User-Role")
-	userId := c.Request.Header.Get("X-User-Id")
+//Request ids taken over from callers, anything else is replaced by a generated one
+var requestIdPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
 
-	if userId != "" && userRole != "" && userPermission != "" {
+//Every request carries an id, given by the caller in X-Request-Id or generated, so audit events & logs can be traced back to it
+func RequestIdMiddleware(c *gin.Context) {
 
-		log.Println("Request Authorized")
+	requestId := c.GetHeader("X-Request-Id")
 
-		c.Set("authorization", &Authorization{
-			Permission: userPermission,
-			UserID:     userId,
-			Role:       userRole,
-		})
-		c.Next()
-
-	} else {
-		log.Println("Request Unauthorized")
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Request Unathorized"})
-		c.Abort()
+	if !requestIdPattern.MatchString(requestId) {
+		id := make([]byte, 16)
+		_, err := rand.Read(id)
+		if err != nil {
+			log.Println(err.Error())
+		}
+		requestId = hex.EncodeToString(id)
 	}
 
+	c.Set("request_id", requestId)
+	c.Header("X-Request-Id", requestId)
+	c.Next()
+}
+
+//Authenticator resolves who makes the request.
+//Requests without its kind of credentials give no authorization & no error, so the next authenticator is tried
+type Authenticator interface {
+	Authenticate(request *http.Request) (*Authorization, error)
 }
 
-func CanCreateCourseMiddleware(c *gin.Context) {
+//Requests are authorized by the first authenticator which recognizes their credentials, invalid credentials are rejected right away
+func AuthorizeRequestMiddleware(authenticators ...Authenticator) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+
+		for _, authenticator := range authenticators {
+
+			authorization, err := authenticator.Authenticate(c.Request)
+			if err != nil {
+				log.Printf("Request Unauthorized, %v", err.Error())
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Request Unathorized"})
+				c.Abort()
+				return
+			}
 
-	permission, _ := c.Get("authorization")
-	p := permission.(*Authorization).Permission
+			if authorization != nil {
+				log.Println("Request Authorized")
 
-	if !strings.Contains(p, "c") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Can't Create, Unathorized"})
+				c.Set("authorization", authorization)
+				c.Next()
+				return
+			}
+		}
+
+		log.Println("Request Unauthorized")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Request Unathorized"})
 		c.Abort()
 	}
-	c.Next()
 }
 
-func CanUpdateCourseMiddleware(c *gin.Context) {
+//TrustedGatewayAuthenticator takes the user from X-User-* headers, only safe behind a gateway which sets them itself
+type TrustedGatewayAuthenticator struct{}
 
-	permission, _ := c.Get("authorization")
-	p := permission.(*Authorization).Permission
+func (a TrustedGatewayAuthenticator) Authenticate(request *http.Request) (*Authorization, error) {
 
-	if !strings.Contains(p, "u") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Can't Update, Unathorized"})
-		c.Abort()
+	userPermission := request.Header.Get("X-User-Permission")
+	userRole := request.Header.Get("X-User-Role")
+	userId := request.Header.Get("X-User-Id")
+
+	if userId == "" || userRole == "" || userPermission == "" {
+		return nil, nil
 	}
-	c.Next()
+
+	return &Authorization{
+		Permission: userPermission,
+		UserID:     userId,
+		Role:       userRole,
+	}, nil
+}
+
+//ActionPolicy tells whether the requester may perform the action on at least some resources, ownership is checked once the resource is loaded
+type ActionPolicy interface {
+	MayPerform(authorization Authorization, action string) bool
 }
 
-func CanDeleteCourseMiddleware(c *gin.Context) {
+//Requests which no policy rule allows the action for are rejected before the handler loads anything
+func CanPerformMiddleware(policy ActionPolicy, action string) gin.HandlerFunc {
 
-	permission, _ := c.Get("authorization")
-	p := permission.(*Authorization).Permission
+	return func(c *gin.Context) {
 
-	if !strings.Contains(p, "d") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Can't Delete, Unathorized"})
-		c.Abort()
+		authorization, _ := c.Get("authorization")
+
+		if !policy.MayPerform(*authorization.(*Authorization), action) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Can't %v, Unathorized", action)})
+			c.Abort()
+			return
+		}
+		c.Next()
 	}
-	c.Next()
 }
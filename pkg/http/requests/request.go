@@ -1,19 +1,27 @@
 package requests
 
 import (
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
 	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"mime/multipart"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
+//Course is owned by the requester, user_id creates it on behalf of another user when the access policy allows impersonation
 type CreateCourseRequest struct {
-	UserID      int64                   `form:"user_id" json:"user_id" binding:"required"`
+	UserID      int64                   `form:"user_id" json:"user_id"`
 	Name        string                  `form:"name" json:"name" binding:"required"`
 	Description string                  `form:"description" json:"description" binding:"required"`
 	Price       float32                 `form:"price" json:"price" binding:"required"`
 	IsReleased  *bool                   `form:"is_released" json:"is_released" binding:"required"`
 	Materials   []CreateMaterialRequest `form:"materials" json:"materials" binding:"required,dive"`
-	Files       []*multipart.FileHeader `form:"files" json:"files" binding:"required"`
+	Files       []*multipart.FileHeader `form:"files" json:"files"`
 	Image       *multipart.FileHeader   `form:"image" json:"image" binding:"required"`
 }
 
@@ -23,14 +31,132 @@ type CreateMaterialRequest struct {
 	Description string             `form:"description" json:"description" binding:"required"`
 	Order       *int               `form:"order" json:"order" binding:"required"`
 	NewOrder    *int               `form:"new_order" json:"new_order"`
+	UploadID    string             `form:"upload_id" json:"upload_id"`
+	Kind        string             `form:"kind" json:"kind"`
+	Url         string             `form:"url" json:"url"`
+	Content     string             `form:"content" json:"content"`
+	Quiz        *models.Quiz       `form:"quiz" json:"quiz"`
+	Duration    *int               `form:"duration" json:"duration"`
+}
+
+//Article content above this length is rejected
+const MaxArticleLength = 1024 * 1024
+
+//Kind defaults to video, existing materials have to repeat their kind
+func (m CreateMaterialRequest) MaterialKind() string {
+	if m.Kind == "" {
+		return models.MaterialKindVideo
+	}
+	return m.Kind
+}
+
+//Only file kinds without resumable upload take a file from the request
+func (m CreateMaterialRequest) takesFile() bool {
+	return m.UploadID == "" && models.MaterialKindHasFile(m.MaterialKind())
+}
+
+//Validate kind specific fields, new materials need the content of their kind
+func (m CreateMaterialRequest) Validate() error {
+
+	kind := m.MaterialKind()
+	isNew := m.MaterialID.IsZero()
+
+	if !models.IsMaterialKind(kind) {
+		return errors.New(fmt.Sprintf("Material %v has unknown kind %v", m.Name, kind))
+	}
+
+	if m.Duration != nil && *m.Duration < 0 {
+		return errors.New(fmt.Sprintf("Material %v duration must not be negative", m.Name))
+	}
+
+	if m.UploadID != "" && !models.MaterialKindHasFile(kind) {
+		return errors.New(fmt.Sprintf("Material %v of kind %v can't have an upload", m.Name, kind))
+	}
+
+	switch kind {
+	case models.MaterialKindLink:
+		if m.Url == "" && isNew {
+			return errors.New(fmt.Sprintf("Link material %v requires url", m.Name))
+		}
+		if m.Url != "" {
+			linkUrl, err := url.Parse(m.Url)
+			if err != nil || (linkUrl.Scheme != "http" && linkUrl.Scheme != "https") || linkUrl.Host == "" {
+				return errors.New(fmt.Sprintf("Link material %v requires an absolute http or https url", m.Name))
+			}
+		}
+	case models.MaterialKindArticle:
+		if m.Content == "" && isNew {
+			return errors.New(fmt.Sprintf("Article material %v requires content", m.Name))
+		}
+		if len(m.Content) > MaxArticleLength {
+			return errors.New(fmt.Sprintf("Article material %v content exceeds %v bytes", m.Name, MaxArticleLength))
+		}
+	case models.MaterialKindQuiz:
+		if m.Quiz == nil && isNew {
+			return errors.New(fmt.Sprintf("Quiz material %v requires quiz", m.Name))
+		}
+		if m.Quiz != nil {
+			err := m.Quiz.Validate()
+			if err != nil {
+				return errors.New(fmt.Sprintf("Quiz material %v is invalid, %v", m.Name, err.Error()))
+			}
+		}
+	}
+
+	return nil
+}
+
+//Files are assigned in order to file materials which don't refer to a resumable upload, -1 means no file
+func MaterialFileIndexes(materials []CreateMaterialRequest) []int {
+
+	indexes := make([]int, len(materials))
+	fileIndex := 0
+
+	for i, material := range materials {
+		if !material.takesFile() {
+			indexes[i] = -1
+			continue
+		}
+		indexes[i] = fileIndex
+		fileIndex++
+	}
+
+	return indexes
 }
 
+func countMaterialFiles(materials []CreateMaterialRequest) int {
+
+	total := 0
+	for _, material := range materials {
+		if material.takesFile() {
+			total++
+		}
+	}
+	return total
+}
+
+func validateMaterials(materials []CreateMaterialRequest) error {
+	for _, material := range materials {
+		err := material.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Files can be omitted when material files are uploaded directly to storage, links, articles & quizzes have no file
 func (r CreateCourseRequest) ValidateMaterialFiles() error {
 
-	material_length := len(r.Materials)
+	err := validateMaterials(r.Materials)
+	if err != nil {
+		return err
+	}
+
+	material_length := countMaterialFiles(r.Materials)
 	material_files_length := len(r.Files)
 
-	if material_length != material_files_length {
+	if material_files_length != 0 && material_length != material_files_length {
 		return errors.New("Total Material Data and Material Files is not match")
 	}
 
@@ -46,20 +172,334 @@ type UpdateCourseRequest struct {
 	Materials   []CreateMaterialRequest `form:"materials" json:"materials"`
 	Files       []*multipart.FileHeader `form:"files" json:"files"`
 	Image       []*multipart.FileHeader `form:"image" json:"image"`
+	Version     *int                    `form:"version" json:"version"`
 }
 
+//Existing materials keep their file when no file is left for them
 func (r UpdateCourseRequest) ValidateMaterialFiles() error {
 
-	material_length := len(r.Materials)
+	err := validateMaterials(r.Materials)
+	if err != nil {
+		return err
+	}
+
+	material_length := countMaterialFiles(r.Materials)
 	material_files_length := len(r.Files)
 
-	if material_length != material_files_length {
+	if material_files_length > material_length {
 		return errors.New("Total Material Data and Material Files is not match")
 	}
 
 	return nil
 }
 
+//Expected version from an If-Match header such as "3" or W/"3", it takes precedence over the version field.
+//Any version matches "*"
+func (r *UpdateCourseRequest) ExpectVersion(ifMatch string) error {
+
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		return nil
+	}
+
+	if ifMatch == "*" {
+		r.Version = nil
+		return nil
+	}
+
+	tag := strings.TrimPrefix(ifMatch, "W/")
+	version, err := strconv.Atoi(strings.Trim(tag, `"`))
+	if err != nil || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return errors.New(fmt.Sprintf("If-Match %v is not a course version entity tag", ifMatch))
+	}
+
+	r.Version = &version
+	return nil
+}
+
 type DeleteMaterialsRequest struct {
 	MaterialIDs []string `form:"material_id" json:"material_id" binding:"required"`
 }
+
+type CreateMaterialUploadsRequest struct {
+	Files []MaterialUploadFileRequest `form:"files" json:"files" binding:"required,dive"`
+}
+
+type MaterialUploadFileRequest struct {
+	Filename    string `form:"filename" json:"filename" binding:"required"`
+	ContentType string `form:"content_type" json:"content_type" binding:"required"`
+	Size        int64  `form:"size" json:"size" binding:"required"`
+}
+
+type FinalizeMaterialUploadsRequest struct {
+	Uploads []FinalizeMaterialUploadRequest `form:"uploads" json:"uploads" binding:"required,dive"`
+}
+
+type FinalizeMaterialUploadRequest struct {
+	MaterialID  string                  `form:"material_id" json:"material_id"`
+	Name        string                  `form:"name" json:"name"`
+	Description string                  `form:"description" json:"description"`
+	Order       *int                    `form:"order" json:"order"`
+	Kind        string                  `form:"kind" json:"kind"`
+	Key         string                  `form:"key" json:"key" binding:"required"`
+	UploadID    string                  `form:"upload_id" json:"upload_id" binding:"required"`
+	Parts       []response.UploadedPart `form:"parts" json:"parts" binding:"required,dive"`
+}
+
+//Uploads without material id create a new material, so it needs name & order.
+//Every upload is completed once & every material gets at most one of them
+func (r FinalizeMaterialUploadsRequest) ValidateMaterials() error {
+
+	materialIds := make(map[string]bool)
+	keys := make(map[string]bool)
+	uploadIds := make(map[string]bool)
+
+	for _, upload := range r.Uploads {
+		if upload.MaterialID == "" && (upload.Name == "" || upload.Order == nil) {
+			return errors.New(fmt.Sprintf("Upload %v requires material_id or name and order", upload.Key))
+		}
+		if upload.Kind != "" && !models.MaterialKindHasFile(upload.Kind) {
+			return errors.New(fmt.Sprintf("Upload %v can't be attached to material of kind %v", upload.Key, upload.Kind))
+		}
+
+		if upload.MaterialID != "" && materialIds[upload.MaterialID] {
+			return errors.New(fmt.Sprintf("Material %v is given more than once", upload.MaterialID))
+		}
+		if keys[upload.Key] {
+			return errors.New(fmt.Sprintf("Upload %v is given more than once", upload.Key))
+		}
+		if uploadIds[upload.UploadID] {
+			return errors.New(fmt.Sprintf("Upload id %v is given more than once", upload.UploadID))
+		}
+		materialIds[upload.MaterialID] = true
+		keys[upload.Key] = true
+		uploadIds[upload.UploadID] = true
+	}
+
+	return nil
+}
+
+type PutCaptionRequest struct {
+	File  *multipart.FileHeader `form:"file" json:"file" binding:"required"`
+	Label string                `form:"label" json:"label"`
+}
+
+type CreateSectionRequest struct {
+	Name        string `form:"name" json:"name" binding:"required"`
+	Description string `form:"description" json:"description"`
+	Order       *int   `form:"order" json:"order"`
+}
+
+type UpdateSectionRequest struct {
+	Name        string  `form:"name" json:"name"`
+	Description *string `form:"description" json:"description"`
+	Order       *int    `form:"order" json:"order"`
+}
+
+//Materials are placed in the given order at position inside the section, at its end when position is omitted
+type SectionMaterialsRequest struct {
+	MaterialIDs []string `form:"material_id" json:"material_id" binding:"required,min=1"`
+	Position    *int     `form:"position" json:"position" binding:"omitempty,min=0"`
+}
+
+//Either the complete new order of a section or move operations relative to other materials
+type ReorderMaterialsRequest struct {
+	SectionID   string                `form:"section_id" json:"section_id"`
+	MaterialIDs []string              `form:"material_ids" json:"material_ids"`
+	Moves       []MaterialMoveRequest `form:"moves" json:"moves" binding:"dive"`
+}
+
+//Material is moved right before or after another material, into the section of that material
+type MaterialMoveRequest struct {
+	MaterialID string `form:"material_id" json:"material_id" binding:"required"`
+	Before     string `form:"before" json:"before"`
+	After      string `form:"after" json:"after"`
+}
+
+func (r ReorderMaterialsRequest) Validate() error {
+
+	if (len(r.MaterialIDs) == 0) == (len(r.Moves) == 0) {
+		return errors.New("Either material_ids or moves is required")
+	}
+
+	if len(r.Moves) > 0 && r.SectionID != "" {
+		return errors.New("section_id only applies to material_ids, moves follow the section of their anchor")
+	}
+
+	for _, move := range r.Moves {
+		if (move.Before == "") == (move.After == "") {
+			return errors.New(fmt.Sprintf("Move of material %v requires either before or after", move.MaterialID))
+		}
+		if move.Before == move.MaterialID || move.After == move.MaterialID {
+			return errors.New(fmt.Sprintf("Material %v can't be moved relative to itself", move.MaterialID))
+		}
+	}
+
+	return nil
+}
+
+//Single material added to a course, file kinds take either a file or upload_id of a finished resumable upload
+type CreateCourseMaterialRequest struct {
+	Name        string                `form:"name" json:"name" binding:"required"`
+	Description string                `form:"description" json:"description" binding:"required"`
+	Order       *int                  `form:"order" json:"order"`
+	SectionID   string                `form:"section_id" json:"section_id"`
+	UploadID    string                `form:"upload_id" json:"upload_id"`
+	Kind        string                `form:"kind" json:"kind"`
+	Url         string                `form:"url" json:"url"`
+	Content     string                `form:"content" json:"content"`
+	Quiz        *models.Quiz          `form:"quiz" json:"quiz"`
+	Duration    *int                  `form:"duration" json:"duration"`
+	File        *multipart.FileHeader `form:"file" json:"file"`
+}
+
+//Same material as it would be given within a course request
+func (r CreateCourseMaterialRequest) Material() CreateMaterialRequest {
+	return CreateMaterialRequest{
+		Name:        r.Name,
+		Description: r.Description,
+		Order:       r.Order,
+		UploadID:    r.UploadID,
+		Kind:        r.Kind,
+		Url:         r.Url,
+		Content:     r.Content,
+		Quiz:        r.Quiz,
+		Duration:    r.Duration,
+	}
+}
+
+func (r CreateCourseMaterialRequest) Validate() error {
+
+	material := r.Material()
+
+	err := material.Validate()
+	if err != nil {
+		return err
+	}
+
+	if r.File != nil && r.UploadID != "" {
+		return errors.New(fmt.Sprintf("Material %v takes either file or upload_id", r.Name))
+	}
+
+	if models.MaterialKindHasFile(material.MaterialKind()) && r.File == nil && r.UploadID == "" {
+		return errors.New(fmt.Sprintf("Material %v requires file or upload_id", r.Name))
+	}
+
+	if !models.MaterialKindHasFile(material.MaterialKind()) && r.File != nil {
+		return errors.New(fmt.Sprintf("Material %v of kind %v can't have a file", r.Name, material.MaterialKind()))
+	}
+
+	return nil
+}
+
+//Only given fields are changed, order & section are changed through their own endpoints
+type UpdateMaterialRequest struct {
+	Name        *string      `form:"name" json:"name"`
+	Description *string      `form:"description" json:"description"`
+	Url         *string      `form:"url" json:"url"`
+	Content     *string      `form:"content" json:"content"`
+	Quiz        *models.Quiz `form:"quiz" json:"quiz"`
+	Duration    *int         `form:"duration" json:"duration"`
+}
+
+//Changes as they would be given for an existing material within a course request
+func (r UpdateMaterialRequest) Material(existing models.Material) CreateMaterialRequest {
+
+	material := CreateMaterialRequest{
+		MaterialID:  existing.MaterialID,
+		Name:        existing.Name,
+		Description: existing.Description,
+		Kind:        existing.MaterialKind(),
+		Quiz:        r.Quiz,
+		Duration:    r.Duration,
+	}
+
+	if r.Name != nil {
+		material.Name = *r.Name
+	}
+	if r.Description != nil {
+		material.Description = *r.Description
+	}
+	if r.Url != nil {
+		material.Url = *r.Url
+	}
+	if r.Content != nil {
+		material.Content = *r.Content
+	}
+
+	return material
+}
+
+type ReplaceMaterialFileRequest struct {
+	UploadID string                `form:"upload_id" json:"upload_id"`
+	File     *multipart.FileHeader `form:"file" json:"file"`
+}
+
+func (r ReplaceMaterialFileRequest) Validate() error {
+	if (r.File == nil) == (r.UploadID == "") {
+		return errors.New("Either file or upload_id is required")
+	}
+	return nil
+}
+
+//Times are RFC 3339, omitted time leaves that change unscheduled
+type ReleaseScheduleRequest struct {
+	PublishAt   *time.Time `form:"publish_at" json:"publish_at" time_format:"2006-01-02T15:04:05Z07:00"`
+	UnpublishAt *time.Time `form:"unpublish_at" json:"unpublish_at" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+//Scheduled changes have to lie in the future & the first of them has to change current release state
+func (r ReleaseScheduleRequest) Validate(released bool, now time.Time) error {
+
+	if r.PublishAt == nil && r.UnpublishAt == nil {
+		return errors.New("Either publish_at or unpublish_at is required")
+	}
+
+	if (r.PublishAt != nil && !r.PublishAt.After(now)) || (r.UnpublishAt != nil && !r.UnpublishAt.After(now)) {
+		return errors.New("Scheduled release changes have to be in the future")
+	}
+
+	if r.PublishAt != nil && r.UnpublishAt != nil && r.PublishAt.Equal(*r.UnpublishAt) {
+		return errors.New("publish_at and unpublish_at can't be the same time")
+	}
+
+	publishFirst := r.UnpublishAt == nil || (r.PublishAt != nil && r.PublishAt.Before(*r.UnpublishAt))
+	if publishFirst && released {
+		return errors.New("Course is already released, schedule unpublish_at before publishing it again")
+	}
+	if !publishFirst && !released {
+		return errors.New("Course isn't released, schedule publish_at before unpublishing it")
+	}
+
+	return nil
+}
+
+//Collaborators are invited as editors or viewers, ownership is handed over by transfer
+type InviteCollaboratorRequest struct {
+	UserID int64  `form:"user_id" json:"user_id" binding:"required"`
+	Role   string `form:"role" json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type UpdateCollaboratorRequest struct {
+	Role string `form:"role" json:"role" binding:"required,oneof=editor viewer"`
+}
+
+type TransferOwnershipRequest struct {
+	UserID int64 `form:"user_id" json:"user_id" binding:"required"`
+}
+
+//Audit log of a course, of an actor or both within a time range, latest events first
+type AuditLogRequest struct {
+	CourseID string     `form:"course_id" json:"course_id"`
+	Actor    string     `form:"actor" json:"actor"`
+	From     *time.Time `form:"from" json:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       *time.Time `form:"to" json:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page     int64      `form:"page" json:"page" binding:"omitempty,min=1"`
+}
+
+func (r AuditLogRequest) Validate() error {
+	if r.From != nil && r.To != nil && r.From.After(*r.To) {
+		return errors.New("from has to be before to")
+	}
+	return nil
+}
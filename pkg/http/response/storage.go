@@ -1,5 +1,7 @@
 package response
 
+import "time"
+
 type S3Response struct {
 	Filename string `json:"filename"`
 	Success  bool   `json:"success"`
@@ -8,3 +10,29 @@ type S3Response struct {
 	Message  string `json:"message"`
 	Order    int    `json:"order"`
 }
+
+type PresignedUpload struct {
+	Filename  string          `json:"filename"`
+	Key       string          `json:"key"`
+	UploadID  string          `json:"upload_id"`
+	PartSize  int64           `json:"part_size"`
+	Parts     []PresignedPart `json:"parts"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+type PresignedPart struct {
+	PartNumber int64  `json:"part_number"`
+	Method     string `json:"method"`
+	Url        string `json:"url"`
+}
+
+type UploadedPart struct {
+	PartNumber int64  `form:"part_number" json:"part_number" binding:"required"`
+	ETag       string `form:"etag" json:"etag" binding:"required"`
+}
+
+type StorageObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
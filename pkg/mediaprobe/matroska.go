@@ -0,0 +1,366 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+//Matroska element ids, including their length marker
+const (
+	idEBML            = 0x1A45DFA3
+	idDocType         = 0x4282
+	idSegment         = 0x18538067
+	idInfo            = 0x1549A966
+	idTimecodeScale   = 0x2AD7B1
+	idDuration        = 0x4489
+	idTracks          = 0x1654AE6B
+	idTrackEntry      = 0xAE
+	idTrackType       = 0x83
+	idCodecID         = 0x86
+	idDefaultDuration = 0x23E383
+	idVideo           = 0xE0
+	idPixelWidth      = 0xB0
+	idPixelHeight     = 0xBA
+	idCluster         = 0x1F43B675
+)
+
+const (
+	matroskaTrackVideo = 1
+	matroskaTrackAudio = 2
+)
+
+var matroskaCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_AV1":            "av1",
+	"A_AAC":            "aac",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_MPEG/L3":        "mp3",
+	"A_FLAC":           "flac",
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+//Element id keeps its length marker bits
+func readElementID(reader io.ByteReader) (uint32, error) {
+
+	first, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 4 && first&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 4 {
+		return 0, errors.New("invalid matroska element id")
+	}
+
+	id := uint32(first)
+	for i := 1; i < length; i++ {
+		next, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		id = id<<8 | uint32(next)
+	}
+
+	return id, nil
+}
+
+//Element size drops its length marker, all value bits set means unknown size
+func readElementSize(reader io.ByteReader) (int64, bool, error) {
+
+	first, err := reader.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	length := 1
+	mask := byte(0x80)
+	for ; length <= 8 && first&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, false, errors.New("invalid matroska element size")
+	}
+
+	value := uint64(first & (mask - 1))
+	unknown := value == uint64(mask-1)
+
+	for i := 1; i < length; i++ {
+		next, err := reader.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		value = value<<8 | uint64(next)
+		unknown = unknown && next == 0xFF
+	}
+
+	return int64(value), unknown, nil
+}
+
+func probeMatroska(reader byteReader, info *Info) error {
+
+	//1. EBML header tells whether it's matroska or webm
+	id, size, _, err := readElementHeader(reader)
+	if err != nil {
+		return err
+	}
+	if id != idEBML {
+		return ErrUnsupportedFormat
+	}
+
+	header, err := readBody(reader, size)
+	if err != nil {
+		return err
+	}
+
+	info.Container = "matroska"
+	err = eachElement(header, func(id uint32, body []byte) error {
+		if id == idDocType && string(body) == "webm" {
+			info.Container = "webm"
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	//2. Segment holds Info & Tracks, they are written before clusters
+	id, _, _, err = readElementHeader(reader)
+	if err != nil {
+		return err
+	}
+	if id != idSegment {
+		return errors.New("matroska segment not found")
+	}
+
+	foundInfo, foundTracks := false, false
+
+	for !foundInfo || !foundTracks {
+
+		id, size, unknown, err := readElementHeader(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case id == idInfo && !unknown:
+			body, err := readBody(reader, size)
+			if err != nil {
+				return err
+			}
+			err = parseMatroskaInfo(body, info)
+			if err != nil {
+				return err
+			}
+			foundInfo = true
+		case id == idTracks && !unknown:
+			body, err := readBody(reader, size)
+			if err != nil {
+				return err
+			}
+			err = parseMatroskaTracks(body, info)
+			if err != nil {
+				return err
+			}
+			foundTracks = true
+		case unknown:
+			//Live streams have clusters of unknown size, nothing can be skipped past them
+			return checkMatroskaInfo(foundInfo)
+		default:
+			err = skip(reader, size)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return checkMatroskaInfo(foundInfo)
+}
+
+func checkMatroskaInfo(foundInfo bool) error {
+	if !foundInfo {
+		return errors.New("matroska segment info not found")
+	}
+	return nil
+}
+
+func readElementHeader(reader io.ByteReader) (uint32, int64, bool, error) {
+
+	id, err := readElementID(reader)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	size, unknown, err := readElementSize(reader)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return id, size, unknown, err
+}
+
+//Iterate elements inside an in-memory element body
+func eachElement(data []byte, handle func(id uint32, body []byte) error) error {
+
+	reader := &sliceReader{data: data}
+
+	for reader.offset < len(data) {
+
+		id, size, unknown, err := readElementHeader(reader)
+		if err != nil {
+			return err
+		}
+
+		if unknown || size > int64(len(data)-reader.offset) {
+			return errors.New("invalid matroska element size")
+		}
+
+		err = handle(id, data[reader.offset:reader.offset+int(size)])
+		if err != nil {
+			return err
+		}
+
+		reader.offset += int(size)
+	}
+
+	return nil
+}
+
+type sliceReader struct {
+	data   []byte
+	offset int
+}
+
+func (s *sliceReader) ReadByte() (byte, error) {
+	if s.offset >= len(s.data) {
+		return 0, io.EOF
+	}
+	s.offset++
+	return s.data[s.offset-1], nil
+}
+
+func readUint(body []byte) uint64 {
+	var value uint64
+	for _, b := range body {
+		value = value<<8 | uint64(b)
+	}
+	return value
+}
+
+func readFloat(body []byte) float64 {
+	switch len(body) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(body)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(body))
+	}
+	return 0
+}
+
+func parseMatroskaInfo(body []byte, info *Info) error {
+
+	timecodeScale := uint64(1000000)
+	var duration float64
+
+	err := eachElement(body, func(id uint32, body []byte) error {
+		switch id {
+		case idTimecodeScale:
+			timecodeScale = readUint(body)
+		case idDuration:
+			duration = readFloat(body)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	info.Duration = time.Duration(duration * float64(timecodeScale))
+
+	return nil
+}
+
+func parseMatroskaTracks(body []byte, info *Info) error {
+
+	return eachElement(body, func(id uint32, body []byte) error {
+
+		if id != idTrackEntry {
+			return nil
+		}
+
+		var trackType, defaultDuration uint64
+		var codec string
+		var width, height int
+
+		err := eachElement(body, func(id uint32, body []byte) error {
+			switch id {
+			case idTrackType:
+				trackType = readUint(body)
+			case idCodecID:
+				codec = strings.TrimRight(string(body), "\x00")
+			case idDefaultDuration:
+				defaultDuration = readUint(body)
+			case idVideo:
+				return eachElement(body, func(id uint32, body []byte) error {
+					switch id {
+					case idPixelWidth:
+						width = int(readUint(body))
+					case idPixelHeight:
+						height = int(readUint(body))
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		//Only the first video & audio tracks are described
+		switch trackType {
+		case matroskaTrackVideo:
+			if info.VideoCodec != "" {
+				return nil
+			}
+			info.VideoCodec = matroskaCodecName(codec)
+			info.Width = width
+			info.Height = height
+			if defaultDuration > 0 {
+				info.FrameRate = float64(time.Second) / float64(defaultDuration)
+			}
+		case matroskaTrackAudio:
+			if info.AudioCodec == "" {
+				info.AudioCodec = matroskaCodecName(codec)
+			}
+		}
+
+		return nil
+	})
+}
+
+func matroskaCodecName(codecId string) string {
+	if name, ok := matroskaCodecs[codecId]; ok {
+		return name
+	}
+	return codecId
+}
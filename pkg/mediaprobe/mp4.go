@@ -0,0 +1,284 @@
+package mediaprobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+//Top level boxes which may start an ISO base media (MP4/MOV) file
+var mp4TopLevelBoxes = []string{"ftyp", "moov", "mdat", "free", "skip", "wide", "pnot", "uuid"}
+
+var mp4Codecs = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"av01": "av1",
+	"mp4v": "mpeg4",
+	"mp4a": "aac",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+}
+
+func isMp4Box(boxType string) bool {
+	for _, box := range mp4TopLevelBoxes {
+		if box == boxType {
+			return true
+		}
+	}
+	return false
+}
+
+func probeMp4(reader io.Reader, info *Info) error {
+
+	info.Container = "mp4"
+	header := make([]byte, 16)
+
+	for {
+		//1. Box header, size 1 means 64 bit size follows & size 0 means box extends to the end
+		_, err := io.ReadFull(reader, header[:8])
+		if err == io.EOF {
+			return errors.New("moov box not found")
+		}
+		if err != nil {
+			return err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+
+		if size == 1 {
+			_, err = io.ReadFull(reader, header[8:16])
+			if err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if size != 0 && size < headerSize {
+			return errors.New("invalid mp4 box size")
+		}
+
+		//2. Only ftyp & moov are parsed, moov may come after mdat
+		switch boxType {
+		case "ftyp":
+			body, err := readBody(reader, size-headerSize)
+			if err != nil {
+				return err
+			}
+			if len(body) >= 4 && string(body[:4]) == "qt  " {
+				info.Container = "mov"
+			}
+		case "moov":
+			var body []byte
+			if size == 0 {
+				body, err = ioutil.ReadAll(io.LimitReader(reader, maxHeaderSize))
+			} else {
+				body, err = readBody(reader, size-headerSize)
+			}
+			if err != nil {
+				return err
+			}
+			return parseMoov(body, info)
+		default:
+			if size == 0 {
+				return errors.New("moov box not found")
+			}
+			err = skip(reader, size-headerSize)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+//Iterate boxes inside an in-memory box body
+func eachBox(data []byte, handle func(boxType string, body []byte) error) error {
+
+	for len(data) >= 8 {
+
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		boxType := string(data[4:8])
+		headerSize := uint64(8)
+
+		if size == 1 {
+			if len(data) < 16 {
+				return errors.New("invalid mp4 box size")
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		} else if size == 0 {
+			size = uint64(len(data))
+		}
+
+		if size < headerSize || size > uint64(len(data)) {
+			return errors.New("invalid mp4 box size")
+		}
+
+		err := handle(boxType, data[headerSize:size])
+		if err != nil {
+			return err
+		}
+
+		data = data[size:]
+	}
+
+	return nil
+}
+
+//Read timescale & duration of mvhd or mdhd full box
+func parseTimescaleDuration(body []byte) (uint32, uint64, error) {
+
+	if len(body) < 4 {
+		return 0, 0, errors.New("invalid mp4 header box")
+	}
+
+	if body[0] == 1 {
+		if len(body) < 32 {
+			return 0, 0, errors.New("invalid mp4 header box")
+		}
+		return binary.BigEndian.Uint32(body[20:24]), binary.BigEndian.Uint64(body[24:32]), nil
+	}
+
+	if len(body) < 20 {
+		return 0, 0, errors.New("invalid mp4 header box")
+	}
+	return binary.BigEndian.Uint32(body[12:16]), uint64(binary.BigEndian.Uint32(body[16:20])), nil
+}
+
+func scaledDuration(timescale uint32, duration uint64) time.Duration {
+	if timescale == 0 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+func parseMoov(moov []byte, info *Info) error {
+
+	return eachBox(moov, func(boxType string, body []byte) error {
+		switch boxType {
+		case "mvhd":
+			timescale, duration, err := parseTimescaleDuration(body)
+			if err != nil {
+				return err
+			}
+			info.Duration = scaledDuration(timescale, duration)
+		case "trak":
+			return parseTrak(body, info)
+		}
+		return nil
+	})
+}
+
+type mp4Track struct {
+	handler     string
+	codec       string
+	width       int
+	height      int
+	duration    time.Duration
+	sampleCount uint64
+}
+
+func parseTrak(trak []byte, info *Info) error {
+
+	var track mp4Track
+
+	err := eachBox(trak, func(boxType string, body []byte) error {
+		if boxType != "mdia" {
+			return nil
+		}
+		return eachBox(body, func(boxType string, body []byte) error {
+			switch boxType {
+			case "mdhd":
+				timescale, duration, err := parseTimescaleDuration(body)
+				if err != nil {
+					return err
+				}
+				track.duration = scaledDuration(timescale, duration)
+			case "hdlr":
+				if len(body) >= 12 {
+					track.handler = string(body[8:12])
+				}
+			case "minf":
+				return eachBox(body, func(boxType string, body []byte) error {
+					if boxType != "stbl" {
+						return nil
+					}
+					return parseStbl(body, &track)
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	//Only the first video & audio tracks are described
+	switch track.handler {
+	case "vide":
+		if info.VideoCodec != "" {
+			return nil
+		}
+		info.VideoCodec = mp4CodecName(track.codec)
+		info.Width = track.width
+		info.Height = track.height
+		if track.duration > 0 && track.sampleCount > 0 {
+			info.FrameRate = float64(track.sampleCount) / track.duration.Seconds()
+		}
+	case "soun":
+		if info.AudioCodec == "" {
+			info.AudioCodec = mp4CodecName(track.codec)
+		}
+	}
+
+	return nil
+}
+
+func parseStbl(stbl []byte, track *mp4Track) error {
+
+	return eachBox(stbl, func(boxType string, body []byte) error {
+		switch boxType {
+		case "stsd":
+			//Full box header + entry count, then the first sample entry
+			if len(body) < 16 {
+				return nil
+			}
+			entry := body[8:]
+			track.codec = string(entry[4:8])
+
+			//Visual sample entry keeps width & height after 24 bytes of reserved & pre-defined fields
+			if len(entry) >= 36 {
+				track.width = int(binary.BigEndian.Uint16(entry[32:34]))
+				track.height = int(binary.BigEndian.Uint16(entry[34:36]))
+			}
+		case "stts":
+			if len(body) < 8 {
+				return nil
+			}
+			entries := binary.BigEndian.Uint32(body[4:8])
+			for i := uint32(0); i < entries && len(body) >= int(16+i*8); i++ {
+				track.sampleCount += uint64(binary.BigEndian.Uint32(body[8+i*8 : 12+i*8]))
+			}
+		}
+		return nil
+	})
+}
+
+func mp4CodecName(fourcc string) string {
+	if name, ok := mp4Codecs[fourcc]; ok {
+		return name
+	}
+	return strings.TrimSpace(fourcc)
+}
@@ -0,0 +1,98 @@
+package mediaprobe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+//Elements kept in memory while probing (moov box, matroska headers) may not exceed this size
+const maxHeaderSize = 64 * 1024 * 1024
+
+var ErrUnsupportedFormat = errors.New("unsupported media format")
+
+type Info struct {
+	Container  string
+	Duration   time.Duration
+	Width      int
+	Height     int
+	FrameRate  float64
+	VideoCodec string
+	AudioCodec string
+	Bitrate    int64
+	Size       int64
+}
+
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.reader.Read(b)
+	c.count += int64(n)
+	return n, err
+}
+
+//Probe reads media headers sequentially, payload (mdat, clusters) is skipped so it works on streams.
+//The reader is always consumed until EOF so size & bitrate can be computed.
+func Probe(reader io.Reader) (Info, error) {
+
+	var info Info
+	var err error
+
+	counter := &countingReader{reader: reader}
+	buffered := bufio.NewReader(counter)
+
+	head, _ := buffered.Peek(8)
+
+	switch {
+	case len(head) >= 4 && binary.BigEndian.Uint32(head) == idEBML:
+		err = probeMatroska(buffered, &info)
+	case len(head) >= 8 && isMp4Box(string(head[4:8])):
+		err = probeMp4(buffered, &info)
+	default:
+		err = ErrUnsupportedFormat
+	}
+
+	_, drainErr := io.Copy(ioutil.Discard, buffered)
+	if err == nil {
+		err = drainErr
+	}
+
+	info.Size = counter.count
+
+	if info.Bitrate == 0 && info.Duration > 0 {
+		info.Bitrate = int64(float64(info.Size*8) / info.Duration.Seconds())
+	}
+
+	return info, err
+}
+
+//Read exactly size bytes of an element that has to be parsed
+func readBody(reader io.Reader, size int64) ([]byte, error) {
+
+	if size < 0 || size > maxHeaderSize {
+		return nil, errors.New("media header is too large")
+	}
+
+	body := make([]byte, size)
+	_, err := io.ReadFull(reader, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func skip(reader io.Reader, size int64) error {
+
+	skipped, err := io.CopyN(ioutil.Discard, reader, size)
+	if err == io.EOF && skipped < size {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
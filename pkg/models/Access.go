@@ -0,0 +1,23 @@
+package models
+
+//Actions authorized by the access policy, rules may match every action of a resource with "course:*" or every action with "*"
+const (
+	ActionCourseRead        = "course:read"
+	ActionCoursePreview     = "course:preview"
+	ActionCourseCreate      = "course:create"
+	ActionCourseImpersonate = "course:impersonate"
+	ActionCourseUpdate      = "course:update"
+	ActionCoursePublish     = "course:publish"
+	ActionCourseDelete      = "course:delete"
+	ActionCourseRestore     = "course:restore"
+	ActionCourseShare       = "course:share"
+	ActionCourseTransfer    = "course:transfer"
+	ActionMaterialCreate    = "material:create"
+	ActionMaterialUpdate    = "material:update"
+	ActionMaterialDelete    = "material:delete"
+	ActionMaterialRestore   = "material:restore"
+	ActionAuditRead         = "audit:read"
+)
+
+//Condition of access policy rules which apply to every course, other conditions are the course role the requester needs at least
+const AccessConditionAny = "any"
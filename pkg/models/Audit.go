@@ -0,0 +1,184 @@
+package models
+
+import (
+	"encoding/json"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"sort"
+	"time"
+)
+
+//Outcome of an audited attempt, events of mutations which were made have no outcome
+const (
+	AuditOutcomeAllowed = "allowed"
+	AuditOutcomeDenied  = "denied"
+)
+
+//Actor of mutations made by background jobs, their events have no request id
+const AuditActorSystem = "system"
+
+//Audited mutations which have no access policy action of their own
+const (
+	AuditActionScheduleRelease    = "course:schedule-release"
+	AuditActionCancelRelease      = "course:cancel-release"
+	AuditActionRollback           = "course:rollback"
+	AuditActionReorderMaterials   = "material:reorder"
+	AuditActionPutCaption         = "caption:put"
+	AuditActionDeleteCaption      = "caption:delete"
+	AuditActionCreateSection      = "section:create"
+	AuditActionUpdateSection      = "section:update"
+	AuditActionDeleteSection      = "section:delete"
+	AuditActionPlaceMaterials     = "section:add-materials"
+	AuditActionUnplaceMaterials   = "section:remove-materials"
+	AuditActionInviteCollaborator = "collaborator:invite"
+	AuditActionUpdateCollaborator = "collaborator:update"
+	AuditActionRemoveCollaborator = "collaborator:remove"
+	AuditActionScheduledPublish   = "course:scheduled-publish"
+	AuditActionScheduledUnpublish = "course:scheduled-unpublish"
+	AuditActionPurgeCourse        = "course:purge"
+	AuditActionPurgeMaterial      = "material:purge"
+)
+
+//AuditEvent records who attempted or changed what, events are only ever appended
+type AuditEvent struct {
+	ID         primitive.ObjectID  `json:"id" bson:"_id"`
+	Actor      string              `json:"actor" bson:"actor"`
+	ActorRole  string              `json:"actor_role,omitempty" bson:"actor_role"`
+	Action     string              `json:"action" bson:"action"`
+	OnBehalfOf string              `json:"on_behalf_of,omitempty" bson:"on_behalf_of,omitempty"`
+	CourseID   *primitive.ObjectID `json:"course_id,omitempty" bson:"course_id,omitempty"`
+	MaterialID *primitive.ObjectID `json:"material_id,omitempty" bson:"material_id,omitempty"`
+	Changes    []FieldChange       `json:"changes,omitempty" bson:"changes,omitempty"`
+	Outcome    string              `json:"outcome,omitempty" bson:"outcome,omitempty"`
+	RequestID  string              `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+}
+
+//Value of a field before & after the change, either is nil when the field was added or removed
+type FieldChange struct {
+	Field string      `json:"field" bson:"field"`
+	From  interface{} `json:"from,omitempty" bson:"from,omitempty"`
+	To    interface{} `json:"to,omitempty" bson:"to,omitempty"`
+}
+
+//Audit events matching every given criteria, empty criteria match every event
+type AuditFilter struct {
+	CourseID *primitive.ObjectID
+	Actor    string
+	From     *time.Time
+	To       *time.Time
+}
+
+//Fields which are never audited, derived values & bookkeeping change along with the fields that matter
+var unauditedFields = map[string]bool{
+	"updated_at":     true,
+	"created_at":     true,
+	"version":        true,
+	"url":            true,
+	"image_url":      true,
+	"playlist_url":   true,
+	"poster_url":     true,
+	"thumbnails_url": true,
+}
+
+//Fields of after which differ from before, as they're serialized in responses.
+//Either may be a value, a pointer or fields taken earlier by AuditFields, nil diffs against no fields at all
+func DiffFields(before interface{}, after interface{}, ignored ...string) []FieldChange {
+
+	beforeFields := AuditFields(before)
+	afterFields := AuditFields(after)
+
+	skipped := make(map[string]bool)
+	for _, field := range ignored {
+		skipped[field] = true
+	}
+
+	names := make(map[string]bool)
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	var changes []FieldChange
+	for name := range names {
+		if skipped[name] || unauditedFields[name] || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: name, From: beforeFields[name], To: afterFields[name]})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+
+	return changes
+}
+
+//Changes of course fields, materials are compared one by one & their fields are prefixed with "materials.<material id>."
+//Sections, trash, release history, revisions & collaborators are audited by their own events
+func DiffCourse(before interface{}, after interface{}) []FieldChange {
+
+	beforeFields := AuditFields(before)
+	afterFields := AuditFields(after)
+
+	changes := DiffFields(beforeFields, afterFields, "materials", "sections", "trashed_materials", "release_history", "collaborators", "published_revision", "latest_revision")
+
+	beforeMaterials := auditMaterials(beforeFields["materials"])
+	afterMaterials := auditMaterials(afterFields["materials"])
+
+	var materialIds []string
+	for id := range beforeMaterials {
+		materialIds = append(materialIds, id)
+	}
+	for id := range afterMaterials {
+		if _, found := beforeMaterials[id]; !found {
+			materialIds = append(materialIds, id)
+		}
+	}
+	sort.Strings(materialIds)
+
+	for _, id := range materialIds {
+		for _, change := range DiffFields(beforeMaterials[id], afterMaterials[id], "material_id") {
+			change.Field = "materials." + id + "." + change.Field
+			changes = append(changes, change)
+		}
+	}
+
+	return changes
+}
+
+//Fields of the value as they're serialized in responses
+func AuditFields(value interface{}) map[string]interface{} {
+
+	if fields, ok := value.(map[string]interface{}); ok {
+		return fields
+	}
+
+	fields := make(map[string]interface{})
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil()) {
+		return fields
+	}
+
+	document, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+
+	_ = json.Unmarshal(document, &fields)
+	return fields
+}
+
+func auditMaterials(value interface{}) map[string]map[string]interface{} {
+
+	materials := make(map[string]map[string]interface{})
+
+	list, _ := value.([]interface{})
+	for _, item := range list {
+		if material, ok := item.(map[string]interface{}); ok {
+			id, _ := material["material_id"].(string)
+			materials[id] = material
+		}
+	}
+
+	return materials
+}
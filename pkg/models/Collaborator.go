@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+//Roles on a course, the owner is the user the course belongs to & collaborators are either editors or viewers
+const (
+	CourseRoleOwner  = "owner"
+	CourseRoleEditor = "editor"
+	CourseRoleViewer = "viewer"
+)
+
+//Every role includes the rights of the roles ranked below it
+var courseRoleRanks = map[string]int{
+	CourseRoleViewer: 1,
+	CourseRoleEditor: 2,
+	CourseRoleOwner:  3,
+}
+
+type Collaborator struct {
+	UserID    int64      `json:"user_id" bson:"user_id"`
+	Role      string     `json:"role" bson:"role"`
+	InvitedBy string     `json:"invited_by,omitempty" bson:"invited_by"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" bson:"created_at"`
+}
+
+//Whether the role is one of the course roles
+func IsCourseRole(role string) bool {
+	return courseRoleRanks[role] > 0
+}
+
+//Whether the role grants at least the rights of the required role, empty role grants nothing
+func CourseRoleIncludes(role string, required string) bool {
+	return courseRoleRanks[role] > 0 && courseRoleRanks[role] >= courseRoleRanks[required]
+}
+
+//Role of the user on the course, empty when the user neither owns nor collaborates on it
+func (c *Course) CourseRole(userId int64) string {
+
+	if c.UserID == userId {
+		return CourseRoleOwner
+	}
+
+	if collaborator := c.FindCollaborator(userId); collaborator != nil {
+		return collaborator.Role
+	}
+
+	return ""
+}
+
+func (c *Course) FindCollaborator(userId int64) *Collaborator {
+	for i := range c.Collaborators {
+		if c.Collaborators[i].UserID == userId {
+			return &c.Collaborators[i]
+		}
+	}
+	return nil
+}
+
+//Remove the collaborator, false when the user isn't one
+func (c *Course) RemoveCollaborator(userId int64) bool {
+
+	for i := range c.Collaborators {
+		if c.Collaborators[i].UserID == userId {
+			c.Collaborators = append(c.Collaborators[:i:i], c.Collaborators[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+//Hand the course over to another user, the previous owner stays on as editor
+func (c *Course) TransferOwnership(userId int64, transferredBy string, now time.Time) {
+
+	previous := c.UserID
+
+	c.RemoveCollaborator(userId)
+	c.UserID = userId
+	c.Collaborators = append(c.Collaborators, Collaborator{
+		UserID:    previous,
+		Role:      CourseRoleEditor,
+		InvitedBy: transferredBy,
+		UpdatedAt: &now,
+		CreatedAt: &now,
+	})
+}
@@ -1,41 +1,204 @@
 package models
 
 import (
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"path"
+	"strings"
 	"sync"
 	"time"
 )
 
 type Course struct {
 	sync.Mutex
-	ID            primitive.ObjectID `json:"id" bson:"_id"`
-	UserID        int64              `json:"user_id,omitempty" bson:"user_id"`
-	Name          string             `json:"name,omitempty" bson:"name"`
-	CourseID      string             `json:"course_id,omitempty" bson:"course_id"`
-	Description   string             `json:"description,omitempty" bson:"description"`
-	ImageUrl      string             `json:"image_url,omitempty" bson:"image_url"`
-	ImageKey      string             `json:"image_key,omitempty" bson:"image_key"`
-	Price         float32            `json:"price,omitempty" bson:"price"`
-	TotalDuration time.Duration      `json:"total_duration,omitempty" bson:"total_duration"`
-	IsReleased    bool               `json:"is_released,omitempty" bson:"is_released"`
-	Materials     []Material         `json:"materials,omitempty" bson:"materials"`
-	ReleasedAt    *time.Time         `json:"released_at,omitempty" bson:"released_at"`
-	UpdatedAt     *time.Time         `json:"updated_at,omitempty" bson:"updated_at"`
-	CreatedAt     *time.Time         `json:"created_at,omitempty" bson:"created_at"`
-	DeletedAt     *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at"`
+	ID                primitive.ObjectID `json:"id" bson:"_id"`
+	UserID            int64              `json:"user_id,omitempty" bson:"user_id"`
+	Name              string             `json:"name,omitempty" bson:"name"`
+	CourseID          string             `json:"course_id,omitempty" bson:"course_id"`
+	Description       string             `json:"description,omitempty" bson:"description"`
+	ImageUrl          string             `json:"image_url,omitempty" bson:"-"`
+	ImageKey          string             `json:"image_key,omitempty" bson:"image_key"`
+	Price             float32            `json:"price,omitempty" bson:"price"`
+	TotalDuration     time.Duration      `json:"total_duration,omitempty" bson:"total_duration"`
+	IsReleased        bool               `json:"is_released,omitempty" bson:"is_released"`
+	Materials         []Material         `json:"materials,omitempty" bson:"materials"`
+	Sections          []Section          `json:"sections,omitempty" bson:"sections,omitempty"`
+	TrashedMaterials  []Material         `json:"trashed_materials,omitempty" bson:"trashed_materials,omitempty"`
+	ReleasedAt        *time.Time         `json:"released_at,omitempty" bson:"released_at"`
+	PublishAt         *time.Time         `json:"publish_at,omitempty" bson:"publish_at"`
+	UnpublishAt       *time.Time         `json:"unpublish_at,omitempty" bson:"unpublish_at"`
+	ReleaseHistory    []ReleaseEvent     `json:"release_history,omitempty" bson:"release_history,omitempty"`
+	Collaborators     []Collaborator     `json:"collaborators,omitempty" bson:"collaborators,omitempty"`
+	PublishedRevision int                `json:"published_revision,omitempty" bson:"published_revision,omitempty"`
+	LatestRevision    int                `json:"latest_revision,omitempty" bson:"latest_revision,omitempty"`
+	Version           int                `json:"version" bson:"version"`
+	UpdatedAt         *time.Time         `json:"updated_at,omitempty" bson:"updated_at"`
+	CreatedAt         *time.Time         `json:"created_at,omitempty" bson:"created_at"`
+	DeletedAt         *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at"`
 }
 
 type Material struct {
-	MaterialID  primitive.ObjectID `json:"material_id" bson:"material_id"`
-	Name        string             `json:"name" bson:"name"`
-	Duration    time.Duration      `json:"duration" bson:"duration"`
-	Description string             `json:"description" bson:"description"`
-	Order       int                `json:"order" bson:"order"`
-	Url         string             `json:"url" bson:"url"`
-	Key         string             `json:"key" bson:"key"`
-	UpdatedAt   *time.Time         `json:"updated_at,omitempty" bson:"updated_at"`
-	CreatedAt   *time.Time         `json:"created_at,omitempty" bson:"created_at"`
-	DeletedAt   *time.Time         `json:"deleted_at,omitempty" bson:"deleted_at"`
+	MaterialID    primitive.ObjectID  `json:"material_id" bson:"material_id"`
+	Name          string              `json:"name" bson:"name"`
+	Kind          string              `json:"kind" bson:"kind"`
+	Duration      time.Duration       `json:"duration" bson:"duration"`
+	MediaInfo     *MediaInfo          `json:"media_info,omitempty" bson:"media_info,omitempty"`
+	Description   string              `json:"description" bson:"description"`
+	Order         int                 `json:"order" bson:"order"`
+	SectionID     *primitive.ObjectID `json:"section_id,omitempty" bson:"section_id,omitempty"`
+	Url           string              `json:"url" bson:"-"`
+	Key           string              `json:"key" bson:"key"`
+	Status        string              `json:"status,omitempty" bson:"status"`
+	StatusError   string              `json:"status_error,omitempty" bson:"status_error"`
+	PlaylistKey   string              `json:"playlist_key,omitempty" bson:"playlist_key"`
+	PlaylistUrl   string              `json:"playlist_url,omitempty" bson:"-"`
+	PosterKey     string              `json:"poster_key,omitempty" bson:"poster_key"`
+	PosterUrl     string              `json:"poster_url,omitempty" bson:"-"`
+	ThumbnailsKey string              `json:"thumbnails_key,omitempty" bson:"thumbnails_key"`
+	ThumbnailsUrl string              `json:"thumbnails_url,omitempty" bson:"-"`
+	Captions      []Caption           `json:"captions,omitempty" bson:"captions,omitempty"`
+	LinkUrl       string              `json:"link_url,omitempty" bson:"link_url,omitempty"`
+	Content       string              `json:"content,omitempty" bson:"content,omitempty"`
+	Quiz          *Quiz               `json:"quiz,omitempty" bson:"quiz,omitempty"`
+	UpdatedAt     *time.Time          `json:"updated_at,omitempty" bson:"updated_at"`
+	CreatedAt     *time.Time          `json:"created_at,omitempty" bson:"created_at"`
+	DeletedAt     *time.Time          `json:"deleted_at,omitempty" bson:"deleted_at"`
+}
+
+//Caption track of a material, stored as WebVTT regardless of the uploaded format
+type Caption struct {
+	Language  string     `json:"language" bson:"language"`
+	Label     string     `json:"label,omitempty" bson:"label"`
+	Key       string     `json:"key" bson:"key"`
+	Url       string     `json:"url" bson:"-"`
+	CueCount  int        `json:"cue_count" bson:"cue_count"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty" bson:"updated_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" bson:"created_at"`
+}
+
+func (m *Material) FindCaption(language string) *Caption {
+	for i := range m.Captions {
+		if m.Captions[i].Language == language {
+			return &m.Captions[i]
+		}
+	}
+	return nil
+}
+
+//Storage objects referenced by the material, video & caption tracks
+func (m Material) StorageKeys() []string {
+
+	var keys []string
+
+	if m.Key != "" {
+		keys = append(keys, m.Key)
+	}
+
+	for _, caption := range m.Captions {
+		if caption.Key != "" {
+			keys = append(keys, caption.Key)
+		}
+	}
+
+	return keys
+}
+
+//Attach probed media info, duration follows the probed file
+func (m *Material) SetMediaInfo(info *MediaInfo) {
+	m.MediaInfo = info
+	m.Duration = 0
+	if info != nil {
+		m.Duration = info.Duration
+	}
+}
+
+//Material video processing status
+const (
+	MaterialStatusPending    = "pending"
+	MaterialStatusProcessing = "processing"
+	MaterialStatusReady      = "ready"
+	MaterialStatusFailed     = "failed"
+)
+
+//Materials stored before kinds existed are videos
+func (m Material) MaterialKind() string {
+	if m.Kind == "" {
+		return MaterialKindVideo
+	}
+	return m.Kind
+}
+
+//Duration of materials which aren't probed, estimated from their content
+func (m Material) EstimatedDuration() time.Duration {
+
+	switch m.MaterialKind() {
+	case MaterialKindArticle:
+		words := len(strings.Fields(m.Content))
+		return time.Duration(words) * time.Minute / ArticleWordsPerMinute
+	case MaterialKindQuiz:
+		if m.Quiz != nil {
+			return time.Duration(len(m.Quiz.Questions)) * QuizTimePerQuestion
+		}
+	}
+
+	return 0
+}
+
+//Materials without processing status are served progressively from their raw file, kinds without file are always available
+func (m Material) IsPlayable() bool {
+	if !MaterialKindHasFile(m.MaterialKind()) {
+		return true
+	}
+	if m.Status == "" {
+		return m.Key != ""
+	}
+	return m.Status == MaterialStatusReady
+}
+
+//Storage prefix holding every HLS rendition of the material
+func (m Material) HlsPrefix() string {
+	if m.PlaylistKey == "" {
+		return ""
+	}
+	return path.Dir(m.PlaylistKey) + "/"
+}
+
+//Storage prefix holding poster, sprite sheet & thumbnail track of the material
+func (m Material) PreviewPrefix() string {
+	if m.PosterKey != "" {
+		return path.Dir(m.PosterKey) + "/"
+	}
+	if m.ThumbnailsKey != "" {
+		return path.Dir(m.ThumbnailsKey) + "/"
+	}
+	return ""
+}
+
+//Every storage prefix owned entirely by the material
+func (m Material) StoragePrefixes() []string {
+
+	var prefixes []string
+
+	if prefix := m.HlsPrefix(); prefix != "" {
+		prefixes = append(prefixes, prefix)
+	}
+
+	if prefix := m.PreviewPrefix(); prefix != "" {
+		prefixes = append(prefixes, prefix)
+	}
+
+	return prefixes
+}
+
+//CourseVersion identifies the stored state of a course, every write to the course increases its version
+type CourseVersion struct {
+	ID      primitive.ObjectID `json:"id"`
+	Version int                `json:"version"`
+}
+
+//Entity tag of the course version, as sent in ETag & expected in If-Match headers
+func (v CourseVersion) ETag() string {
+	return fmt.Sprintf("\"%d\"", v.Version)
 }
 
 type Pagination struct {
@@ -58,3 +221,39 @@ func (c *Course) SubTotalDuration(duration time.Duration) {
 	c.TotalDuration -= duration
 	c.Unlock()
 }
+
+//Every storage object referenced by the course, including soft deleted materials
+func (c *Course) StorageKeys() []string {
+
+	var keys []string
+
+	if c.ImageKey != "" {
+		keys = append(keys, c.ImageKey)
+	}
+
+	for _, material := range c.Materials {
+		keys = append(keys, material.StorageKeys()...)
+	}
+
+	for _, material := range c.TrashedMaterials {
+		keys = append(keys, material.StorageKeys()...)
+	}
+
+	return keys
+}
+
+//Every storage prefix owned entirely by the course, such as HLS renditions & previews
+func (c *Course) StoragePrefixes() []string {
+
+	var prefixes []string
+
+	for _, material := range c.Materials {
+		prefixes = append(prefixes, material.StoragePrefixes()...)
+	}
+
+	for _, material := range c.TrashedMaterials {
+		prefixes = append(prefixes, material.StoragePrefixes()...)
+	}
+
+	return prefixes
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Material kinds, materials stored before kinds existed are videos
+const (
+	MaterialKindVideo    = "video"
+	MaterialKindAudio    = "audio"
+	MaterialKindDocument = "document"
+	MaterialKindLink     = "link"
+	MaterialKindArticle  = "article"
+	MaterialKindQuiz     = "quiz"
+)
+
+var MaterialKinds = []string{
+	MaterialKindVideo,
+	MaterialKindAudio,
+	MaterialKindDocument,
+	MaterialKindLink,
+	MaterialKindArticle,
+	MaterialKindQuiz,
+}
+
+//Documents are accepted by their extension, PDFs are also checked by their signature
+var DocumentExtensions = []string{".pdf", ".doc", ".docx", ".ppt", ".pptx", ".xls", ".xlsx", ".odt", ".odp", ".ods"}
+
+//Reading speed & time per question used when no duration is given
+const (
+	ArticleWordsPerMinute = 200
+	QuizTimePerQuestion   = time.Minute
+)
+
+func IsMaterialKind(kind string) bool {
+	for _, materialKind := range MaterialKinds {
+		if materialKind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+//Kinds whose content is a file in storage
+func MaterialKindHasFile(kind string) bool {
+	return kind == MaterialKindVideo || kind == MaterialKindAudio || kind == MaterialKindDocument
+}
+
+//Kinds whose duration is probed from their file
+func MaterialKindIsMedia(kind string) bool {
+	return kind == MaterialKindVideo || kind == MaterialKindAudio
+}
+
+type Quiz struct {
+	PassingScore int            `json:"passing_score" bson:"passing_score"`
+	Questions    []QuizQuestion `json:"questions" bson:"questions"`
+}
+
+//Answers are indexes of the correct options
+type QuizQuestion struct {
+	Question    string   `json:"question" bson:"question"`
+	Options     []string `json:"options" bson:"options"`
+	Answers     []int    `json:"answers,omitempty" bson:"answers"`
+	Explanation string   `json:"explanation,omitempty" bson:"explanation"`
+}
+
+func (q Quiz) Validate() error {
+
+	if len(q.Questions) == 0 {
+		return errors.New("Quiz requires at least one question")
+	}
+
+	if q.PassingScore < 0 || q.PassingScore > 100 {
+		return errors.New("Quiz passing score must be between 0 and 100")
+	}
+
+	for i, question := range q.Questions {
+
+		if strings.TrimSpace(question.Question) == "" {
+			return errors.New(fmt.Sprintf("Quiz question %v has no text", i+1))
+		}
+
+		if len(question.Options) < 2 {
+			return errors.New(fmt.Sprintf("Quiz question %v requires at least two options", i+1))
+		}
+
+		if len(question.Answers) == 0 {
+			return errors.New(fmt.Sprintf("Quiz question %v has no answer", i+1))
+		}
+
+		for _, answer := range question.Answers {
+			if answer < 0 || answer >= len(question.Options) {
+				return errors.New(fmt.Sprintf("Quiz question %v has answer %v which is not one of its options", i+1, answer))
+			}
+		}
+	}
+
+	return nil
+}
+
+//Quiz as students see it, answers & explanations are only shown to owners
+func (q Quiz) WithoutAnswers() *Quiz {
+
+	questions := make([]QuizQuestion, len(q.Questions))
+	for i, question := range q.Questions {
+		questions[i] = QuizQuestion{Question: question.Question, Options: question.Options}
+	}
+
+	return &Quiz{PassingScore: q.PassingScore, Questions: questions}
+}
+
+//Validate uploaded document by its extension, head is the beginning of the file when it's available
+func ValidateDocument(filename string, head []byte) error {
+
+	extension := strings.ToLower(filepath.Ext(filename))
+
+	supported := false
+	for _, documentExtension := range DocumentExtensions {
+		if documentExtension == extension {
+			supported = true
+		}
+	}
+
+	if !supported {
+		return errors.New(fmt.Sprintf("Document %v is not supported, supported documents are %v", filename, strings.Join(DocumentExtensions, ", ")))
+	}
+
+	if extension == ".pdf" && head != nil && !bytes.HasPrefix(head, []byte("%PDF-")) {
+		return errors.New(fmt.Sprintf("Document %v is not a valid PDF", filename))
+	}
+
+	return nil
+}
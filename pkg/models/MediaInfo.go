@@ -0,0 +1,121 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//Technical metadata probed from an uploaded material file, codecs & containers are lowercase short names (h264, aac, mp4)
+type MediaInfo struct {
+	Container  string        `json:"container,omitempty" bson:"container"`
+	Duration   time.Duration `json:"duration" bson:"duration"`
+	Width      int           `json:"width,omitempty" bson:"width"`
+	Height     int           `json:"height,omitempty" bson:"height"`
+	FrameRate  float64       `json:"frame_rate,omitempty" bson:"frame_rate"`
+	VideoCodec string        `json:"video_codec,omitempty" bson:"video_codec"`
+	AudioCodec string        `json:"audio_codec,omitempty" bson:"audio_codec"`
+	Bitrate    int64         `json:"bitrate,omitempty" bson:"bitrate"`
+	FileSize   int64         `json:"file_size,omitempty" bson:"file_size"`
+}
+
+//Upload policy for material files, zero values don't restrict anything
+type MediaPolicy struct {
+	MinWidth           int
+	MinHeight          int
+	AllowedContainers  []string
+	AllowedVideoCodecs []string
+	AllowedAudioCodecs []string
+}
+
+//File rejected by the media policy
+type MediaPolicyError struct {
+	Reason string
+}
+
+func (e *MediaPolicyError) Error() string {
+	return e.Reason
+}
+
+//Validate media info against the policy, files which couldn't be probed are passed as nil
+func (p MediaPolicy) Validate(info *MediaInfo) error {
+
+	if info == nil {
+		if p.IsEmpty() {
+			return nil
+		}
+		return &MediaPolicyError{Reason: "Media information of the file can't be read"}
+	}
+
+	if info.Width < p.MinWidth || info.Height < p.MinHeight {
+		return &MediaPolicyError{Reason: fmt.Sprintf("Resolution %vx%v is below the minimum of %vx%v", info.Width, info.Height, p.MinWidth, p.MinHeight)}
+	}
+
+	if len(p.AllowedContainers) > 0 && !containsFold(p.AllowedContainers, info.Container) {
+		return &MediaPolicyError{Reason: fmt.Sprintf("Container %v is not allowed, allowed containers are %v", describeFormat(info.Container), strings.Join(p.AllowedContainers, ", "))}
+	}
+
+	if len(p.AllowedVideoCodecs) > 0 && !containsFold(p.AllowedVideoCodecs, info.VideoCodec) {
+		return &MediaPolicyError{Reason: fmt.Sprintf("Video codec %v is not allowed, allowed video codecs are %v", describeFormat(info.VideoCodec), strings.Join(p.AllowedVideoCodecs, ", "))}
+	}
+
+	//Files without audio track are fine
+	if len(p.AllowedAudioCodecs) > 0 && info.AudioCodec != "" && !containsFold(p.AllowedAudioCodecs, info.AudioCodec) {
+		return &MediaPolicyError{Reason: fmt.Sprintf("Audio codec %v is not allowed, allowed audio codecs are %v", info.AudioCodec, strings.Join(p.AllowedAudioCodecs, ", "))}
+	}
+
+	return nil
+}
+
+//Validate media info of a material kind, resolution & video codecs only apply to videos
+func (p MediaPolicy) ValidateKind(kind string, info *MediaInfo) error {
+
+	switch kind {
+	case MaterialKindVideo:
+		return p.Validate(info)
+	case MaterialKindAudio:
+		return p.validateAudio(info)
+	}
+
+	return nil
+}
+
+func (p MediaPolicy) validateAudio(info *MediaInfo) error {
+
+	if info == nil {
+		if len(p.AllowedAudioCodecs) == 0 {
+			return nil
+		}
+		return &MediaPolicyError{Reason: "Media information of the file can't be read"}
+	}
+
+	if info.AudioCodec == "" {
+		return &MediaPolicyError{Reason: "Audio material has no audio track"}
+	}
+
+	if len(p.AllowedAudioCodecs) > 0 && !containsFold(p.AllowedAudioCodecs, info.AudioCodec) {
+		return &MediaPolicyError{Reason: fmt.Sprintf("Audio codec %v is not allowed, allowed audio codecs are %v", info.AudioCodec, strings.Join(p.AllowedAudioCodecs, ", "))}
+	}
+
+	return nil
+}
+
+func (p MediaPolicy) IsEmpty() bool {
+	return p.MinWidth == 0 && p.MinHeight == 0 && len(p.AllowedContainers) == 0 && len(p.AllowedVideoCodecs) == 0 && len(p.AllowedAudioCodecs) == 0
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func describeFormat(format string) string {
+	if format == "" {
+		return "unknown"
+	}
+	return format
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+type OrphanObject struct {
+	Key          string    `json:"key"`
+	Prefix       string    `json:"prefix"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Deleted      bool      `json:"deleted"`
+	Error        string    `json:"error,omitempty"`
+}
+
+type ReaperReport struct {
+	DryRun      bool           `json:"dry_run"`
+	GracePeriod string         `json:"grace_period"`
+	Scanned     int            `json:"scanned"`
+	Referenced  int            `json:"referenced"`
+	Orphans     []OrphanObject `json:"orphans"`
+	OrphanSize  int64          `json:"orphan_size"`
+	Deleted     int            `json:"deleted"`
+	Failed      int            `json:"failed"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  time.Time      `json:"finished_at"`
+}
+
+//StorageReferences collects storage objects still in use, objects under a referenced prefix are in use as well
+type StorageReferences struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+func NewStorageReferences() *StorageReferences {
+	return &StorageReferences{keys: make(map[string]bool)}
+}
+
+func (r *StorageReferences) Add(course *Course) {
+	for _, key := range course.StorageKeys() {
+		r.keys[key] = true
+	}
+	r.prefixes = append(r.prefixes, course.StoragePrefixes()...)
+}
+
+func (r *StorageReferences) AddKey(key string) {
+	r.keys[key] = true
+}
+
+func (r *StorageReferences) IsReferenced(key string) bool {
+	if r.keys[key] {
+		return true
+	}
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//Whether any referenced object or prefix lies under the prefix
+func (r *StorageReferences) IsPrefixReferenced(prefix string) bool {
+	for key := range r.keys {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	for _, referenced := range r.prefixes {
+		if strings.HasPrefix(referenced, prefix) || strings.HasPrefix(prefix, referenced) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+//Release actions recorded in course release history
+const (
+	ReleaseActionPublish   = "publish"
+	ReleaseActionUnpublish = "unpublish"
+)
+
+//Release changes are either made by the owner or by the release scheduler
+const (
+	ReleaseSourceManual   = "manual"
+	ReleaseSourceSchedule = "schedule"
+)
+
+type ReleaseEvent struct {
+	Action      string     `json:"action" bson:"action"`
+	Source      string     `json:"source" bson:"source"`
+	UserID      string     `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty" bson:"scheduled_at,omitempty"`
+	At          time.Time  `json:"at" bson:"at"`
+}
+
+//Scheduled release changes which are due, in the order they have to be applied
+func (c *Course) DueReleases(now time.Time) []ReleaseEvent {
+
+	var events []ReleaseEvent
+
+	if c.PublishAt != nil && !c.PublishAt.After(now) {
+		events = append(events, ReleaseEvent{Action: ReleaseActionPublish, Source: ReleaseSourceSchedule, ScheduledAt: c.PublishAt, At: now})
+	}
+	if c.UnpublishAt != nil && !c.UnpublishAt.After(now) {
+		events = append(events, ReleaseEvent{Action: ReleaseActionUnpublish, Source: ReleaseSourceSchedule, ScheduledAt: c.UnpublishAt, At: now})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ScheduledAt.Before(*events[j].ScheduledAt)
+	})
+
+	return events
+}
+
+//Change release state manually, pending schedule of the same action is dropped
+func (c *Course) SetReleased(released bool, userId string, now time.Time) {
+
+	if c.IsReleased == released {
+		return
+	}
+
+	event := ReleaseEvent{Action: ReleaseActionUnpublish, Source: ReleaseSourceManual, UserID: userId, At: now}
+	c.UnpublishAt = nil
+
+	if released {
+		event.Action = ReleaseActionPublish
+		c.PublishAt = nil
+		c.ReleasedAt = &now
+	}
+
+	c.IsReleased = released
+	c.ReleaseHistory = append(c.ReleaseHistory, event)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+//CourseRevision is a published snapshot of the course draft, revisions are numbered from 1 per course & never changed
+type CourseRevision struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	CourseID    primitive.ObjectID `json:"course_id" bson:"course_id"`
+	Revision    int                `json:"revision" bson:"revision"`
+	IsPublished bool               `json:"is_published" bson:"-"`
+	PublishedBy string             `json:"published_by,omitempty" bson:"published_by"`
+	Snapshot    *Course            `json:"snapshot,omitempty" bson:"snapshot,omitempty"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty" bson:"created_at"`
+}
+
+//Content of the course draft which is published, release state & trash stay on the course itself
+func (c *Course) Snapshot() *Course {
+
+	materials := make([]Material, len(c.Materials))
+	copy(materials, c.Materials)
+
+	sections := make([]Section, len(c.Sections))
+	for i, section := range c.Sections {
+		section.Materials = nil
+		section.Duration = 0
+		sections[i] = section
+	}
+
+	return &Course{
+		ID:            c.ID,
+		UserID:        c.UserID,
+		Name:          c.Name,
+		CourseID:      c.CourseID,
+		Description:   c.Description,
+		ImageKey:      c.ImageKey,
+		Price:         c.Price,
+		TotalDuration: c.TotalDuration,
+		Materials:     materials,
+		Sections:      sections,
+		UpdatedAt:     c.UpdatedAt,
+		CreatedAt:     c.CreatedAt,
+	}
+}
+
+//Serve the published content instead of the draft.
+//Processing results of the draft are kept for materials whose file didn't change, transcoding may finish after publishing.
+func (c *Course) ApplyRevision(snapshot *Course) {
+
+	drafts := make(map[primitive.ObjectID]Material)
+	for _, material := range c.Materials {
+		drafts[material.MaterialID] = material
+	}
+
+	totalDuration := snapshot.TotalDuration
+	materials := make([]Material, len(snapshot.Materials))
+	for i, material := range snapshot.Materials {
+		if draft, found := drafts[material.MaterialID]; found && material.Key != "" && draft.Key == material.Key {
+			material.Status = draft.Status
+			material.StatusError = draft.StatusError
+			material.PlaylistKey = draft.PlaylistKey
+			material.PosterKey = draft.PosterKey
+			material.ThumbnailsKey = draft.ThumbnailsKey
+			material.MediaInfo = draft.MediaInfo
+			totalDuration += draft.Duration - material.Duration
+			material.Duration = draft.Duration
+		}
+		materials[i] = material
+	}
+
+	sections := make([]Section, len(snapshot.Sections))
+	copy(sections, snapshot.Sections)
+
+	c.Name = snapshot.Name
+	c.Description = snapshot.Description
+	c.ImageKey = snapshot.ImageKey
+	c.Price = snapshot.Price
+	c.TotalDuration = totalDuration
+	c.Materials = materials
+	c.Sections = sections
+	c.UpdatedAt = snapshot.UpdatedAt
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+//Section groups ordered materials of a course, materials refer to their section so they stay in one array
+type Section struct {
+	SectionID   primitive.ObjectID `json:"section_id" bson:"section_id"`
+	Name        string             `json:"name" bson:"name"`
+	Description string             `json:"description" bson:"description"`
+	Order       int                `json:"order" bson:"order"`
+	Duration    time.Duration      `json:"duration" bson:"-"`
+	Materials   []Material         `json:"materials" bson:"-"`
+	UpdatedAt   *time.Time         `json:"updated_at,omitempty" bson:"updated_at"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty" bson:"created_at"`
+}
+
+//MaterialPlacement moves a material into a section, nil section leaves the material outside of any section
+type MaterialPlacement struct {
+	MaterialID primitive.ObjectID
+	SectionID  *primitive.ObjectID
+	Order      int
+}
+
+func (c *Course) FindSection(sectionId string) *Section {
+	for i := range c.Sections {
+		if c.Sections[i].SectionID.Hex() == sectionId {
+			return &c.Sections[i]
+		}
+	}
+	return nil
+}
+
+//Position of the material section, -1 when the material isn't in any existing section
+func (c *Course) sectionPosition(material Material) int {
+	if material.SectionID == nil {
+		return -1
+	}
+	for i := range c.Sections {
+		if c.Sections[i].SectionID == *material.SectionID {
+			return i
+		}
+	}
+	return -1
+}
+
+//Sort sections & materials in a stable order, then nest materials into their sections.
+//Materials outside of any section come first, ties are broken by id so every fetch returns the same order.
+func (c *Course) ArrangeMaterials() {
+
+	sort.SliceStable(c.Sections, func(i, j int) bool {
+		if c.Sections[i].Order != c.Sections[j].Order {
+			return c.Sections[i].Order < c.Sections[j].Order
+		}
+		return c.Sections[i].SectionID.Hex() < c.Sections[j].SectionID.Hex()
+	})
+
+	positions := make([]int, len(c.Materials))
+	for i, material := range c.Materials {
+		positions[i] = c.sectionPosition(material)
+	}
+
+	indexes := make([]int, len(c.Materials))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if positions[a] != positions[b] {
+			return positions[a] < positions[b]
+		}
+		if c.Materials[a].Order != c.Materials[b].Order {
+			return c.Materials[a].Order < c.Materials[b].Order
+		}
+		return c.Materials[a].MaterialID.Hex() < c.Materials[b].MaterialID.Hex()
+	})
+
+	materials := make([]Material, len(c.Materials))
+	for i, index := range indexes {
+		materials[i] = c.Materials[index]
+	}
+	if c.Materials != nil {
+		c.Materials = materials
+	}
+
+	for i := range c.Sections {
+		c.Sections[i].Materials = []Material{}
+		c.Sections[i].Duration = 0
+	}
+
+	for _, material := range c.Materials {
+		position := c.sectionPosition(material)
+		if position < 0 {
+			continue
+		}
+		c.Sections[position].Materials = append(c.Sections[position].Materials, material)
+		c.Sections[position].Duration += material.Duration
+	}
+}
+
+//Materials of a section in their current order, empty section id selects materials outside of any section
+func (c *Course) SectionMaterials(sectionId string) []Material {
+
+	materials := make([]Material, 0)
+	for _, material := range c.Materials {
+		position := c.sectionPosition(material)
+		if (sectionId == "" && position < 0) || (position >= 0 && c.Sections[position].SectionID.Hex() == sectionId) {
+			materials = append(materials, material)
+		}
+	}
+
+	sort.SliceStable(materials, func(i, j int) bool {
+		if materials[i].Order != materials[j].Order {
+			return materials[i].Order < materials[j].Order
+		}
+		return materials[i].MaterialID.Hex() < materials[j].MaterialID.Hex()
+	})
+
+	return materials
+}
@@ -0,0 +1,23 @@
+package models
+
+type TranscodeJob struct {
+	CourseID     string `json:"course_id"`
+	CoursePrefix string `json:"course_prefix"`
+	MaterialID   string `json:"material_id"`
+	Key          string `json:"key"`
+}
+
+//Single HLS rendition, bitrates are in kbps
+type Rendition struct {
+	Name         string `json:"name"`
+	Height       int    `json:"height"`
+	VideoBitrate int    `json:"video_bitrate"`
+	AudioBitrate int    `json:"audio_bitrate"`
+}
+
+var DefaultRenditions = []Rendition{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 128},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
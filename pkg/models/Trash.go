@@ -0,0 +1,32 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+//Trashed courses & materials of an owner, they're purged once retention period is over
+type Trash struct {
+	Courses   []*Course         `json:"courses"`
+	Materials []TrashedMaterial `json:"materials"`
+}
+
+type TrashedMaterial struct {
+	CourseID   primitive.ObjectID `json:"course_id"`
+	CourseName string             `json:"course_name"`
+	Material   Material           `json:"material"`
+}
+
+type PurgeReport struct {
+	Retention       string    `json:"retention"`
+	PurgedCourses   int       `json:"purged_courses"`
+	PurgedMaterials int       `json:"purged_materials"`
+	FailedObjects   int       `json:"failed_objects"`
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+}
+
+//Item deleted before the cutoff is past retention period
+func IsPastRetention(deletedAt *time.Time, cutoff time.Time) bool {
+	return deletedAt != nil && !deletedAt.After(cutoff)
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Upload struct {
+	ID        string            `json:"id"`
+	UserID    string            `json:"user_id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Completed bool              `json:"completed"`
+	Claimed   bool              `json:"claimed,omitempty"`
+	Filename  string            `json:"filename,omitempty"`
+	Filepath  string            `json:"filepath,omitempty"`
+	Key       string            `json:"key,omitempty"`
+	MediaInfo *MediaInfo        `json:"media_info,omitempty"`
+	UpdatedAt *time.Time        `json:"updated_at,omitempty"`
+	CreatedAt *time.Time        `json:"created_at,omitempty"`
+}
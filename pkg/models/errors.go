@@ -0,0 +1,31 @@
+package models
+
+import "errors"
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadForbidden      = errors.New("upload belongs to another user")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+	ErrUploadTooLarge       = errors.New("upload exceeds maximum size")
+	ErrUploadIncomplete     = errors.New("upload is not completed yet")
+	ErrUploadClaimed        = errors.New("upload is already being attached to a material")
+)
+
+type FailedFile struct {
+	Filename string `json:"filename"`
+	Order    int    `json:"order"`
+	Message  string `json:"message"`
+}
+
+//Operation failed midway, side effects which were already made are compensated
+type OperationError struct {
+	StatusCode         int          `json:"-"`
+	Message            string       `json:"message"`
+	FailedFiles        []FailedFile `json:"failed_files,omitempty"`
+	Compensated        []string     `json:"compensated,omitempty"`
+	CompensationErrors []string     `json:"compensation_errors,omitempty"`
+}
+
+func (e *OperationError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+type AuditRepository struct {
+	Collection *mongo.Collection
+}
+
+func ConstructAuditRepository(coll *mongo.Collection) contracts.AuditRepository {
+
+	return &AuditRepository{
+		Collection: coll,
+	}
+}
+
+func (a AuditRepository) Record(ctx context.Context, event models.AuditEvent) (err error) {
+
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+
+	_, err = a.Collection.InsertOne(ctx, event)
+	return err
+}
+
+//Latest events first
+func (a AuditRepository) Find(ctx context.Context, filter models.AuditFilter, limit int64, skip int64) (res []models.AuditEvent, err error) {
+
+	query := bson.D{}
+	if filter.CourseID != nil {
+		query = append(query, bson.E{Key: "course_id", Value: *filter.CourseID})
+	}
+	if filter.Actor != "" {
+		query = append(query, bson.E{Key: "actor", Value: filter.Actor})
+	}
+
+	createdAt := bson.D{}
+	if filter.From != nil {
+		createdAt = append(createdAt, bson.E{Key: "$gte", Value: *filter.From})
+	}
+	if filter.To != nil {
+		createdAt = append(createdAt, bson.E{Key: "$lte", Value: *filter.To})
+	}
+	if len(createdAt) > 0 {
+		query = append(query, bson.E{Key: "created_at", Value: createdAt})
+	}
+
+	opts := options.Find().SetSort(bson.D{{"created_at", -1}, {"_id", -1}}).SetLimit(limit).SetSkip(skip)
+
+	records, err := a.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//Close Cursor
+	defer func(records *mongo.Cursor, ctx context.Context) {
+		err := records.Close(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(records, ctx)
+
+	results := make([]models.AuditEvent, 0)
+
+	for records.Next(ctx) {
+
+		var event models.AuditEvent
+
+		err := records.Decode(&event)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, event)
+	}
+
+	return results, records.Err()
+}
@@ -4,30 +4,35 @@ import (
 	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/models"
 	"context"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
-	"sort"
+	"log"
+	"time"
 )
 
 type DatabaseRepository struct {
 	Connection *mongo.Database
 	Collection *mongo.Collection
+	Revisions  *mongo.Collection
 }
 
-func ConstructDBRepository(conn *mongo.Database, coll *mongo.Collection) contracts.CourseDatabaseRepository {
+func ConstructDBRepository(conn *mongo.Database, coll *mongo.Collection, revisions *mongo.Collection) contracts.CourseDatabaseRepository {
 
 	return &DatabaseRepository{
 		Connection: conn,
 		Collection: coll,
+		Revisions:  revisions,
 	}
 }
 
-func (d DatabaseRepository) Fetch(ctx context.Context, excludeFields []string, limit int64, skip int64) (res []models.Course, err error) {
+//Fetch released courses, unreleased ones are included only for their owner
+func (d DatabaseRepository) Fetch(ctx context.Context, excludeFields []string, limit int64, skip int64, visibleTo int64) (res []models.Course, err error) {
 
-	//Exclude fields
-	excluded := make(map[string]int)
+	//Exclude fields, trashed materials are only read through trash
+	excluded := map[string]int{"trashed_materials": 0}
 	for _, field := range excludeFields {
 		excluded[field] = 0
 	}
@@ -59,7 +64,11 @@ func (d DatabaseRepository) Fetch(ctx context.Context, excludeFields []string, l
 	//}
 
 	//Fetch Records
-	filter := map[string]interface{}{"deleted_at": nil}
+	filter := bson.D{{"deleted_at", nil}, {"$or", bson.A{
+		bson.D{{"is_released", true}},
+		bson.D{{"user_id", visibleTo}},
+		bson.D{{"collaborators.user_id", visibleTo}},
+	}}}
 	records, err := d.Collection.Find(ctx, filter, opts)
 
 	//Close Cursor
@@ -86,9 +95,7 @@ func (d DatabaseRepository) Fetch(ctx context.Context, excludeFields []string, l
 			return nil, err
 		}
 
-		sort.SliceStable(course.Materials, func(i, j int) bool {
-			return course.Materials[i].Order < course.Materials[j].Order
-		})
+		course.ArrangeMaterials()
 
 		results = append(results, course)
 	}
@@ -98,8 +105,8 @@ func (d DatabaseRepository) Fetch(ctx context.Context, excludeFields []string, l
 
 func (d DatabaseRepository) FetchById(ctx context.Context, id string, excludeFields []string) (res models.Course, err error) {
 
-	//Exclude fields
-	excluded := make(map[string]int)
+	//Exclude fields, trashed materials are only read through trash
+	excluded := map[string]int{"trashed_materials": 0}
 	for _, field := range excludeFields {
 		excluded[field] = 0
 	}
@@ -118,9 +125,7 @@ func (d DatabaseRepository) FetchById(ctx context.Context, id string, excludeFie
 		return course, err
 	}
 
-	sort.SliceStable(course.Materials, func(i, j int) bool {
-		return course.Materials[i].Order < course.Materials[j].Order
-	})
+	course.ArrangeMaterials()
 
 	return course, nil
 }
@@ -147,6 +152,46 @@ func (d DatabaseRepository) FetchByUserId(ctx context.Context, user_id int64, ex
 	return &result, nil
 }
 
+//Fetch every course without pagination, soft deleted courses are included
+func (d DatabaseRepository) FetchAllWithDeleted(ctx context.Context, excludeFields []string) (res []models.Course, err error) {
+
+	//Exclude fields
+	excluded := make(map[string]int)
+	for _, field := range excludeFields {
+		excluded[field] = 0
+	}
+
+	opts := options.Find().SetProjection(excluded)
+
+	records, err := d.Collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//Close Cursor
+	defer func(records *mongo.Cursor, ctx context.Context) {
+		err := records.Close(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(records, ctx)
+
+	results := make([]models.Course, 0)
+
+	for records.Next(ctx) {
+
+		//Decoded in place, courses hold a mutex & aren't copied
+		results = append(results, models.Course{})
+
+		err := records.Decode(&results[len(results)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, records.Err()
+}
+
 func (d DatabaseRepository) Create(ctx context.Context, data *models.Course) (string_id primitive.ObjectID, err error) {
 
 	var course_id primitive.ObjectID
@@ -184,46 +229,476 @@ func (d DatabaseRepository) Create(ctx context.Context, data *models.Course) (st
 	return course_id, nil
 }
 
-func (d DatabaseRepository) Update(ctx context.Context, data models.Course, id string) (res bool, err error) {
+func (d DatabaseRepository) Update(ctx context.Context, data *models.Course, id string) (res bool, err error) {
 
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return false, err
 	}
 
-	filter := bson.D{{"_id", objectId}}
-	_, err = d.Collection.UpdateOne(ctx, filter, bson.D{{"$set", data}})
+	//Revision pointers are only moved by publishing & rollback
+	fields, err := bson.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	var set bson.M
+	err = bson.Unmarshal(fields, &set)
+	if err != nil {
+		return false, err
+	}
+	delete(set, "published_revision")
+	delete(set, "latest_revision")
+	delete(set, "version")
+
+	//Ownership & collaborators are only changed through their own endpoints
+	delete(set, "user_id")
+	delete(set, "collaborators")
+
+	//Written only while the course is still at the version it was read at
+	filter := bson.D{{"_id", objectId}, versionFilter(data.Version)}
+	update := bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, err
+}
+
+//Set fields of a single material, only when the material still matches the given fields
+func (d DatabaseRepository) UpdateMaterialFields(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	materialObjectID, err := primitive.ObjectIDFromHex(material_id)
+	if err != nil {
+		return false, err
+	}
+
+	elemMatch := bson.D{{"material_id", materialObjectID}}
+	for field, value := range match {
+		elemMatch = append(elemMatch, bson.E{Key: field, Value: value})
+	}
+
+	set := bson.D{}
+	for field, value := range fields {
+		set = append(set, bson.E{Key: "materials.$." + field, Value: value})
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"materials", bson.D{{"$elemMatch", elemMatch}}}}
+	update := bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Append a single material & add its duration to the course, the section it's placed in has to exist
+func (d DatabaseRepository) PushMaterial(ctx context.Context, course_id string, material models.Material) (res bool, err error) {
+	return d.pushMaterial(ctx, course_id, material)
+}
+
+//Append material only while the course is still at the given version, e.g. right after room was made for it
+func (d DatabaseRepository) PushMaterialAt(ctx context.Context, course_id string, version int, material models.Material) (res bool, err error) {
+	return d.pushMaterial(ctx, course_id, material, versionFilter(version))
+}
+
+func (d DatabaseRepository) pushMaterial(ctx context.Context, course_id string, material models.Material, conditions ...bson.E) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := append(bson.D{{"_id", courseObjectID}, {"deleted_at", nil}}, conditions...)
+	if material.SectionID != nil {
+		filter = append(filter, bson.E{Key: "sections.section_id", Value: *material.SectionID})
+	}
+
+	update := bson.D{
+		{"$push", bson.D{{"materials", material}}},
+		{"$inc", bson.D{{"total_duration", material.Duration}, {"version", 1}}},
+		{"$set", bson.D{{"updated_at", material.CreatedAt}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Set fields of a single material when it still matches the given fields, duration change is added to the course
+func (d DatabaseRepository) UpdateMaterial(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}, durationDelta time.Duration) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	materialObjectID, err := primitive.ObjectIDFromHex(material_id)
+	if err != nil {
+		return false, err
+	}
+
+	elemMatch := bson.D{{"material_id", materialObjectID}}
+	for field, value := range match {
+		elemMatch = append(elemMatch, bson.E{Key: field, Value: value})
+	}
+
+	set := bson.D{{"updated_at", time.Now()}}
+	for field, value := range fields {
+		set = append(set, bson.E{Key: "materials.$." + field, Value: value})
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}, {"materials", bson.D{{"$elemMatch", elemMatch}}}}
+	update := bson.D{
+		{"$set", set},
+		{"$inc", bson.D{{"total_duration", durationDelta}, {"version", 1}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Append a section to the course
+func (d DatabaseRepository) CreateSection(ctx context.Context, course_id string, section models.Section) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}}
+	update := bson.D{
+		{"$push", bson.D{{"sections", section}}},
+		{"$set", bson.D{{"updated_at", section.CreatedAt}}},
+		{"$inc", bson.D{{"version", 1}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return false, err
 	}
-	return true, err
+
+	return result.MatchedCount > 0, nil
 }
 
-func (d DatabaseRepository) DeleteCourse(ctx context.Context, course_id string) (res bool, err error) {
+//Set fields of a single section
+func (d DatabaseRepository) UpdateSectionFields(ctx context.Context, course_id string, section_id string, fields map[string]interface{}) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	sectionObjectID, err := primitive.ObjectIDFromHex(section_id)
+	if err != nil {
+		return false, err
+	}
+
+	set := bson.D{}
+	for field, value := range fields {
+		set = append(set, bson.E{Key: "sections.$." + field, Value: value})
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}, {"sections.section_id", sectionObjectID}}
+	update := bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Remove a section & place its materials elsewhere in the same write, only while the course is still at the version it was read at
+func (d DatabaseRepository) DeleteSection(ctx context.Context, course_id string, version int, section_id string, placements []models.MaterialPlacement) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	sectionObjectID, err := primitive.ObjectIDFromHex(section_id)
+	if err != nil {
+		return false, err
+	}
+
+	update, filter, opts := materialPlacementUpdate(courseObjectID, version, placements)
+	filter = append(filter, bson.E{Key: "sections.section_id", Value: sectionObjectID})
+	update = append(update, bson.E{Key: "$pull", Value: bson.D{{"sections", bson.D{{"section_id", sectionObjectID}}}}})
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Move materials between sections atomically. Placements are computed from the course as it was read,
+//so nothing is written when the course changed meanwhile
+func (d DatabaseRepository) PlaceMaterials(ctx context.Context, course_id string, version int, placements []models.MaterialPlacement) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	update, filter, opts := materialPlacementUpdate(courseObjectID, version, placements)
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update, opts)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Every placement addresses its material through an array filter, so a single update moves all of them
+func materialPlacementUpdate(courseObjectID primitive.ObjectID, version int, placements []models.MaterialPlacement) (bson.D, bson.D, *options.UpdateOptions) {
+
+	set := bson.D{{"updated_at", time.Now()}}
+	unset := bson.D{}
+	arrayFilters := []interface{}{}
+	materialIDs := []primitive.ObjectID{}
+	sectionIDs := []primitive.ObjectID{}
+
+	for i, placement := range placements {
+
+		identifier := fmt.Sprintf("m%v", i)
+		path := "materials.$[" + identifier + "]."
+
+		set = append(set, bson.E{Key: path + "order", Value: placement.Order})
+		if placement.SectionID != nil {
+			set = append(set, bson.E{Key: path + "section_id", Value: *placement.SectionID})
+			sectionIDs = append(sectionIDs, *placement.SectionID)
+		} else {
+			unset = append(unset, bson.E{Key: path + "section_id", Value: ""})
+		}
+
+		arrayFilters = append(arrayFilters, bson.D{{identifier + ".material_id", placement.MaterialID}})
+		materialIDs = append(materialIDs, placement.MaterialID)
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}, versionFilter(version)}
+	if len(materialIDs) > 0 {
+		filter = append(filter, bson.E{Key: "materials.material_id", Value: bson.D{{"$all", materialIDs}}})
+	}
+	if len(sectionIDs) > 0 {
+		filter = append(filter, bson.E{Key: "sections.section_id", Value: bson.D{{"$all", sectionIDs}}})
+	}
+
+	update := bson.D{{"$set", set}, {"$inc", bson.D{{"version", 1}}}}
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	opts := options.Update()
+	if len(arrayFilters) > 0 {
+		opts.SetArrayFilters(options.ArrayFilters{Filters: arrayFilters})
+	}
+
+	return update, filter, opts
+}
+
+//Course is still at the version it was read at, courses stored before versions existed have none
+func versionFilter(version int) bson.E {
+	if version == 0 {
+		return bson.E{Key: "version", Value: bson.D{{"$in", bson.A{0, nil}}}}
+	}
+	return bson.E{Key: "version", Value: version}
+}
+
+//Soft delete course, its storage objects are kept until it's purged
+func (d DatabaseRepository) TrashCourse(ctx context.Context, course_id string, deletedAt time.Time) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", nil}}
+	update := bson.D{{"$set", bson.D{{"deleted_at", deletedAt}}}, {"$inc", bson.D{{"version", 1}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+func (d DatabaseRepository) RestoreCourse(ctx context.Context, course_id string) (res bool, err error) {
+
 	objectID, err := primitive.ObjectIDFromHex(course_id)
 	if err != nil {
 		return false, err
 	}
-	_, err = d.Collection.DeleteOne(ctx, bson.D{{"_id", objectID}})
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", bson.D{{"$ne", nil}}}}
+	update := bson.D{{"$set", bson.D{{"deleted_at", nil}, {"updated_at", time.Now()}}}, {"$inc", bson.D{{"version", 1}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
 	if err != nil {
 		return false, err
 	}
-	return true, nil
+
+	return result.MatchedCount > 0, nil
+}
+
+//Fetch course whether it's trashed or not, including its trashed materials
+func (d DatabaseRepository) FetchByIdWithDeleted(ctx context.Context, id string) (res *models.Course, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	course := &models.Course{}
+	err = d.Collection.FindOne(ctx, bson.D{{"_id", objectID}}).Decode(course)
+	if err != nil {
+		return nil, err
+	}
+
+	course.ArrangeMaterials()
+
+	return course, nil
 }
 
-func (d DatabaseRepository) DeleteMaterials(ctx context.Context, course_id string, material_id []string) (res interface{}, err error) {
+//Courses of the owner which are trashed or have trashed materials, including courses the user edits
+func (d DatabaseRepository) FetchTrash(ctx context.Context, user_id int64) (res []models.Course, err error) {
+
+	//Trashed courses are listed for their owner, trashed materials for editors as well
+	editable := bson.A{
+		bson.D{{"user_id", user_id}},
+		bson.D{{"collaborators", bson.D{{"$elemMatch", bson.D{{"user_id", user_id}, {"role", models.CourseRoleEditor}}}}}},
+	}
+
+	filter := bson.D{{"$or", bson.A{
+		bson.D{{"user_id", user_id}, {"deleted_at", bson.D{{"$ne", nil}}}},
+		bson.D{{"$or", editable}, {"deleted_at", nil}, {"trashed_materials.0", bson.D{{"$exists", true}}}},
+	}}}
+
+	records, err := d.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{"deleted_at", -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	//Close Cursor
+	defer func(records *mongo.Cursor, ctx context.Context) {
+		err := records.Close(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(records, ctx)
+
+	results := make([]models.Course, 0)
+
+	for records.Next(ctx) {
 
-	var material_ids []primitive.ObjectID
+		results = append(results, models.Course{})
+		course := &results[len(results)-1]
 
-	for _, m_id := range material_id {
-		objectID, err := primitive.ObjectIDFromHex(m_id)
+		err := records.Decode(course)
 		if err != nil {
-			return false, err
+			return nil, err
 		}
-		material_ids = append(material_ids, objectID)
+
+		course.ArrangeMaterials()
 	}
 
-	pull := bson.D{{"$pull", bson.D{{"materials", bson.D{{"material_id", bson.D{{"$in", material_ids}}}}}}}}
+	return results, records.Err()
+}
+
+//Move materials into trash in one write, their duration no longer counts for the course
+func (d DatabaseRepository) TrashMaterials(ctx context.Context, course_id string, materials []models.Material) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	var materialIDs []primitive.ObjectID
+	var duration time.Duration
+	for _, material := range materials {
+		materialIDs = append(materialIDs, material.MaterialID)
+		duration += material.Duration
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}, {"materials.material_id", bson.D{{"$all", materialIDs}}}}
+	update := bson.D{
+		{"$pull", bson.D{{"materials", bson.D{{"material_id", bson.D{{"$in", materialIDs}}}}}}},
+		{"$push", bson.D{{"trashed_materials", bson.D{{"$each", materials}}}}},
+		{"$inc", bson.D{{"total_duration", -duration}, {"version", 1}}},
+		{"$set", bson.D{{"updated_at", time.Now()}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Move material out of trash back into the course
+func (d DatabaseRepository) RestoreMaterial(ctx context.Context, course_id string, material models.Material) (res bool, err error) {
+
+	courseObjectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", courseObjectID}, {"deleted_at", nil}, {"trashed_materials.material_id", material.MaterialID}}
+	update := bson.D{
+		{"$pull", bson.D{{"trashed_materials", bson.D{{"material_id", material.MaterialID}}}}},
+		{"$push", bson.D{{"materials", material}}},
+		{"$inc", bson.D{{"total_duration", material.Duration}, {"version", 1}}},
+		{"$set", bson.D{{"updated_at", time.Now()}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Permanently delete course which is still trashed since before the cutoff
+func (d DatabaseRepository) PurgeCourse(ctx context.Context, course_id string, cutoff time.Time) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", bson.D{{"$lte", cutoff}}}}
+	result, err := d.Collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+//Permanently delete material which is still trashed since before the cutoff
+func (d DatabaseRepository) PurgeTrashedMaterial(ctx context.Context, course_id string, material_id primitive.ObjectID, cutoff time.Time) (res bool, err error) {
 
 	objectID, err := primitive.ObjectIDFromHex(course_id)
 	if err != nil {
@@ -231,13 +706,295 @@ func (d DatabaseRepository) DeleteMaterials(ctx context.Context, course_id strin
 	}
 
 	filter := bson.D{{"_id", objectID}}
+	pull := bson.D{{"$pull", bson.D{{"trashed_materials", bson.D{
+		{"material_id", material_id},
+		{"deleted_at", bson.D{{"$lte", cutoff}}},
+	}}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, pull)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
+
+//Set or clear scheduled release changes of the course
+func (d DatabaseRepository) SetReleaseSchedule(ctx context.Context, course_id string, publishAt *time.Time, unpublishAt *time.Time) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", nil}}
+	update := bson.D{
+		{"$set", bson.D{{"publish_at", publishAt}, {"unpublish_at", unpublishAt}, {"updated_at", time.Now()}}},
+		{"$inc", bson.D{{"version", 1}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Courses with a scheduled release change which is due
+func (d DatabaseRepository) FetchDueReleases(ctx context.Context, now time.Time) (res []models.Course, err error) {
+
+	filter := bson.D{{"deleted_at", nil}, {"$or", bson.A{
+		bson.D{{"publish_at", bson.D{{"$lte", now}}}},
+		bson.D{{"unpublish_at", bson.D{{"$lte", now}}}},
+	}}}
+
+	opts := options.Find().SetProjection(bson.D{{"is_released", 1}, {"publish_at", 1}, {"unpublish_at", 1}})
+
+	records, err := d.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//Close Cursor
+	defer func(records *mongo.Cursor, ctx context.Context) {
+		err := records.Close(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(records, ctx)
+
+	results := make([]models.Course, 0)
+
+	for records.Next(ctx) {
+
+		results = append(results, models.Course{})
+
+		err := records.Decode(&results[len(results)-1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, records.Err()
+}
+
+//Apply scheduled release change & record it, only while the schedule is still the one which became due
+func (d DatabaseRepository) ApplyScheduledRelease(ctx context.Context, course_id string, event models.ReleaseEvent) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	scheduleField := "unpublish_at"
+	set := bson.D{{"is_released", false}, {"unpublish_at", nil}, {"updated_at", event.At}}
+
+	if event.Action == models.ReleaseActionPublish {
+		scheduleField = "publish_at"
+		set = bson.D{{"is_released", true}, {"released_at", event.ScheduledAt}, {"publish_at", nil}, {"updated_at", event.At}}
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", nil}, {scheduleField, event.ScheduledAt}}
+	update := bson.D{
+		{"$set", set},
+		{"$push", bson.D{{"release_history", event}}},
+		{"$inc", bson.D{{"version", 1}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Replace owner & collaborators of the course, only while it's still at the version they were read at
+func (d DatabaseRepository) SetCollaborators(ctx context.Context, course_id string, version int, user_id int64, collaborators []models.Collaborator) (res bool, err error) {
 
-	res, err = d.Collection.UpdateOne(ctx, filter, pull)
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", nil}, versionFilter(version)}
+	update := bson.D{
+		{"$set", bson.D{{"user_id", user_id}, {"collaborators", collaborators}}},
+		{"$inc", bson.D{{"version", 1}}},
+	}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Store a published snapshot, the revision number is unique per course
+func (d DatabaseRepository) CreateRevision(ctx context.Context, revision models.CourseRevision) (err error) {
+
+	_, err = d.Revisions.InsertOne(ctx, revision)
+	return err
+}
+
+//Remove a snapshot which lost the race to become the latest revision
+func (d DatabaseRepository) DeleteRevision(ctx context.Context, revision_id primitive.ObjectID) (err error) {
+
+	_, err = d.Revisions.DeleteOne(ctx, bson.D{{"_id", revision_id}})
+	return err
+}
+
+//Publish the revision, only while no other revision was published since the previous one
+func (d DatabaseRepository) PublishRevision(ctx context.Context, course_id string, previous int, revision int) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	//Courses which were never published have no latest revision at all
+	latest := bson.D{{"latest_revision", previous}}
+	if previous == 0 {
+		latest = bson.D{{"latest_revision", bson.D{{"$in", bson.A{0, nil}}}}}
+	}
+
+	filter := append(bson.D{{"_id", objectID}, {"deleted_at", nil}}, latest...)
+	update := bson.D{{"$set", bson.D{{"published_revision", revision}, {"latest_revision", revision}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Serve an earlier revision, the draft & latest revision are kept
+func (d DatabaseRepository) SetPublishedRevision(ctx context.Context, course_id string, revision int) (res bool, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.D{{"_id", objectID}, {"deleted_at", nil}, {"latest_revision", bson.D{{"$gte", revision}}}}
+	update := bson.D{{"$set", bson.D{{"published_revision", revision}}}}
+
+	result, err := d.Collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+//Revisions of the course, newest first
+func (d DatabaseRepository) FetchRevisions(ctx context.Context, course_id string, withSnapshot bool) (res []models.CourseRevision, err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
 	if err != nil {
 		return nil, err
 	}
 
-	return res, nil
+	opts := options.Find().SetSort(bson.D{{"revision", -1}})
+	if !withSnapshot {
+		opts.SetProjection(bson.D{{"snapshot", 0}})
+	}
+
+	return d.findRevisions(ctx, bson.D{{"course_id", objectID}}, opts)
+}
+
+func (d DatabaseRepository) FetchRevision(ctx context.Context, course_id string, revision int) (res models.CourseRevision, err error) {
+
+	var result models.CourseRevision
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return result, err
+	}
+
+	err = d.Revisions.FindOne(ctx, bson.D{{"course_id", objectID}, {"revision", revision}}).Decode(&result)
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+//Published revision of every given course, keyed by course id
+func (d DatabaseRepository) FetchPublishedRevisions(ctx context.Context, revisions map[primitive.ObjectID]int) (res map[primitive.ObjectID]models.CourseRevision, err error) {
+
+	published := bson.A{}
+	for courseID, revision := range revisions {
+		published = append(published, bson.D{{"course_id", courseID}, {"revision", revision}})
+	}
+
+	results := make(map[primitive.ObjectID]models.CourseRevision)
+	if len(published) == 0 {
+		return results, nil
+	}
+
+	records, err := d.findRevisions(ctx, bson.D{{"$or", published}}, options.Find())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, revision := range records {
+		results[revision.CourseID] = revision
+	}
+
+	return results, nil
+}
+
+//Every stored revision, used to keep storage objects which are still published or can be rolled back to
+func (d DatabaseRepository) FetchAllRevisions(ctx context.Context) (res []models.CourseRevision, err error) {
+	return d.findRevisions(ctx, bson.D{}, options.Find())
+}
+
+//Permanently delete every revision of the course
+func (d DatabaseRepository) DeleteRevisions(ctx context.Context, course_id string) (err error) {
+
+	objectID, err := primitive.ObjectIDFromHex(course_id)
+	if err != nil {
+		return err
+	}
+
+	_, err = d.Revisions.DeleteMany(ctx, bson.D{{"course_id", objectID}})
+	return err
+}
+
+func (d DatabaseRepository) findRevisions(ctx context.Context, filter bson.D, opts *options.FindOptions) (res []models.CourseRevision, err error) {
+
+	records, err := d.Revisions.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	//Close Cursor
+	defer func(records *mongo.Cursor, ctx context.Context) {
+		err := records.Close(ctx)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(records, ctx)
+
+	results := make([]models.CourseRevision, 0)
+
+	for records.Next(ctx) {
+
+		var revision models.CourseRevision
+
+		err := records.Decode(&revision)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, revision)
+	}
+
+	return results, records.Err()
 }
 
 func (d DatabaseRepository) GenerateModelID() primitive.ObjectID {
@@ -3,7 +3,10 @@ package repositories
 import (
 	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/http/response"
+	"bufio"
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,40 +14,56 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"golang.org/x/exp/slices"
-	"io/ioutil"
+	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 )
 
+//Presigned upload urls are valid for this long
+const presignedUploadTTL = time.Hour
+
+//S3 multipart upload limit
+const maxUploadParts = 10000
+
 type S3BucketService struct {
-	maxPartSize     int64
+	maxFileSize     int64
+	partSize        int64
+	partBuffers     chan []byte
 	maxRetries      int
 	accessKeyID     string
 	secretAccessKey string
 	bucketRegion    string
 	bucketName      string
-	allowedMimeType []string
 	session         *session.Session
 	prefix          *string
 }
 
-func ConstructS3Repository(accessKeyId string, secretAccessKey string, bucketName string, bucketRegion string, allowedMimeType []string, maxPartSize int64, maxRetries int) contracts.StorageRepository {
+func ConstructS3Repository(accessKeyId string, secretAccessKey string, bucketName string, bucketRegion string, maxFileSize int64, partSize int64, partConcurrency int, maxRetries int) contracts.StorageRepository {
+
+	//Buffers are allocated lazily, at most partConcurrency parts are held in memory
+	partBuffers := make(chan []byte, partConcurrency)
+	for i := 0; i < partConcurrency; i++ {
+		partBuffers <- nil
+	}
+
 	return &S3BucketService{
-		maxPartSize:     maxPartSize,
+		maxFileSize:     maxFileSize,
+		partSize:        partSize,
+		partBuffers:     partBuffers,
 		maxRetries:      maxRetries,
 		accessKeyID:     accessKeyId,
 		secretAccessKey: secretAccessKey,
 		bucketRegion:    bucketRegion,
 		bucketName:      bucketName,
-		allowedMimeType: allowedMimeType,
 	}
 }
 
-func (s S3BucketService) GetClient() (*s3.S3, error) {
+func (s S3BucketService) getClient() (*s3.S3, error) {
 
 	var client *s3.S3
 
@@ -68,62 +87,6 @@ func (s S3BucketService) GetClient() (*s3.S3, error) {
 
 }
 
-//Read file bytes From multipart request
-func (s S3BucketService) readFileBytes(file *multipart.FileHeader) ([]byte, error) {
-	//Get raw file bytes
-	openedFile, _ := file.Open()
-	filebytes, err := ioutil.ReadAll(openedFile)
-
-	if err != nil {
-		return nil, err
-	}
-
-	//Close file reading
-	defer func(openedFile multipart.File) {
-		err := openedFile.Close()
-		if err != nil {
-			log.Fatalf("Failed closing file, %v", err.Error())
-		}
-	}(openedFile)
-
-	return filebytes, nil
-}
-
-//Files must be video type
-func (s S3BucketService) validateFilesType(files []*multipart.FileHeader) (bool, error) {
-
-	for _, file := range files {
-		size := file.Size
-		contentType := file.Header.Get("Content-Type")
-
-		if size > s.maxPartSize {
-			return false, errors.New(fmt.Sprintf("File too large, maximum file size is %v MB", s.maxPartSize/(1024*1024)))
-		}
-
-		if !slices.Contains(s.allowedMimeType, contentType) {
-			return false, errors.New(fmt.Sprintf("File type %v is not supported", file.Filename))
-		}
-	}
-
-	return true, nil
-}
-
-func (s S3BucketService) validateFileType(file *multipart.FileHeader) (bool, error) {
-
-	size := file.Size
-	contentType := file.Header.Get("Content-Type")
-
-	if size > s.maxPartSize {
-		return false, errors.New(fmt.Sprintf("File too large, maximum file size is %v MB", s.maxPartSize/(1024*1024)))
-	}
-
-	if !slices.Contains(s.allowedMimeType, contentType) {
-		return false, errors.New(fmt.Sprintf("File type %v is not supported", file.Filename))
-	}
-
-	return true, nil
-}
-
 //Construct AWS CompleteMultipartUpload Object
 func (s S3BucketService) completeMultipartUpload(S3 *s3.S3, resp *s3.CreateMultipartUploadOutput, completedParts []*s3.CompletedPart) (*s3.CompleteMultipartUploadOutput, error) {
 	completeInput := &s3.CompleteMultipartUploadInput{
@@ -144,7 +107,6 @@ func (s S3BucketService) uploadPart(S3 *s3.S3, resp *s3.CreateMultipartUploadOut
 	try := 1
 
 	partInput := &s3.UploadPartInput{
-		Body:          bytes.NewReader(filebytes),
 		Bucket:        resp.Bucket,
 		Key:           resp.Key,
 		PartNumber:    aws.Int64(int64(partNumber)),
@@ -153,6 +115,9 @@ func (s S3BucketService) uploadPart(S3 *s3.S3, resp *s3.CreateMultipartUploadOut
 	}
 
 	for try <= s.maxRetries {
+		//Every attempt needs a fresh body reader
+		partInput.Body = bytes.NewReader(filebytes)
+
 		uploadPartOutput, err := S3.UploadPart(partInput)
 		if err != nil {
 			if try == s.maxRetries {
@@ -189,351 +154,443 @@ func (s S3BucketService) abortMultiPartUpload(S3 *s3.S3, resp *s3.CreateMultipar
 	return err
 }
 
-func (s S3BucketService) UploadFile(file *multipart.FileHeader, prefix string) (response.S3Response, error) {
-	//1. Validate Inputs
-	//valid, err := s.validateFileType(file)
-	//if !valid {
-	//	return response.S3Response{}, err
-	//}
+//Stream reader into S3 multipart upload, parts are read one at a time and uploaded concurrently.
+//Part buffers are shared by every upload so memory stays bounded regardless of file size.
+func (s S3BucketService) uploadStream(s3Client *s3.S3, reader io.Reader, path string, fileType string) (*s3.CompleteMultipartUploadOutput, error) {
 
-	//2. Open AWS S3 Session
-	s3Client, _ := s.GetClient()
+	//1. Get file type /mime type from the first bytes unless it's given
+	bufferedReader := bufio.NewReaderSize(reader, 512)
+	if fileType == "" {
+		head, _ := bufferedReader.Peek(512)
+		fileType = http.DetectContentType(head)
+	}
+
+	//2. Create s3 multipart upload
+	createdMultipartOutput, err := s3Client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(path),
+		ContentType: aws.String(fileType),
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	//3.
-	now := time.Now()
-	nowRFC3339 := now.Format(time.RFC3339)
+	log.Println("Created multipart upload request")
 
-	//4. Loop over form input and process each part
+	//3. Read parts & upload them in parallel
 	var wg sync.WaitGroup
-	finalResultChannel := make(chan response.S3Response, 10)
-	pathNumber := 0
+	var mutex sync.Mutex
+	var completedParts []*s3.CompletedPart
+	var uploadErr error
+	var uploadedSize int64
+
+	failed := func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if uploadErr == nil {
+			uploadErr = err
+		}
+	}
 
-	wg.Add(1)
+	hasFailed := func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return uploadErr != nil
+	}
 
-	//5. Get filePart bytes
-	go func(wg *sync.WaitGroup, filePart *multipart.FileHeader, pathNumber int, prefix string) {
+	for partNumber := 1; !hasFailed(); partNumber++ {
 
-		defer wg.Done()
+		if partNumber > maxUploadParts {
+			failed(errors.New(fmt.Sprintf("File too large, maximum file size is %v MB", s.partSize*maxUploadParts/(1024*1024))))
+			break
+		}
 
-		result := response.S3Response{}
+		buffer := <-s.partBuffers
+		if buffer == nil {
+			buffer = make([]byte, s.partSize)
+		}
 
-		filebytes, err := s.readFileBytes(filePart)
-		if err != nil {
-			log.Println(err.Error())
-			result.Success = false
-			result.Filename = filePart.Filename
-			result.Message = err.Error()
-			result.Order = pathNumber
-			result.Key = ""
-			finalResultChannel <- result
-			return
+		length, readErr := io.ReadFull(bufferedReader, buffer)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			s.partBuffers <- buffer
+			failed(readErr)
+			break
+		}
+
+		//Nothing left to upload
+		if length == 0 && partNumber > 1 {
+			s.partBuffers <- buffer
+			break
 		}
 
-		//6. Filename format
-		path := prefix + nowRFC3339 + "-" + filePart.Filename
+		//Size is only known while streaming, parts uploaded so far are aborted along with the upload
+		uploadedSize += int64(length)
+		if uploadedSize > s.maxFileSize {
+			s.partBuffers <- buffer
+			failed(errors.New(fmt.Sprintf("File too large, maximum file size is %v MB", s.maxFileSize/(1024*1024))))
+			break
+		}
 
-		//7. Get filePart type /mime type
-		fileType := http.DetectContentType(filebytes)
+		wg.Add(1)
+
+		go func(buffer []byte, length int, partNumber int) {
+			defer wg.Done()
+			defer func() { s.partBuffers <- buffer }()
 
-		//8. Prepare s3 multipart upload
-		input := &s3.CreateMultipartUploadInput{
-			Bucket:      aws.String(s.bucketName),
-			Key:         aws.String(path),
-			ContentType: aws.String(fileType),
+			completedPart, err := s.uploadPart(s3Client, createdMultipartOutput, buffer[:length], partNumber)
+			if err != nil {
+				log.Println(err.Error())
+				failed(err)
+				return
+			}
+
+			mutex.Lock()
+			completedParts = append(completedParts, completedPart)
+			mutex.Unlock()
+		}(buffer, length, partNumber)
+
+		//Last part has been read
+		if readErr != nil {
+			break
 		}
+	}
+
+	wg.Wait()
 
-		//9. Create s3 multipart upload
-		createdMultipartOutput, err := s3Client.CreateMultipartUpload(input)
+	if uploadErr != nil {
+		err := s.abortMultiPartUpload(s3Client, createdMultipartOutput)
 		if err != nil {
 			log.Println(err.Error())
-			result.Success = false
-			result.Filename = filePart.Filename
-			result.Order = pathNumber
-			result.Message = err.Error()
-			result.Key = ""
-			finalResultChannel <- result
-			return
 		}
+		return nil, uploadErr
+	}
 
-		log.Println("Created multipart upload request")
+	//4. Parts must be completed in ascending order
+	sort.SliceStable(completedParts, func(i, j int) bool {
+		return *completedParts[i].PartNumber < *completedParts[j].PartNumber
+	})
 
-		//10. UploadFiles Multipart
-		var current, partLength int64
-		var remaining = filePart.Size
-		var completedParts []*s3.CompletedPart
+	return s.completeMultipartUpload(s3Client, createdMultipartOutput, completedParts)
+}
 
-		partNumber := 1
-		for current = 0; remaining != 0; current += partLength {
-			if remaining < s.maxPartSize {
-				partLength = remaining
-			} else {
-				partLength = s.maxPartSize
-			}
+//Filename format, random part keeps keys of files stored within the same second apart
+func objectKeyOf(prefix string, nowRFC3339 string, filename string) string {
 
-			//UploadFiles Binaries File
-			completedPart, err := s.uploadPart(s3Client, createdMultipartOutput, filebytes[current:current+partLength], partNumber)
+	randomBytes := make([]byte, 4)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return fmt.Sprintf("%s%s-%d-%s", prefix, nowRFC3339, time.Now().UnixNano(), filepath.Base(filename))
+	}
 
-			if err != nil {
-				log.Println(err.Error())
+	return prefix + nowRFC3339 + "-" + hex.EncodeToString(randomBytes) + "-" + filepath.Base(filename)
+}
 
-				err := s.abortMultiPartUpload(s3Client, createdMultipartOutput)
-				if err != nil {
-					log.Println(err.Error())
-					result.Success = false
-					result.Filename = filePart.Filename
-					result.Order = pathNumber
-					result.Message = err.Error()
-					result.Key = ""
-					finalResultChannel <- result
-
-					return
-				}
+func (s S3BucketService) upload(s3Client *s3.S3, reader io.Reader, filename string, prefix string, order int, nowRFC3339 string) response.S3Response {
 
-				result.Success = false
-				result.Filename = filePart.Filename
-				result.Order = pathNumber
-				result.Message = err.Error()
-				result.Key = ""
-				finalResultChannel <- result
+	result := response.S3Response{
+		Filename: filename,
+		Order:    order,
+	}
 
-				return
-			}
+	path := objectKeyOf(prefix, nowRFC3339, filename)
 
-			remaining -= partLength
-			partNumber++
-			completedParts = append(completedParts, completedPart)
+	log.Printf("Uploading file: %s\n", filename)
 
-		}
+	completeResponse, err := s.uploadStream(s3Client, reader, path, "")
+	if err != nil {
+		log.Println(err.Error())
+		result.Success = false
+		result.Message = err.Error()
+		result.Key = ""
+		return result
+	}
 
-		log.Printf("Uploading file: %s\n", filePart.Filename)
+	log.Printf("File successfully uploaded : %s\n", filename)
 
-		completeResponse, err := s.completeMultipartUpload(s3Client, createdMultipartOutput, completedParts)
+	//Uploaded file's url location
+	result.Success = true
+	result.Filepath = *completeResponse.Location
+	result.Key = *completeResponse.Key
+	result.Message = fmt.Sprintf("File %v successfully uploaded", filename)
 
-		if err != nil {
-			log.Println(err.Error())
-			result.Success = false
-			result.Filename = filePart.Filename
-			result.Order = pathNumber
-			result.Message = err.Error()
-			result.Key = ""
-			finalResultChannel <- result
-			return
-		}
+	return result
+}
 
-		log.Printf("File successfully uploaded : %s\n", filePart.Filename)
+func (s S3BucketService) UploadReader(reader io.Reader, filename string, prefix string) (response.S3Response, error) {
 
-		//Array of uploaded part's url location
-		result.Success = true
-		result.Filename = filePart.Filename
-		result.Filepath = *completeResponse.Location
-		result.Order = pathNumber
-		result.Key = *completeResponse.Key
-		result.Message = fmt.Sprintf("File %v successfully uploaded", filePart.Filename)
-		finalResultChannel <- result
+	//Open AWS S3 Session
+	s3Client, err := s.getClient()
+	if err != nil {
+		return response.S3Response{}, err
+	}
+
+	return s.upload(s3Client, reader, filename, prefix, 0, time.Now().Format(time.RFC3339)), nil
+}
 
-	}(&wg, file, pathNumber, prefix)
+func (s S3BucketService) PutObject(reader io.Reader, objectKey string, contentType string) (response.S3Response, error) {
 
-	pathNumber++
+	result := response.S3Response{
+		Filename: filepath.Base(objectKey),
+	}
 
-	wg.Wait()
+	s3Client, err := s.getClient()
+	if err != nil {
+		return result, err
+	}
 
-	//Close Channel
-	close(finalResultChannel)
+	completeResponse, err := s.uploadStream(s3Client, reader, objectKey, contentType)
+	if err != nil {
+		return result, err
+	}
 
-	//Loop Over Channel
+	result.Success = true
+	result.Filepath = *completeResponse.Location
+	result.Key = *completeResponse.Key
+	result.Message = fmt.Sprintf("File %v successfully uploaded", result.Filename)
 
-	return <-finalResultChannel, nil
+	return result, nil
 }
 
-func (s S3BucketService) UploadFiles(files []*multipart.FileHeader, prefix string) ([]response.S3Response, error) {
+func (s S3BucketService) UploadFile(file *multipart.FileHeader, prefix string) (response.S3Response, error) {
 
-	//1. Validate Inputs
-	//valid, err := s.validateFilesType(files)
-	//if !valid {
-	//	return nil, err
-	//}
+	openedFile, err := file.Open()
+	if err != nil {
+		return response.S3Response{}, err
+	}
 
-	//2. Open AWS S3 Session
-	s3Client, _ := s.GetClient()
+	//Close file reading
+	defer func(openedFile multipart.File) {
+		err := openedFile.Close()
+		if err != nil {
+			log.Printf("Failed closing file, %v", err.Error())
+		}
+	}(openedFile)
+
+	return s.UploadReader(openedFile, file.Filename, prefix)
+}
 
-	//3.
-	now := time.Now()
-	nowRFC3339 := now.Format(time.RFC3339)
+func (s S3BucketService) UploadFiles(files []*multipart.FileHeader, prefix string) ([]response.S3Response, error) {
 
-	//4. Loop over form input and process each part
+	//1. Open AWS S3 Session
+	s3Client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	nowRFC3339 := time.Now().Format(time.RFC3339)
+
+	//2. Upload each file concurrently, results keep the request order
 	var wg sync.WaitGroup
-	finalResultChannel := make(chan response.S3Response, 10)
-	pathNumber := 0
+	finalResult := make([]response.S3Response, len(files))
 
-	for _, filePart := range files {
+	for pathNumber, filePart := range files {
 
 		wg.Add(1)
 
-		//5. Get filePart bytes
-		go func(wg *sync.WaitGroup, filePart *multipart.FileHeader, pathNumber int, prefix string) {
-
+		go func(filePart *multipart.FileHeader, pathNumber int) {
 			defer wg.Done()
 
-			result := response.S3Response{}
-
-			filebytes, err := s.readFileBytes(filePart)
+			openedFile, err := filePart.Open()
 			if err != nil {
 				log.Println(err.Error())
-				result.Success = false
-				result.Filename = filePart.Filename
-				result.Message = err.Error()
-				result.Order = pathNumber
-				result.Key = ""
-				finalResultChannel <- result
+				finalResult[pathNumber] = response.S3Response{
+					Filename: filePart.Filename,
+					Order:    pathNumber,
+					Success:  false,
+					Message:  err.Error(),
+				}
 				return
 			}
+			defer openedFile.Close()
 
-			//6. Filename format
-			path := prefix + nowRFC3339 + "-" + filePart.Filename
+			finalResult[pathNumber] = s.upload(s3Client, openedFile, filePart.Filename, prefix, pathNumber, nowRFC3339)
+		}(filePart, pathNumber)
+	}
 
-			//7. Get filePart type /mime type
-			fileType := http.DetectContentType(filebytes)
+	wg.Wait()
 
-			//8. Prepare s3 multipart upload
-			input := &s3.CreateMultipartUploadInput{
-				Bucket:      aws.String(s.bucketName),
-				Key:         aws.String(path),
-				ContentType: aws.String(fileType),
-			}
+	return finalResult, nil
+}
 
-			//9. Create s3 multipart upload
-			createdMultipartOutput, err := s3Client.CreateMultipartUpload(input)
-			if err != nil {
-				log.Println(err.Error())
-				result.Success = false
-				result.Filename = filePart.Filename
-				result.Order = pathNumber
-				result.Message = err.Error()
-				result.Key = ""
-				finalResultChannel <- result
-				return
-			}
+func (s S3BucketService) DeleteObject(objectKey *string) error {
 
-			log.Println("Created multipart upload request")
+	client, err := s.getClient()
+	if err != nil {
+		return err
+	}
 
-			//10. UploadFiles Multipart
-			var current, partLength int64
-			var remaining = filePart.Size
-			var completedParts []*s3.CompletedPart
+	_, err = client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    objectKey,
+	})
+	if err != nil {
+		return err
+	}
 
-			partNumber := 1
-			for current = 0; remaining != 0; current += partLength {
+	err = client.WaitUntilObjectNotExists(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucketName),
+		Key:    objectKey,
+	})
+	if err != nil {
+		return err
+	}
 
-				if remaining < s.maxPartSize {
-					partLength = remaining
-				} else {
-					partLength = s.maxPartSize
-				}
+	return nil
+}
 
-				//UploadFiles Binaries File
-				completedPart, err := s.uploadPart(s3Client, createdMultipartOutput, filebytes[current:current+partLength], partNumber)
-
-				if err != nil {
-					log.Println(err.Error())
-
-					err := s.abortMultiPartUpload(s3Client, createdMultipartOutput)
-					if err != nil {
-						log.Println(err.Error())
-						result.Success = false
-						result.Filename = filePart.Filename
-						result.Order = pathNumber
-						result.Message = err.Error()
-						result.Key = ""
-						finalResultChannel <- result
-
-						return
-					}
-
-					result.Success = false
-					result.Filename = filePart.Filename
-					result.Order = pathNumber
-					result.Message = err.Error()
-					result.Key = ""
-					finalResultChannel <- result
-
-					return
-				}
+func (s S3BucketService) CreatePresignedUpload(filename string, prefix string, contentType string, size int64) (response.PresignedUpload, error) {
 
-				remaining -= partLength
-				partNumber++
-				completedParts = append(completedParts, completedPart)
+	if size <= 0 {
+		return response.PresignedUpload{}, errors.New("File size must be greater than zero")
+	}
 
-			}
+	//1. Calculate total parts
+	totalParts := (size + s.partSize - 1) / s.partSize
+	if totalParts > maxUploadParts {
+		return response.PresignedUpload{}, errors.New(fmt.Sprintf("File too large, maximum file size is %v MB", s.partSize*maxUploadParts/(1024*1024)))
+	}
 
-			log.Printf("Uploading file: %s\n", filePart.Filename)
+	//2. Open AWS S3 Session
+	s3Client, err := s.getClient()
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
 
-			completeResponse, err := s.completeMultipartUpload(s3Client, createdMultipartOutput, completedParts)
+	//3. Create s3 multipart upload
+	path := objectKeyOf(prefix, time.Now().Format(time.RFC3339), filename)
 
-			if err != nil {
-				log.Println(err.Error())
-				result.Success = false
-				result.Filename = filePart.Filename
-				result.Order = pathNumber
-				result.Message = err.Error()
-				result.Key = ""
-				finalResultChannel <- result
-				return
+	createdMultipartOutput, err := s3Client.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucketName),
+		Key:         aws.String(path),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
+
+	//4. Presign every part
+	result := response.PresignedUpload{
+		Filename:  filename,
+		Key:       path,
+		UploadID:  *createdMultipartOutput.UploadId,
+		PartSize:  s.partSize,
+		ExpiresAt: time.Now().Add(presignedUploadTTL),
+	}
+
+	for partNumber := int64(1); partNumber <= totalParts; partNumber++ {
+
+		request, _ := s3Client.UploadPartRequest(&s3.UploadPartInput{
+			Bucket:     createdMultipartOutput.Bucket,
+			Key:        createdMultipartOutput.Key,
+			UploadId:   createdMultipartOutput.UploadId,
+			PartNumber: aws.Int64(partNumber),
+		})
+
+		url, err := request.Presign(presignedUploadTTL)
+		if err != nil {
+			abortErr := s.abortMultiPartUpload(s3Client, createdMultipartOutput)
+			if abortErr != nil {
+				log.Println(abortErr.Error())
 			}
+			return response.PresignedUpload{}, err
+		}
 
-			log.Printf("File successfully uploaded : %s\n", filePart.Filename)
+		result.Parts = append(result.Parts, response.PresignedPart{
+			PartNumber: partNumber,
+			Method:     http.MethodPut,
+			Url:        url,
+		})
+	}
 
-			//Array of uploaded part's url location
-			result.Success = true
-			result.Filename = filePart.Filename
-			result.Filepath = *completeResponse.Location
-			result.Order = pathNumber
-			result.Key = *completeResponse.Key
-			result.Message = fmt.Sprintf("File %v successfully uploaded", filePart.Filename)
-			finalResultChannel <- result
+	return result, nil
+}
 
-		}(&wg, filePart, pathNumber, prefix)
+func (s S3BucketService) CompletePresignedUpload(objectKey string, uploadId string, parts []response.UploadedPart) (response.S3Response, error) {
 
-		pathNumber++
+	result := response.S3Response{
+		Filename: filepath.Base(objectKey),
 	}
 
-	wg.Wait()
+	s3Client, err := s.getClient()
+	if err != nil {
+		return result, err
+	}
 
-	//Close Channel
-	close(finalResultChannel)
+	//Parts must be sent in ascending order
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
 
-	//Loop Over Channel
-	var finalResult []response.S3Response
+	var completedParts []*s3.CompletedPart
+	for _, part := range parts {
+		completedParts = append(completedParts, &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(part.PartNumber),
+		})
+	}
 
-	for res := range finalResultChannel {
-		finalResult = append(finalResult, res)
+	completeResponse, err := s.completeMultipartUpload(s3Client, &s3.CreateMultipartUploadOutput{
+		Bucket:   aws.String(s.bucketName),
+		Key:      aws.String(objectKey),
+		UploadId: aws.String(uploadId),
+	}, completedParts)
+	if err != nil {
+		result.Success = false
+		result.Message = err.Error()
+		return result, err
 	}
 
-	return finalResult, nil
+	result.Success = true
+	result.Filepath = *completeResponse.Location
+	result.Key = *completeResponse.Key
+	result.Message = fmt.Sprintf("File %v successfully uploaded", result.Filename)
 
+	return result, nil
 }
 
-func (s S3BucketService) DeleteObject(objectKey *string) error {
+func (s S3BucketService) GetObject(objectKey string) (io.ReadCloser, error) {
 
-	client, err := s.GetClient()
+	client, err := s.getClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = client.DeleteObject(&s3.DeleteObjectInput{
+	output, err := client.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(s.bucketName),
-		Key:    objectKey,
+		Key:    aws.String(objectKey),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	err = client.WaitUntilObjectNotExists(&s3.HeadObjectInput{
+	return output.Body, nil
+}
+
+func (s S3BucketService) ListObjects(prefix string) ([]response.StorageObject, error) {
+
+	client, err := s.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []response.StorageObject
+
+	err = client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
 		Bucket: aws.String(s.bucketName),
-		Key:    objectKey,
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			objects = append(objects, response.StorageObject{
+				Key:          aws.StringValue(object.Key),
+				Size:         aws.Int64Value(object.Size),
+				LastModified: aws.TimeValue(object.LastModified),
+			})
+		}
+		return true
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return objects, nil
 }
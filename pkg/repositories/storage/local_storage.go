@@ -0,0 +1,541 @@
+package repositories
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/signature"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Part size used when presigning local uploads
+const localUploadPartSize = int64(100 * 1024 * 1024)
+
+var uploadIdPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+type LocalStorageService struct {
+	rootDir  string
+	partsDir string
+	baseUrl  string
+	secret   string
+}
+
+func ConstructLocalStorageRepository(rootDir string, baseUrl string, secret string) contracts.LocalStorageRepository {
+	return &LocalStorageService{
+		rootDir:  rootDir,
+		partsDir: filepath.Clean(rootDir) + ".parts",
+		baseUrl:  strings.TrimSuffix(baseUrl, "/"),
+		secret:   secret,
+	}
+}
+
+//Resolve object key into a path inside root directory
+func (l LocalStorageService) objectPath(objectKey string) (string, error) {
+
+	root, err := filepath.Abs(l.rootDir)
+	if err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(root, filepath.FromSlash(path.Clean("/"+objectKey)))
+
+	relative, err := filepath.Rel(root, target)
+	if err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+		return "", errors.New(fmt.Sprintf("Invalid object key %v", objectKey))
+	}
+
+	return target, nil
+}
+
+//Build public url of stored object
+func (l LocalStorageService) objectUrl(objectKey string) string {
+
+	segments := strings.Split(objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return l.baseUrl + "/" + strings.Join(segments, "/")
+}
+
+func (l LocalStorageService) writeFile(reader io.Reader, objectKey string) error {
+
+	target, err := l.objectPath(objectKey)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	destination, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(destination, reader)
+	if err != nil {
+		_ = destination.Close()
+		_ = os.Remove(target)
+		return err
+	}
+
+	return destination.Close()
+}
+
+func (l LocalStorageService) upload(reader io.Reader, filename string, prefix string, order int, nowRFC3339 string) response.S3Response {
+
+	result := response.S3Response{
+		Filename: filename,
+		Order:    order,
+	}
+
+	objectKey := objectKeyOf(prefix, nowRFC3339, filename)
+
+	err := l.writeFile(reader, objectKey)
+	if err != nil {
+		log.Println(err.Error())
+		result.Success = false
+		result.Message = err.Error()
+		return result
+	}
+
+	log.Printf("File successfully stored : %s\n", filename)
+
+	result.Success = true
+	result.Filepath = l.objectUrl(objectKey)
+	result.Key = objectKey
+	result.Message = fmt.Sprintf("File %v successfully uploaded", filename)
+
+	return result
+}
+
+func (l LocalStorageService) uploadFile(file *multipart.FileHeader, prefix string, order int, nowRFC3339 string) response.S3Response {
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return response.S3Response{
+			Filename: file.Filename,
+			Order:    order,
+			Success:  false,
+			Message:  err.Error(),
+		}
+	}
+
+	//Close file reading
+	defer func(openedFile multipart.File) {
+		err := openedFile.Close()
+		if err != nil {
+			log.Printf("Failed closing file, %v", err.Error())
+		}
+	}(openedFile)
+
+	return l.upload(openedFile, file.Filename, prefix, order, nowRFC3339)
+}
+
+func (l LocalStorageService) UploadReader(reader io.Reader, filename string, prefix string) (response.S3Response, error) {
+
+	nowRFC3339 := time.Now().Format(time.RFC3339)
+
+	return l.upload(reader, filename, prefix, 0, nowRFC3339), nil
+}
+
+func (l LocalStorageService) PutObject(reader io.Reader, objectKey string, contentType string) (response.S3Response, error) {
+
+	result := response.S3Response{
+		Filename: filepath.Base(objectKey),
+	}
+
+	err := l.writeFile(reader, objectKey)
+	if err != nil {
+		return result, err
+	}
+
+	result.Success = true
+	result.Filepath = l.objectUrl(objectKey)
+	result.Key = objectKey
+	result.Message = fmt.Sprintf("File %v successfully uploaded", result.Filename)
+
+	return result, nil
+}
+
+func (l LocalStorageService) UploadFile(file *multipart.FileHeader, prefix string) (response.S3Response, error) {
+
+	nowRFC3339 := time.Now().Format(time.RFC3339)
+
+	return l.uploadFile(file, prefix, 0, nowRFC3339), nil
+}
+
+func (l LocalStorageService) UploadFiles(files []*multipart.FileHeader, prefix string) ([]response.S3Response, error) {
+
+	nowRFC3339 := time.Now().Format(time.RFC3339)
+
+	var finalResult []response.S3Response
+
+	for pathNumber, file := range files {
+		finalResult = append(finalResult, l.uploadFile(file, prefix, pathNumber, nowRFC3339))
+	}
+
+	return finalResult, nil
+}
+
+func (l LocalStorageService) DeleteObject(objectKey *string) error {
+
+	target, err := l.objectPath(*objectKey)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(target)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+//Resolve directory holding the parts of a presigned upload
+func (l LocalStorageService) uploadDir(uploadId string) (string, error) {
+	if !uploadIdPattern.MatchString(uploadId) {
+		return "", errors.New(fmt.Sprintf("Invalid upload id %v", uploadId))
+	}
+	return filepath.Join(l.partsDir, uploadId), nil
+}
+
+func (l LocalStorageService) CreatePresignedUpload(filename string, prefix string, contentType string, size int64) (response.PresignedUpload, error) {
+
+	if size <= 0 {
+		return response.PresignedUpload{}, errors.New("File size must be greater than zero")
+	}
+
+	if l.secret == "" {
+		return response.PresignedUpload{}, errors.New("Local storage secret is not configured")
+	}
+
+	//1. Generate upload id
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
+	uploadId := hex.EncodeToString(randomBytes)
+
+	//2. Prepare parts directory, remember which key the upload belongs to
+	objectKey := objectKeyOf(prefix, time.Now().Format(time.RFC3339), filename)
+
+	_, err = l.objectPath(objectKey)
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
+
+	dir, _ := l.uploadDir(uploadId)
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "key"), []byte(objectKey), 0644)
+	if err != nil {
+		return response.PresignedUpload{}, err
+	}
+
+	//3. Sign every part
+	expiresAt := time.Now().Add(presignedUploadTTL)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	totalParts := (size + localUploadPartSize - 1) / localUploadPartSize
+
+	result := response.PresignedUpload{
+		Filename:  filename,
+		Key:       objectKey,
+		UploadID:  uploadId,
+		PartSize:  localUploadPartSize,
+		ExpiresAt: expiresAt,
+	}
+
+	for partNumber := int64(1); partNumber <= totalParts; partNumber++ {
+
+		part := strconv.FormatInt(partNumber, 10)
+
+		query := url.Values{}
+		query.Set("upload_id", uploadId)
+		query.Set("part_number", part)
+		query.Set("expires", expires)
+		query.Set("signature", signature.Sign(l.secret, http.MethodPut, objectKey, uploadId, part, expires))
+
+		result.Parts = append(result.Parts, response.PresignedPart{
+			PartNumber: partNumber,
+			Method:     http.MethodPut,
+			Url:        l.objectUrl(objectKey) + "?" + query.Encode(),
+		})
+	}
+
+	return result, nil
+}
+
+func (l LocalStorageService) VerifyUploadPart(objectKey string, uploadId string, partNumber int64, expires int64, sign string) error {
+
+	if time.Now().Unix() > expires {
+		return errors.New("Upload url is expired")
+	}
+
+	valid := signature.Verify(l.secret, sign, http.MethodPut, objectKey, uploadId,
+		strconv.FormatInt(partNumber, 10), strconv.FormatInt(expires, 10))
+	if !valid {
+		return errors.New("Invalid upload signature")
+	}
+
+	return nil
+}
+
+func (l LocalStorageService) VerifyDownload(objectKey string, expires int64, sign string) error {
+
+	if time.Now().Unix() > expires {
+		return errors.New("Download url is expired")
+	}
+
+	valid := signature.Verify(l.secret, sign, http.MethodGet, objectKey, strconv.FormatInt(expires, 10))
+	if !valid {
+		return errors.New("Invalid download signature")
+	}
+
+	return nil
+}
+
+//Object is allowed by a signature of any directory containing it
+func (l LocalStorageService) VerifyPrefixDownload(objectKey string, expires int64, sign string) error {
+
+	if time.Now().Unix() > expires {
+		return errors.New("Download url is expired")
+	}
+
+	if objectKey != path.Clean("/" + objectKey)[1:] {
+		return errors.New("Invalid object key")
+	}
+
+	for dir := path.Dir(objectKey); dir != "."; dir = path.Dir(dir) {
+		if signature.VerifyPrefix(l.secret, sign, dir+"/", strconv.FormatInt(expires, 10)) {
+			return nil
+		}
+	}
+
+	return errors.New("Invalid download signature")
+}
+
+func (l LocalStorageService) WriteUploadPart(uploadId string, partNumber int64, body io.Reader) (string, error) {
+
+	dir, err := l.uploadDir(uploadId)
+	if err != nil {
+		return "", err
+	}
+
+	destination, err := os.Create(filepath.Join(dir, strconv.FormatInt(partNumber, 10)))
+	if err != nil {
+		return "", err
+	}
+
+	//Signed part urls are reusable until they expire, so parts are never written past the part size
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(destination, hash), io.LimitReader(body, localUploadPartSize+1))
+	if err == nil && written > localUploadPartSize {
+		err = errors.New(fmt.Sprintf("Upload part is larger than %d bytes", localUploadPartSize))
+	}
+	if err != nil {
+		_ = destination.Close()
+		_ = os.Remove(destination.Name())
+		return "", err
+	}
+
+	err = destination.Close()
+	if err != nil {
+		return "", err
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+//Compute ETag of stored part
+func (l LocalStorageService) partETag(partPath string) (string, error) {
+
+	part, err := os.Open(partPath)
+	if err != nil {
+		return "", err
+	}
+	defer part.Close()
+
+	hash := md5.New()
+	_, err = io.Copy(hash, part)
+	if err != nil {
+		return "", err
+	}
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)) + `"`, nil
+}
+
+func (l LocalStorageService) CompletePresignedUpload(objectKey string, uploadId string, parts []response.UploadedPart) (response.S3Response, error) {
+
+	result := response.S3Response{
+		Filename: filepath.Base(objectKey),
+	}
+
+	dir, err := l.uploadDir(uploadId)
+	if err != nil {
+		return result, err
+	}
+
+	//1. Make sure the upload belongs to the object key
+	storedKey, err := ioutil.ReadFile(filepath.Join(dir, "key"))
+	if err != nil {
+		return result, errors.New(fmt.Sprintf("Upload %v is not found", uploadId))
+	}
+
+	if string(storedKey) != objectKey {
+		return result, errors.New(fmt.Sprintf("Upload %v does not belong to %v", uploadId, objectKey))
+	}
+
+	if len(parts) == 0 {
+		return result, errors.New("Uploaded parts are required")
+	}
+
+	target, err := l.objectPath(objectKey)
+	if err != nil {
+		return result, err
+	}
+
+	//2. Verify parts
+	sort.SliceStable(parts, func(i, j int) bool {
+		return parts[i].PartNumber < parts[j].PartNumber
+	})
+
+	for _, part := range parts {
+		etag, err := l.partETag(filepath.Join(dir, strconv.FormatInt(part.PartNumber, 10)))
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("Part #%v is not uploaded", part.PartNumber))
+		}
+
+		if strings.Trim(etag, `"`) != strings.Trim(part.ETag, `"`) {
+			return result, errors.New(fmt.Sprintf("Part #%v ETag does not match", part.PartNumber))
+		}
+	}
+
+	//3. Concatenate parts into the object
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return result, err
+	}
+
+	destination, err := os.Create(target)
+	if err != nil {
+		return result, err
+	}
+
+	for _, part := range parts {
+		err = func() error {
+			source, err := os.Open(filepath.Join(dir, strconv.FormatInt(part.PartNumber, 10)))
+			if err != nil {
+				return err
+			}
+			defer source.Close()
+
+			_, err = io.Copy(destination, source)
+			return err
+		}()
+
+		if err != nil {
+			_ = destination.Close()
+			_ = os.Remove(target)
+			return result, err
+		}
+	}
+
+	err = destination.Close()
+	if err != nil {
+		return result, err
+	}
+
+	//4. Cleanup parts
+	err = os.RemoveAll(dir)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	result.Success = true
+	result.Filepath = l.objectUrl(objectKey)
+	result.Key = objectKey
+	result.Message = fmt.Sprintf("File %v successfully uploaded", result.Filename)
+
+	return result, nil
+}
+
+func (l LocalStorageService) GetObject(objectKey string) (io.ReadCloser, error) {
+
+	target, err := l.objectPath(objectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(target)
+}
+
+func (l LocalStorageService) ListObjects(prefix string) ([]response.StorageObject, error) {
+
+	root, err := filepath.Abs(l.rootDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []response.StorageObject
+
+	err = filepath.Walk(root, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relative, err := filepath.Rel(root, current)
+		if err != nil {
+			return err
+		}
+
+		objectKey := filepath.ToSlash(relative)
+		if !strings.HasPrefix(objectKey, prefix) {
+			return nil
+		}
+
+		objects = append(objects, response.StorageObject{
+			Key:          objectKey,
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var uploadIdPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+//Keep partial upload state on disk, each upload has an info file & a data file
+type DiskUploadRepository struct {
+	uploadDir string
+	mutex     *sync.Mutex
+	locks     map[string]*uploadLock
+}
+
+//Lock of an upload, it's dropped once nobody holds or waits for it
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+func ConstructDiskUploadRepository(uploadDir string) contracts.ResumableUploadRepository {
+	return &DiskUploadRepository{
+		uploadDir: uploadDir,
+		mutex:     &sync.Mutex{},
+		locks:     make(map[string]*uploadLock),
+	}
+}
+
+func (d DiskUploadRepository) path(uploadId string, extension string) (string, error) {
+	if !uploadIdPattern.MatchString(uploadId) {
+		return "", models.ErrUploadNotFound
+	}
+	return filepath.Join(d.uploadDir, uploadId+extension), nil
+}
+
+//Serialize writes to the same upload, returns the unlock function. Unknown ids are rejected before a lock is kept for them
+func (d DiskUploadRepository) Lock(uploadId string) (func(), error) {
+
+	if !uploadIdPattern.MatchString(uploadId) {
+		return nil, models.ErrUploadNotFound
+	}
+
+	d.mutex.Lock()
+	lock, ok := d.locks[uploadId]
+	if !ok {
+		lock = &uploadLock{}
+		d.locks[uploadId] = lock
+	}
+	lock.refs++
+	d.mutex.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		d.mutex.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(d.locks, uploadId)
+		}
+		d.mutex.Unlock()
+	}, nil
+}
+
+func (d DiskUploadRepository) Create(upload models.Upload) error {
+
+	err := os.MkdirAll(d.uploadDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	dataPath, err := d.path(upload.ID, ".bin")
+	if err != nil {
+		return err
+	}
+
+	data, err := os.OpenFile(dataPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = data.Close()
+	if err != nil {
+		return err
+	}
+
+	return d.Save(upload)
+}
+
+func (d DiskUploadRepository) Find(uploadId string) (models.Upload, error) {
+
+	var upload models.Upload
+
+	infoPath, err := d.path(uploadId, ".info")
+	if err != nil {
+		return upload, err
+	}
+
+	info, err := ioutil.ReadFile(infoPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return upload, models.ErrUploadNotFound
+		}
+		return upload, err
+	}
+
+	err = json.Unmarshal(info, &upload)
+	if err != nil {
+		return upload, err
+	}
+
+	return upload, nil
+}
+
+//Every tracked upload, partial or completed
+func (d DiskUploadRepository) List() ([]models.Upload, error) {
+
+	infoPaths, err := filepath.Glob(filepath.Join(d.uploadDir, "*.info"))
+	if err != nil {
+		return nil, err
+	}
+
+	uploads := make([]models.Upload, 0, len(infoPaths))
+	for _, infoPath := range infoPaths {
+
+		upload, err := d.Find(strings.TrimSuffix(filepath.Base(infoPath), ".info"))
+		if errors.Is(err, models.ErrUploadNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		uploads = append(uploads, upload)
+	}
+
+	return uploads, nil
+}
+
+//Write info into temporary file first, so a crash never leaves a half written info file
+func (d DiskUploadRepository) Save(upload models.Upload) error {
+
+	infoPath, err := d.path(upload.ID, ".info")
+	if err != nil {
+		return err
+	}
+
+	info, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(infoPath+".tmp", info, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(infoPath+".tmp", infoPath)
+}
+
+func (d DiskUploadRepository) WriteChunk(uploadId string, reader io.Reader) (int64, error) {
+
+	dataPath, err := d.path(uploadId, ".bin")
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, models.ErrUploadNotFound
+		}
+		return 0, err
+	}
+
+	written, err := io.Copy(data, reader)
+
+	closeErr := data.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	return written, err
+}
+
+func (d DiskUploadRepository) Open(uploadId string) (io.ReadCloser, error) {
+
+	dataPath, err := d.path(uploadId, ".bin")
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(dataPath)
+}
+
+func (d DiskUploadRepository) RemoveData(uploadId string) error {
+
+	dataPath, err := d.path(uploadId, ".bin")
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(dataPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (d DiskUploadRepository) Delete(uploadId string) error {
+
+	err := d.RemoveData(uploadId)
+	if err != nil {
+		return err
+	}
+
+	infoPath, err := d.path(uploadId, ".info")
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(infoPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return models.ErrUploadNotFound
+		}
+		return errors.New(fmt.Sprintf("Failed removing upload %v, %v", uploadId, err.Error()))
+	}
+
+	return nil
+}
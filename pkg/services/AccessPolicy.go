@@ -0,0 +1,158 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+//Legacy permissions such as "crud" grant one scope per letter
+const legacyPermissionScopes = "crud"
+
+//AccessRule allows its actions to requesters having one of its roles & every one of its scopes.
+//Empty roles or scopes match every requester, "*" matches every role
+type AccessRule struct {
+	Roles     []string `yaml:"roles"`
+	Scopes    []string `yaml:"scopes"`
+	Actions   []string `yaml:"actions"`
+	Condition string   `yaml:"condition"`
+}
+
+//YamlAccessPolicy holds declarative rules read from a YAML file, an action is allowed when any rule allows it
+type YamlAccessPolicy struct {
+	Rules []AccessRule `yaml:"rules"`
+}
+
+func ConstructYamlAccessPolicy(path string) (contracts.AccessPolicy, error) {
+
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParseAccessPolicy(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+func ParseAccessPolicy(document []byte) (*YamlAccessPolicy, error) {
+
+	var policy YamlAccessPolicy
+
+	err := yaml.Unmarshal(document, &policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policy.Rules) == 0 {
+		return nil, errors.New("Access policy has no rules")
+	}
+
+	for i, rule := range policy.Rules {
+		if len(rule.Actions) == 0 {
+			return nil, errors.New(fmt.Sprintf("Access rule %d has no actions", i+1))
+		}
+		if rule.Condition != models.AccessConditionAny && !models.IsCourseRole(rule.Condition) {
+			return nil, errors.New(fmt.Sprintf("Access rule %d has unknown condition %q, expected %q, %q, %q or %q", i+1, rule.Condition,
+				models.AccessConditionAny, models.CourseRoleOwner, models.CourseRoleEditor, models.CourseRoleViewer))
+		}
+	}
+
+	return &policy, nil
+}
+
+//Course role is the role of the requester on the course, empty when they have none
+func (p *YamlAccessPolicy) Allows(authorization middleware.Authorization, action string, courseRole string) bool {
+
+	for _, rule := range p.matchingRules(authorization, action) {
+		if rule.Condition == models.AccessConditionAny || models.CourseRoleIncludes(courseRole, rule.Condition) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *YamlAccessPolicy) MayPerform(authorization middleware.Authorization, action string) bool {
+	return len(p.matchingRules(authorization, action)) > 0
+}
+
+func (p *YamlAccessPolicy) matchingRules(authorization middleware.Authorization, action string) []AccessRule {
+
+	var rules []AccessRule
+	scopes := permissionScopes(authorization.Permission)
+
+	for _, rule := range p.Rules {
+		if rule.matchesRole(authorization.Role) && rule.matchesScopes(scopes) && rule.matchesAction(action) {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules
+}
+
+func (r AccessRule) matchesRole(role string) bool {
+
+	if len(r.Roles) == 0 {
+		return true
+	}
+
+	for _, ruleRole := range r.Roles {
+		if ruleRole == "*" || ruleRole == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r AccessRule) matchesScopes(scopes map[string]bool) bool {
+
+	for _, scope := range r.Scopes {
+		if !scopes[scope] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r AccessRule) matchesAction(action string) bool {
+
+	for _, ruleAction := range r.Actions {
+		if ruleAction == "*" || ruleAction == action {
+			return true
+		}
+		if strings.HasSuffix(ruleAction, ":*") && strings.HasPrefix(action, strings.TrimSuffix(ruleAction, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Scopes are separated by spaces or commas, words made of legacy permission letters also grant each letter
+func permissionScopes(permission string) map[string]bool {
+
+	scopes := make(map[string]bool)
+
+	for _, scope := range strings.FieldsFunc(permission, func(r rune) bool { return r == ' ' || r == ',' }) {
+		scopes[scope] = true
+
+		if strings.Trim(scope, legacyPermissionScopes) == "" {
+			for _, letter := range scope {
+				scopes[string(letter)] = true
+			}
+		}
+	}
+
+	return scopes
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"time"
+)
+
+//Audit log pages are larger than course pages, events are small
+const auditLogPageSize = 50
+
+func (c CourseService) FetchAuditLog(ctx context.Context, request requests.AuditLogRequest) (*response.HttpResponse, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	err := request.Validate()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	filter := models.AuditFilter{Actor: request.Actor, From: request.From, To: request.To}
+
+	//1. Authorize, the whole log is read by those allowed on every course & the log of a course by those allowed on it
+	allowed := c.AccessPolicy.Allows(*authorization, models.ActionAuditRead, "")
+
+	if request.CourseID != "" {
+		courseId, err := primitive.ObjectIDFromHex(request.CourseID)
+		if err != nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Course %v is not a valid course id", request.CourseID),
+			}, nil
+		}
+		filter.CourseID = &courseId
+
+		//Events of purged courses are kept, only those allowed on every course read them
+		if !allowed {
+			course, err := c.DBRepository.FetchByIdWithDeleted(ctx, request.CourseID)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return &response.HttpResponse{
+					StatusCode: http.StatusInternalServerError,
+					Message:    err.Error(),
+				}, err
+			}
+			if err == nil {
+				allowed, err = c.AuthorizeResourceOwner(course, *authorization, models.ActionAuditRead)
+				if err != nil {
+					return &response.HttpResponse{
+						StatusCode: http.StatusInternalServerError,
+						Message:    err.Error(),
+					}, nil
+				}
+			}
+		}
+	}
+
+	if !allowed {
+		return &response.HttpResponse{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "You don't have any permission to read this audit log",
+		}, nil
+	}
+
+	//2. Fetch Events, latest first
+	pagination := models.Pagination{Page: request.Page, PerPage: auditLogPageSize}
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+	limit, skip := pagination.GetPagination()
+
+	events, err := c.AuditRepository.Find(ctx, filter, limit, skip)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Audit log fetched successfully",
+		Data:       events,
+	}, nil
+}
+
+//Audit event of the requester, request id ties it to the request it was made by
+func newAuditEvent(ctx context.Context, action string) models.AuditEvent {
+
+	event := models.AuditEvent{Action: action, CreatedAt: time.Now()}
+
+	if authorization, _ := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization); authorization != nil {
+		event.Actor = authorization.UserID
+		event.ActorRole = authorization.Role
+	}
+	event.RequestID, _ = ctx.Value(middleware.RequestIdKey).(string)
+
+	return event
+}
+
+//Append a mutation of the course to the audit log. The mutation is already stored, so failures are only logged
+func (c CourseService) audit(ctx context.Context, action string, courseId primitive.ObjectID, materialId *primitive.ObjectID, changes []models.FieldChange) {
+	recordMutation(ctx, c.AuditRepository, newAuditEvent(ctx, action), courseId, materialId, changes)
+}
+
+//Append a mutation made by a background job, it's made on behalf of no request
+func auditSystemMutation(ctx context.Context, auditRepository contracts.AuditRepository, action string, courseId primitive.ObjectID, materialId *primitive.ObjectID, changes []models.FieldChange) {
+	event := models.AuditEvent{Actor: models.AuditActorSystem, Action: action, CreatedAt: time.Now()}
+	recordMutation(ctx, auditRepository, event, courseId, materialId, changes)
+}
+
+func recordMutation(ctx context.Context, auditRepository contracts.AuditRepository, event models.AuditEvent, courseId primitive.ObjectID, materialId *primitive.ObjectID, changes []models.FieldChange) {
+
+	event.CourseID = &courseId
+	event.MaterialID = materialId
+	event.Changes = changes
+
+	err := auditRepository.Record(ctx, event)
+	if err != nil {
+		log.Printf("Failed recording %v of course %v by %v, %v", event.Action, courseId.Hex(), event.Actor, err.Error())
+	}
+}
+
+//Position changes of placed materials, named like material fields of DiffCourse
+func placementChanges(course *models.Course, placements []models.MaterialPlacement) []models.FieldChange {
+
+	var changes []models.FieldChange
+
+	for _, placement := range placements {
+		prefix := "materials." + placement.MaterialID.Hex() + "."
+
+		var order interface{}
+		var sectionId *primitive.ObjectID
+		for _, material := range course.Materials {
+			if material.MaterialID == placement.MaterialID {
+				order, sectionId = material.Order, material.SectionID
+			}
+		}
+
+		if order != placement.Order {
+			changes = append(changes, models.FieldChange{Field: prefix + "order", From: order, To: placement.Order})
+		}
+		if auditObjectID(sectionId) != auditObjectID(placement.SectionID) {
+			changes = append(changes, models.FieldChange{Field: prefix + "section_id", From: auditObjectID(sectionId), To: auditObjectID(placement.SectionID)})
+		}
+	}
+
+	return changes
+}
+
+//Changes of section fields, prefixed with "sections.<section id>." like material fields of DiffCourse
+func sectionChanges(sectionId primitive.ObjectID, before interface{}, after interface{}) []models.FieldChange {
+
+	changes := models.DiffFields(before, after, "section_id", "materials", "duration")
+	for i := range changes {
+		changes[i].Field = "sections." + sectionId.Hex() + "." + changes[i].Field
+	}
+
+	return changes
+}
+
+func auditObjectID(id *primitive.ObjectID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return id.Hex()
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	"acourse-course-service/pkg/captions"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+//Caption tracks may end slightly after the video, subtitle editors round their timestamps
+const captionDurationTolerance = time.Second
+
+func (c CourseService) ListCaptions(ctx context.Context, courseId string, materialId string) (*response.HttpResponse, error) {
+
+	//Material visibility & urls follow the course response
+	course, err := c.FetchById(ctx, courseId, []string{})
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	material := c.findMaterial(course, materialId)
+	if material == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	captionTracks := material.Captions
+	if captionTracks == nil {
+		captionTracks = []models.Caption{}
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Captions fetched successfully",
+		Data:       captionTracks,
+	}, nil
+}
+
+func (c CourseService) PutCaption(ctx context.Context, courseId string, materialId string, language string, request requests.PutCaptionRequest) (*response.HttpResponse, error) {
+
+	language, err := captions.NormalizeLanguage(language)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	if request.File.Size > captions.MaxFileSize {
+		return &response.HttpResponse{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Message:    fmt.Sprintf("Caption file may not exceed %v bytes", captions.MaxFileSize),
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	material := c.findMaterial(course, materialId)
+	if material == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	if !models.MaterialKindIsMedia(material.MaterialKind()) {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Material %v is %v, only video & audio materials have captions", materialId, material.MaterialKind()),
+		}, nil
+	}
+
+	//2. Parse SRT/WebVTT & Validate Cues Against Material Duration
+	cues, err := c.readCaptionFile(request)
+	if err == nil {
+		err = captions.Validate(cues, material.Duration, captionDurationTolerance)
+	}
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//3. Store Normalized WebVTT, every revision gets its own key so cached copies are never stale
+	rollback := &Saga{}
+	timeNow := time.Now()
+	key := fmt.Sprintf("%v/captions/%v/%v-%v.vtt", course.CourseID, materialId, language, timeNow.UnixNano())
+
+	uploaded, err := c.StorageService.PutObject(bytes.NewReader(captions.RenderVtt(cues)), key, "text/vtt")
+	if err == nil && !uploaded.Success {
+		err = errors.New(uploaded.Message)
+	}
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	rollback.RecordUpload(c.StorageService, key)
+
+	//4. Replace Existing Track Of The Language Or Add A New One
+	caption := models.Caption{
+		Language:  language,
+		Label:     request.Label,
+		Key:       key,
+		CueCount:  len(cues),
+		UpdatedAt: &timeNow,
+		CreatedAt: &timeNow,
+	}
+
+	var replaced *models.Caption
+	captionTracks := make([]models.Caption, 0, len(material.Captions)+1)
+
+	for _, existing := range material.Captions {
+		if existing.Language == language {
+			existingCopy := existing
+			replaced = &existingCopy
+			caption.CreatedAt = existing.CreatedAt
+			if caption.Label == "" {
+				caption.Label = existing.Label
+			}
+			continue
+		}
+		captionTracks = append(captionTracks, existing)
+	}
+	captionTracks = append(captionTracks, caption)
+
+	sort.SliceStable(captionTracks, func(i, j int) bool {
+		return captionTracks[i].Language < captionTracks[j].Language
+	})
+
+	//5. Save Material Captions
+	failure = c.saveCaptions(ctx, courseId, materialId, material.Captions, captionTracks, rollback)
+	if failure != nil {
+		return failure, nil
+	}
+
+	change := models.FieldChange{Field: "captions." + language, To: caption}
+	if replaced != nil {
+		change.From = *replaced
+	}
+	c.audit(ctx, models.AuditActionPutCaption, course.ID, &material.MaterialID, []models.FieldChange{change})
+
+	//6. Remove Replaced Track
+	if replaced != nil && replaced.Key != key {
+		c.deleteDraftObjects(ctx, courseId, []string{replaced.Key}, nil)
+	}
+
+	caption.Url, err = c.UrlSigner.SignUrl(caption.Key)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	statusCode, message := http.StatusCreated, "Caption uploaded successfully"
+	if replaced != nil {
+		statusCode, message = http.StatusOK, "Caption replaced successfully"
+	}
+
+	return &response.HttpResponse{
+		StatusCode: statusCode,
+		Message:    message,
+		Data:       caption,
+	}, nil
+}
+
+func (c CourseService) DeleteCaption(ctx context.Context, courseId string, materialId string, language string) (*response.HttpResponse, error) {
+
+	language, err := captions.NormalizeLanguage(language)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialUpdate, "You don't have any permission to remove this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	material := c.findMaterial(course, materialId)
+	if material == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	caption := material.FindCaption(language)
+	if caption == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Caption %v is not found", language),
+		}, nil
+	}
+
+	//2. Save Remaining Tracks
+	captionTracks := make([]models.Caption, 0, len(material.Captions))
+	for _, existing := range material.Captions {
+		if existing.Language != language {
+			captionTracks = append(captionTracks, existing)
+		}
+	}
+
+	failure = c.saveCaptions(ctx, courseId, materialId, material.Captions, captionTracks, &Saga{})
+	if failure != nil {
+		return failure, nil
+	}
+
+	c.audit(ctx, models.AuditActionDeleteCaption, course.ID, &material.MaterialID, []models.FieldChange{{Field: "captions." + language, From: *caption}})
+
+	//3. Remove Track From Storage, failures are left for orphan reaper
+	c.deleteDraftObjects(ctx, courseId, []string{caption.Key}, nil)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Caption deleted successfully",
+	}, nil
+}
+
+func (c CourseService) readCaptionFile(request requests.PutCaptionRequest) ([]captions.Cue, error) {
+
+	file, err := request.File.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return captions.Parse(data)
+}
+
+//Only the captions of the material are written, so concurrent material changes aren't overwritten.
+//They're written only while they're still the ones which were read, concurrent caption changes are a conflict
+func (c CourseService) saveCaptions(ctx context.Context, courseId string, materialId string, current []models.Caption, captionTracks []models.Caption, rollback *Saga) *response.HttpResponse {
+
+	saved, err := c.DBRepository.UpdateMaterialFields(ctx, courseId, materialId, map[string]interface{}{"captions": current}, map[string]interface{}{
+		"captions":   captionTracks,
+		"updated_at": time.Now(),
+	})
+	if err == nil && !saved {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusConflict,
+			Message:    fmt.Sprintf("Captions of material %v were changed meanwhile, please retry", materialId),
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}
+	}
+	if err != nil {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (c CourseService) ListCollaborators(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePreview, "You don't have any permission to view this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Owner is listed first, followed by collaborators in order of their invitation
+	collaborators := append([]models.Collaborator{{UserID: course.UserID, Role: models.CourseRoleOwner}}, course.Collaborators...)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Collaborators fetched successfully",
+		Data:       collaborators,
+	}, nil
+}
+
+func (c CourseService) InviteCollaborator(ctx context.Context, courseId string, request requests.InviteCollaboratorRequest) (*response.HttpResponse, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseShare, "You don't have any permission to share this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.CourseRole(request.UserID) != "" {
+		return &response.HttpResponse{
+			StatusCode: http.StatusConflict,
+			Message:    fmt.Sprintf("User %d already collaborates on this course", request.UserID),
+		}, nil
+	}
+
+	//2. Add Collaborator
+	timeNow := time.Now()
+	collaborator := models.Collaborator{
+		UserID:    request.UserID,
+		Role:      request.Role,
+		InvitedBy: authorization.UserID,
+		UpdatedAt: &timeNow,
+		CreatedAt: &timeNow,
+	}
+	course.Collaborators = append(course.Collaborators, collaborator)
+
+	changes := []models.FieldChange{{Field: collaboratorField(collaborator.UserID), To: collaborator.Role}}
+
+	return c.saveCollaborators(ctx, course, models.AuditActionInviteCollaborator, changes, http.StatusCreated, "Collaborator invited successfully", collaborator)
+}
+
+func (c CourseService) UpdateCollaborator(ctx context.Context, courseId string, userId string, request requests.UpdateCollaboratorRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseShare, "You don't have any permission to share this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	collaborator, failure := findCollaborator(course, userId)
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Change Role
+	timeNow := time.Now()
+	changes := []models.FieldChange{{Field: collaboratorField(collaborator.UserID), From: collaborator.Role, To: request.Role}}
+	collaborator.Role = request.Role
+	collaborator.UpdatedAt = &timeNow
+
+	return c.saveCollaborators(ctx, course, models.AuditActionUpdateCollaborator, changes, http.StatusOK, "Collaborator updated successfully", *collaborator)
+}
+
+func (c CourseService) RemoveCollaborator(ctx context.Context, courseId string, userId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseShare, "You don't have any permission to share this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	collaborator, failure := findCollaborator(course, userId)
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Remove Collaborator
+	removed := *collaborator
+	course.RemoveCollaborator(removed.UserID)
+
+	changes := []models.FieldChange{{Field: collaboratorField(removed.UserID), From: removed.Role}}
+
+	return c.saveCollaborators(ctx, course, models.AuditActionRemoveCollaborator, changes, http.StatusOK, "Collaborator removed successfully", removed)
+}
+
+func (c CourseService) TransferOwnership(ctx context.Context, courseId string, request requests.TransferOwnershipRequest) (*response.HttpResponse, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseTransfer, "You don't have any permission to transfer this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.UserID == request.UserID {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("User %d already owns this course", request.UserID),
+		}, nil
+	}
+
+	//2. Hand Over, previous owner keeps editing as collaborator
+	changes := []models.FieldChange{
+		{Field: "user_id", From: course.UserID, To: request.UserID},
+		{Field: collaboratorField(course.UserID), From: models.CourseRoleOwner, To: models.CourseRoleEditor},
+		{Field: collaboratorField(request.UserID), From: nilIfEmpty(course.CourseRole(request.UserID)), To: models.CourseRoleOwner},
+	}
+	course.TransferOwnership(request.UserID, authorization.UserID, time.Now())
+
+	collaborators := append([]models.Collaborator{{UserID: course.UserID, Role: models.CourseRoleOwner}}, course.Collaborators...)
+
+	return c.saveCollaborators(ctx, course, models.ActionCourseTransfer, changes, http.StatusOK, "Ownership transferred successfully", collaborators)
+}
+
+//Store owner & collaborators, changes made meanwhile are never overwritten
+func (c CourseService) saveCollaborators(ctx context.Context, course *models.Course, action string, changes []models.FieldChange, statusCode int, message string, data interface{}) (*response.HttpResponse, error) {
+
+	saved, err := c.DBRepository.SetCollaborators(ctx, course.ID.Hex(), course.Version, course.UserID, course.Collaborators)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusConflict,
+			Message:    "Course was changed meanwhile, please retry",
+		}, nil
+	}
+
+	c.audit(ctx, action, course.ID, nil, changes)
+
+	return &response.HttpResponse{
+		StatusCode: statusCode,
+		Message:    message,
+		Data:       data,
+	}, nil
+}
+
+func findCollaborator(course *models.Course, userId string) (*models.Collaborator, *response.HttpResponse) {
+
+	id, err := strconv.ParseInt(userId, 10, 64)
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("User %v is not a valid user id", userId),
+		}
+	}
+
+	collaborator := course.FindCollaborator(id)
+	if collaborator == nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("User %v doesn't collaborate on this course", userId),
+		}
+	}
+
+	return collaborator, nil
+}
+
+//Collaborators are audited by user id, like material fields of DiffCourse
+func collaboratorField(userId int64) string {
+	return "collaborators." + strconv.FormatInt(userId, 10)
+}
+
+func nilIfEmpty(role string) interface{} {
+	if role == "" {
+		return nil
+	}
+	return role
+}
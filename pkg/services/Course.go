@@ -6,54 +6,115 @@ import (
 	"acourse-course-service/pkg/http/requests"
 	"acourse-course-service/pkg/http/response"
 	"acourse-course-service/pkg/models"
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"regexp"
 	"strconv"
 	"sync"
 	"time"
 )
 
+//TranscodingService is nil when HLS transcoding is disabled, materials are then served progressively
 type CourseService struct {
-	DBRepository     contracts.CourseDatabaseRepository
-	StorageService   contracts.StorageService
-	MediaInfoService contracts.MediaInfoService
+	DBRepository       contracts.CourseDatabaseRepository
+	StorageService     contracts.StorageService
+	MediaInfoService   contracts.MediaInfoService
+	UploadService      contracts.ResumableUploadService
+	UrlSigner          contracts.UrlSigner
+	TranscodingService contracts.TranscodingService
+	MediaPolicy        models.MediaPolicy
+	AccessPolicy       contracts.AccessPolicy
+	AuditRepository    contracts.AuditRepository
 }
 
-func ConstructCourseService(dbRepository *contracts.CourseDatabaseRepository, storageService *contracts.StorageService, mediaInfoService *contracts.MediaInfoService) contracts.CourseService {
+func ConstructCourseService(dbRepository *contracts.CourseDatabaseRepository, storageService *contracts.StorageService, mediaInfoService *contracts.MediaInfoService, uploadService *contracts.ResumableUploadService, urlSigner *contracts.UrlSigner, transcodingService *contracts.TranscodingService, mediaPolicy models.MediaPolicy, accessPolicy *contracts.AccessPolicy, auditRepository *contracts.AuditRepository) contracts.CourseService {
 
 	return &CourseService{
-		DBRepository:     *dbRepository,
-		StorageService:   *storageService,
-		MediaInfoService: *mediaInfoService,
+		DBRepository:       *dbRepository,
+		StorageService:     *storageService,
+		MediaInfoService:   *mediaInfoService,
+		UploadService:      *uploadService,
+		UrlSigner:          *urlSigner,
+		TranscodingService: *transcodingService,
+		MediaPolicy:        mediaPolicy,
+		AccessPolicy:       *accessPolicy,
+		AuditRepository:    *auditRepository,
 	}
 }
 
-func (c CourseService) AuthorizeResourceOwner(model *models.Course, auth middleware.Authorization) (bool, error) {
+//Whether the access policy allows the action on the course, conditional rules apply by the requester's role as owner or collaborator
+func (c CourseService) AuthorizeResourceOwner(model *models.Course, auth middleware.Authorization, action string) (bool, error) {
 
 	userId, err := strconv.ParseInt(auth.UserID, 10, 64)
 	if err != nil {
 		return false, err
 	}
 
-	if model.UserID != userId {
-		//log.Println(model.UserID, userId)
-		return false, nil
-	}
-	return true, nil
+	return c.AccessPolicy.Allows(auth, action, model.CourseRole(userId)), nil
 }
 
 func (c CourseService) Fetch(ctx context.Context, excludeFields []string, pagination models.Pagination) ([]models.Course, error) {
 
 	limit, skip := pagination.GetPagination()
-	return c.DBRepository.Fetch(ctx, excludeFields, limit, skip)
+
+	//Unreleased courses are listed for their owner only
+	var viewerId int64
+	if authorization, _ := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization); authorization != nil {
+		viewerId, _ = strconv.ParseInt(authorization.UserID, 10, 64)
+	}
+
+	courses, err := c.DBRepository.Fetch(ctx, excludeFields, limit, skip, viewerId)
+	if err != nil {
+		return nil, err
+	}
+
+	served := make([]*models.Course, len(courses))
+	for i := range courses {
+		served[i] = &courses[i]
+	}
+
+	err = c.servePublishedRevisions(ctx, served...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range courses {
+		c.hideUnplayableMaterials(ctx, &courses[i])
+		c.signCourseUrls(&courses[i])
+		courses[i].ArrangeMaterials()
+	}
+
+	return courses, nil
 }
 
-func (c CourseService) FetchById(ctx context.Context, id string, excludeFields []string) (models.Course, error) {
-	return c.DBRepository.FetchById(ctx, id, excludeFields)
+func (c CourseService) FetchById(ctx context.Context, id string, excludeFields []string) (*models.Course, error) {
+
+	course, err := c.DBRepository.FetchById(ctx, id, excludeFields)
+	if err != nil {
+		return nil, err
+	}
+
+	//Unreleased course doesn't exist for anyone but those who may preview it, such as its owner
+	if !course.IsReleased && !c.canPreview(ctx, &course) {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	err = c.servePublishedRevisions(ctx, &course)
+	if err != nil {
+		return nil, err
+	}
+
+	c.hideUnplayableMaterials(ctx, &course)
+	c.signCourseUrls(&course)
+	course.ArrangeMaterials()
+
+	return &course, nil
 }
 
 func (c CourseService) Create(ctx context.Context, request requests.CreateCourseRequest) (interface{}, error) {
@@ -70,92 +131,149 @@ func (c CourseService) Create(ctx context.Context, request requests.CreateCourse
 	//	return nil, errors.New("duplicated user id")
 	//}
 
-	//1. Construct Course Model
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	//1. Resolve Owner From The Requester
+	ownerId, err := c.resolveCourseOwner(ctx, *authorization, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	//2. Construct Course Model
 	var course models.Course
 
 	timeNow := time.Now()
 	course.ID = c.DBRepository.GenerateModelID()
 	course.Name = request.Name
-	course.UserID = request.UserID
+	course.UserID = ownerId
 	course.Description = request.Description
-	course.IsReleased = *request.IsReleased
 	course.Price = request.Price
 	course.UpdatedAt = &timeNow
 	course.CreatedAt = &timeNow
 	course.DeletedAt = nil
+	course.Version = 1
+	course.SetReleased(*request.IsReleased, authorization.UserID, timeNow)
 
-	if *request.IsReleased {
-		course.ReleasedAt = &timeNow
-	}
+	course.CourseID = request.Name + "-" + strconv.FormatInt(ownerId, 10)
 
-	course.CourseID = request.Name + "-" + strconv.FormatInt(request.UserID, 10)
+	//Every side effect from here on records its compensation, partial failure removes what was uploaded
+	rollback := &Saga{}
 
-	//2. UploadFiles Video to AWS S3 Bucket
+	//Claim resumable uploads referenced by materials
+	resolvedUploads, failure := c.resolveUploads(ctx, request.Materials, rollback)
+	if failure != nil {
+		return nil, c.compensate(rollback, &models.OperationError{
+			StatusCode: failure.StatusCode,
+			Message:    failure.Message,
+		})
+	}
+
+	//3. UploadFiles Video to AWS S3 Bucket
 	var uploadedMaterialVideo []response.S3Response
-	var err error
+	var materialMediaInfos []*models.MediaInfo
 
-	uploadedMaterialVideo, err = c.StorageService.UploadFiles(request.Files, course.CourseID+"/")
-	if err != nil {
-		return nil, err
+	if len(request.Files) > 0 {
+		uploadedMaterialVideo, materialMediaInfos = c.uploadVideos(request.Files, materialFileKinds(request.Materials, len(request.Files)), course.CourseID+"/")
+	}
+
+	var failedFiles []models.FailedFile
+	for _, uploaded := range uploadedMaterialVideo {
+		if uploaded.Success {
+			rollback.RecordUpload(c.StorageService, uploaded.Key)
+			continue
+		}
+		failedFiles = append(failedFiles, models.FailedFile{Filename: uploaded.Filename, Order: uploaded.Order, Message: uploaded.Message})
+	}
+
+	if len(failedFiles) > 0 {
+		return nil, c.compensate(rollback, &models.OperationError{
+			StatusCode:  http.StatusUnprocessableEntity,
+			Message:     "Failed uploading material videos",
+			FailedFiles: failedFiles,
+		})
 	}
 
 	uploadedCourseThumbnail, err := c.StorageService.UploadFile(request.Image, course.CourseID+"/")
+	if err == nil && !uploadedCourseThumbnail.Success {
+		err = errors.New(uploadedCourseThumbnail.Message)
+	}
 	if err != nil {
-		return nil, err
+		return nil, c.compensate(rollback, &models.OperationError{
+			StatusCode:  http.StatusUnprocessableEntity,
+			Message:     "Failed uploading course thumbnail",
+			FailedFiles: []models.FailedFile{{Filename: request.Image.Filename, Message: err.Error()}},
+		})
 	}
+	rollback.RecordUpload(c.StorageService, uploadedCourseThumbnail.Key)
 	course.ImageKey = uploadedCourseThumbnail.Key
-	//3. Replace Aws s3 url to Aws Cloudfront url
-	course.ImageUrl = c.replaceVideoUrl(uploadedCourseThumbnail.Filepath)
 
 	//4. Construct Course Materials
-	totalDuration := 0
+	var totalDuration time.Duration
+	fileIndexes := requests.MaterialFileIndexes(request.Materials)
 
 	for i := 0; i < len(request.Materials); i++ {
 
-		existingMaterial := func(materialOrder int, collections []response.S3Response) *response.S3Response {
-			for _, material := range collections {
-				if material.Order == materialOrder {
-					return &material
-				}
-			}
-			return nil
-		}(*request.Materials[i].Order, uploadedMaterialVideo)
-
-		//Get Video Duration
-		duration, _ := c.getVideoDuration(request.Files[i])
-
-		course.Materials = append(course.Materials, models.Material{
+		material := models.Material{
 			MaterialID:  c.DBRepository.GenerateModelID(),
 			Name:        request.Materials[i].Name,
-			Duration:    time.Duration(duration),
+			Kind:        request.Materials[i].MaterialKind(),
 			Description: request.Materials[i].Description,
 			Order:       *request.Materials[i].Order,
-			Url:         c.replaceVideoUrl(existingMaterial.Filepath),
-			Key:         existingMaterial.Key,
 			UpdatedAt:   &timeNow,
 			CreatedAt:   &timeNow,
 			DeletedAt:   nil,
-		})
+		}
+		c.applyMaterialContent(&material, request.Materials[i])
+
+		//Material file will be attached later when it's uploaded directly to storage
+		if resolvedUploads[i] != nil {
+			c.attachMaterialFile(&material, resolvedUploads[i].Key, resolvedUploads[i].MediaInfo)
+		} else if fileIndexes[i] >= 0 && fileIndexes[i] < len(request.Files) {
+			c.attachMaterialFile(&material, uploadedMaterialVideo[fileIndexes[i]].Key, materialMediaInfos[fileIndexes[i]])
+		}
 
-		totalDuration += duration
+		totalDuration += material.Duration
+
+		course.Materials = append(course.Materials, material)
 	}
 
-	course.TotalDuration = time.Duration(totalDuration)
+	course.TotalDuration = totalDuration
 
-	//5. Save Course Model to Database
+	//5. New course is published right away, later edits stay in its draft until they're published.
+	//Its revision is stored first, so the course is never served without one
+	err = c.createFirstRevision(ctx, &course, authorization.UserID, rollback)
+	if err != nil {
+		return nil, c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+	}
+
+	//6. Save Course Model to Database
 	courseId, err := c.DBRepository.Create(ctx, &course)
 	if err != nil {
-		return nil, err
+		return nil, c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		})
 	}
 
 	course.ID = courseId
 
+	c.audit(ctx, models.ActionCourseCreate, course.ID, nil, models.DiffCourse(nil, &course))
+
+	c.releaseUploads(resolvedUploads)
+	c.enqueueTranscoding(&course)
+
+	//7. Urls are generated for the response only, they are never stored
+	c.signCourseUrls(&course)
+
 	return course, nil
 }
 
 func (c CourseService) Update(ctx context.Context, request requests.UpdateCourseRequest, courseId string) (*response.HttpResponse, error) {
 
-	authorization := ctx.Value("authorization").(*middleware.Authorization)
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
 
 	//Fetch Course By id
 	var course models.Course
@@ -169,7 +287,7 @@ func (c CourseService) Update(ctx context.Context, request requests.UpdateCourse
 	}
 
 	//Authorize User to check the rights to do manipulation
-	validated, err := c.AuthorizeResourceOwner(&course, *authorization)
+	validated, err := c.AuthorizeResourceOwner(&course, *authorization, models.ActionCourseUpdate)
 	if err != nil {
 		return &response.HttpResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -184,6 +302,13 @@ func (c CourseService) Update(ctx context.Context, request requests.UpdateCourse
 		}, nil
 	}
 
+	//Edits based on an older version would overwrite changes made meanwhile
+	if request.Version != nil && *request.Version != course.Version {
+		return c.versionConflict(&course), nil
+	}
+
+	before := models.AuditFields(&course)
+
 	//Update current request
 	timeNow := time.Now()
 
@@ -194,147 +319,223 @@ func (c CourseService) Update(ctx context.Context, request requests.UpdateCourse
 		course.Description = request.Description
 	}
 	if request.IsReleased != nil {
-		course.IsReleased = *request.IsReleased
+		course.SetReleased(*request.IsReleased, authorization.UserID, timeNow)
 	}
 	if request.Price != nil {
 		course.Price = *request.Price
 	}
 	course.UpdatedAt = &timeNow
 
-	//Update Current Materials
+	//Validate material kinds before anything is uploaded, kind of existing material can't change
+	err = request.ValidateMaterialFiles()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	for _, data := range request.Materials {
+		existingMaterial := c.findMaterial(&course, data.MaterialID.Hex())
+		if existingMaterial != nil && existingMaterial.MaterialKind() != data.MaterialKind() {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is %v, its kind can't be changed", existingMaterial.MaterialID.Hex(), existingMaterial.MaterialKind()),
+			}, nil
+		}
+	}
+
+	//Every side effect from here on records its compensation, partial failure removes what was uploaded
+	rollback := &Saga{}
+
+	//Claim resumable uploads referenced by materials
+	fileIndexes := requests.MaterialFileIndexes(request.Materials)
+	resolvedUploads, failure := c.resolveUploads(ctx, request.Materials, rollback)
+	if failure != nil {
+		rollback.Compensate()
+		return failure, nil
+	}
+
+	//Upload Material Videos Concurrently, results keep the materials order
 	var wg sync.WaitGroup
+	uploadedVideos := make([]*response.S3Response, len(request.Materials))
+	videoMediaInfos := make([]*models.MediaInfo, len(request.Materials))
+	uploadErrors := make([]error, len(request.Materials))
 
 	for i := 0; i < len(request.Materials); i++ {
 
 		wg.Add(1)
 
-		go func(wg *sync.WaitGroup, course *models.Course, data requests.UpdateCourseRequest, i int) {
+		go func(i int) {
 			defer wg.Done()
 
-			//Check Material if exists
-			existingMaterial := func(materialID interface{}, course *models.Course) *models.Material {
-				for i := 0; i < len(course.Materials); i++ {
-					if materialID == course.Materials[i].MaterialID {
-						return &course.Materials[i]
-					}
-				}
-				return nil
-			}(data.Materials[i].MaterialID, course)
-
-			//If Material Exists Update the Material, Otherwise Add New one
-			if existingMaterial != nil {
-
-				log.Println(fmt.Sprintf("updating existing material >> %v", existingMaterial.MaterialID))
-				//Reset Material Ordering
-				materialOrder := data.Materials[i].Order
-				if data.Materials[i].NewOrder != nil {
-					materialOrder = data.Materials[i].NewOrder
-				}
-
-				//Assign New Data to existing material
-				existingMaterial.Name = data.Materials[i].Name
-				existingMaterial.Description = data.Materials[i].Description
-				existingMaterial.Order = *materialOrder
-				existingMaterial.UpdatedAt = &timeNow
-
-				//Update Material Video if exists on request file
-				if len(data.Files) >= (i + 1) {
-
-					log.Println("replacing old video")
-
-					//UploadFiles new Video
-					uploadedNewVideo, err := c.StorageService.UploadFile(data.Files[i], course.CourseID+"/")
-					if err != nil {
-						log.Fatal(err.Error())
-						//return false, err
-					}
-
-					//Delete Old Video
-					if existingMaterial.Key != "" {
-						err = c.StorageService.Delete(existingMaterial.Key)
-						if err != nil {
-							log.Fatal(err.Error())
-							//return false, err
-						}
-					}
-
-					//Decrease Course Total Duration by Material Duration
-					course.SubTotalDuration(existingMaterial.Duration)
-					//Renew Video Url
-					existingMaterial.Url = c.replaceVideoUrl(uploadedNewVideo.Filepath)
-					existingMaterial.Key = uploadedNewVideo.Key
-					//Renew Duration Information
-					duration, _ := c.getVideoDuration(data.Files[i])
-					existingMaterial.Duration = time.Duration(duration)
-					//Re-Adding Total Duration
-					course.AddTotalDuration(time.Duration(duration))
-
-					log.Println("updating material request is done")
-				}
-
-			} else {
-
-				log.Println("adding new material")
-				var err error
-				var newVideoKey string
-				var uploadedNewVideo response.S3Response
-				var newVideoDuration int
-				var cloudfrontVideoUrl string
-
-				if len(data.Files) >= (i + 1) {
-
-					log.Println("uploading new video")
-
-					uploadedNewVideo, err = c.StorageService.UploadFile(data.Files[i], course.CourseID+"/")
-					if err != nil {
-						//return false, err
-					}
-
-					newVideoKey = uploadedNewVideo.Key
-
-					newVideoDuration, _ = c.getVideoDuration(data.Files[i])
-					cloudfrontVideoUrl = c.replaceVideoUrl(uploadedNewVideo.Filepath)
-				}
-
-				//Otherwise, Add New Material
-				course.Materials = append(course.Materials, models.Material{
-					MaterialID:  c.DBRepository.GenerateModelID(),
-					Name:        data.Materials[i].Name,
-					Order:       *data.Materials[i].Order,
-					Description: data.Materials[i].Description,
-					Key:         newVideoKey,
-					Url:         cloudfrontVideoUrl,
-					Duration:    time.Duration(newVideoDuration),
-					UpdatedAt:   &timeNow,
-					CreatedAt:   &timeNow,
-				})
-
-				course.AddTotalDuration(time.Duration(newVideoDuration))
-
-				log.Println("adding new material is done")
+			uploaded, mediaInfo, err := c.materialVideo(request.Files, fileIndexes[i], request.Materials[i].MaterialKind(), resolvedUploads[i], course.CourseID+"/")
+			if err == nil && uploaded != nil && !uploaded.Success {
+				err = errors.New(uploaded.Message)
 			}
-		}(&wg, &course, request, i)
+			if err != nil {
+				uploadErrors[i] = err
+				return
+			}
+
+			//Resumable uploads are kept by the upload service until they're attached
+			if uploaded != nil && resolvedUploads[i] == nil {
+				rollback.RecordUpload(c.StorageService, uploaded.Key)
+			}
+
+			uploadedVideos[i] = uploaded
+			videoMediaInfos[i] = mediaInfo
+		}(i)
 	}
 
 	wg.Wait()
 
-	_, err = c.DBRepository.Update(ctx, course, courseId)
-	if err != nil {
+	var failedFiles []models.FailedFile
+	for i, err := range uploadErrors {
+		if err == nil {
+			continue
+		}
+
+		failedFile := models.FailedFile{Order: *request.Materials[i].Order, Message: err.Error()}
+		if fileIndexes[i] >= 0 && fileIndexes[i] < len(request.Files) {
+			failedFile.Filename = request.Files[fileIndexes[i]].Filename
+		}
+		failedFiles = append(failedFiles, failedFile)
+	}
+
+	if len(failedFiles) > 0 {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode:  http.StatusUnprocessableEntity,
+			Message:     "Failed uploading material videos",
+			FailedFiles: failedFiles,
+		})
 		return &response.HttpResponse{
-			StatusCode: http.StatusInternalServerError,
-			Message:    err.Error(),
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
 		}, nil
 	}
 
+	//Update Current Materials, replaced videos are removed only after the course is saved
+	var replacedMaterials []models.Material
+
+	for i, data := range request.Materials {
+
+		//Check Material if exists
+		existingMaterial := c.findMaterial(&course, data.MaterialID.Hex())
+
+		//If Material Exists Update the Material, Otherwise Add New one
+		if existingMaterial != nil {
+
+			log.Println(fmt.Sprintf("updating existing material >> %v", existingMaterial.MaterialID))
+			//Reset Material Ordering
+			materialOrder := data.Order
+			if data.NewOrder != nil {
+				materialOrder = data.NewOrder
+			}
+
+			//Assign New Data to existing material
+			existingMaterial.Name = data.Name
+			existingMaterial.Description = data.Description
+			existingMaterial.Order = *materialOrder
+			existingMaterial.UpdatedAt = &timeNow
+
+			//Decrease Course Total Duration by Material Duration
+			course.SubTotalDuration(existingMaterial.Duration)
+
+			c.applyMaterialContent(existingMaterial, data)
+
+			//Update Material File if exists on request file or resumable upload
+			if uploadedVideos[i] != nil {
+
+				log.Println("replacing old file")
+
+				replacedMaterials = append(replacedMaterials, *existingMaterial)
+
+				//Renew File Key & Media Information
+				c.attachMaterialFile(existingMaterial, uploadedVideos[i].Key, videoMediaInfos[i])
+			}
+
+			//Re-Adding Total Duration
+			course.AddTotalDuration(existingMaterial.Duration)
+
+		} else {
+
+			log.Println("adding new material")
+			var newVideoKey string
+
+			if uploadedVideos[i] != nil {
+				newVideoKey = uploadedVideos[i].Key
+			}
+
+			//Otherwise, Add New Material
+			material := models.Material{
+				MaterialID:  c.DBRepository.GenerateModelID(),
+				Name:        data.Name,
+				Kind:        data.MaterialKind(),
+				Order:       *data.Order,
+				Description: data.Description,
+				UpdatedAt:   &timeNow,
+				CreatedAt:   &timeNow,
+			}
+			c.applyMaterialContent(&material, data)
+			if newVideoKey != "" {
+				c.attachMaterialFile(&material, newVideoKey, videoMediaInfos[i])
+			}
+
+			course.Materials = append(course.Materials, material)
+
+			course.AddTotalDuration(material.Duration)
+		}
+	}
+
+	//Saved only while the course is still at the version it was read at
+	saved, err := c.DBRepository.Update(ctx, &course, courseId)
+	if err != nil || !saved {
+		operationError := &models.OperationError{
+			StatusCode: http.StatusConflict,
+			Message:    "Course was changed meanwhile, please retry",
+		}
+		if err != nil {
+			operationError.StatusCode, operationError.Message = http.StatusInternalServerError, err.Error()
+		}
+		operationError = c.compensate(rollback, operationError)
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionCourseUpdate, course.ID, nil, models.DiffCourse(before, &course))
+
+	//Remove Replaced Videos
+	for _, material := range replacedMaterials {
+		c.deleteMaterialVideoObjects(ctx, courseId, material)
+	}
+
+	c.releaseUploads(resolvedUploads)
+	c.enqueueTranscoding(&course)
+
 	return &response.HttpResponse{
 		StatusCode: http.StatusOK,
 		Message:    "Updated successfully",
+		Data:       models.CourseVersion{ID: course.ID, Version: course.Version + 1},
 	}, nil
 }
 
+//Course changed since the version the request expects, the current version is returned to retry with
+func (s CourseService) versionConflict(course *models.Course) *response.HttpResponse {
+	return &response.HttpResponse{
+		StatusCode: http.StatusConflict,
+		Message:    fmt.Sprintf("Course was changed meanwhile, its current version is %d", course.Version),
+		Data:       models.CourseVersion{ID: course.ID, Version: course.Version},
+	}
+}
+
 func (c CourseService) DeleteMaterials(ctx context.Context, course_id string, data requests.DeleteMaterialsRequest) (*response.HttpResponse, error) {
 
-	authorization := ctx.Value("authorization").(*middleware.Authorization)
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
 
 	course, err := c.DBRepository.FetchById(ctx, course_id, []string{})
 	if err != nil {
@@ -344,7 +545,7 @@ func (c CourseService) DeleteMaterials(ctx context.Context, course_id string, da
 		}, err
 	}
 
-	validated, err := c.AuthorizeResourceOwner(&course, *authorization)
+	validated, err := c.AuthorizeResourceOwner(&course, *authorization, models.ActionMaterialDelete)
 	if err != nil {
 		return &response.HttpResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -359,45 +560,42 @@ func (c CourseService) DeleteMaterials(ctx context.Context, course_id string, da
 		}, nil
 	}
 
-	for _, materialId := range data.MaterialIDs {
-
-		foundMaterial := func(course *models.Course, targetID string) *models.Material {
-			for _, material := range course.Materials {
-				if material.MaterialID.Hex() == targetID {
-					return &material
-				}
-			}
-			return nil
-		}(&course, materialId)
-
-		if foundMaterial != nil {
-			err = c.StorageService.Delete(foundMaterial.Key)
-			if err != nil {
-				log.Println(err.Error())
-			}
+	//Materials are moved into trash with their files, unknown ids are ignored
+	timeNow := time.Now()
+	var trashedMaterials []models.Material
+	trashedIds := make(map[string]bool)
 
-			//Decrease Duration
-			course.SubTotalDuration(foundMaterial.Duration)
+	for _, materialId := range data.MaterialIDs {
+		foundMaterial := c.findMaterial(&course, materialId)
+		if foundMaterial == nil || trashedIds[materialId] {
+			continue
 		}
-	}
+		trashedIds[materialId] = true
 
-	timeNow := time.Now()
-	course.UpdatedAt = &timeNow
+		trashedMaterial := *foundMaterial
+		trashedMaterial.DeletedAt = &timeNow
+		trashedMaterials = append(trashedMaterials, trashedMaterial)
+	}
 
-	_, err = c.DBRepository.Update(ctx, course, course_id)
-	if err != nil {
-		return &response.HttpResponse{
-			StatusCode: http.StatusInternalServerError,
-			Message:    err.Error(),
-		}, err
+	if len(trashedMaterials) > 0 {
+		trashed, err := c.DBRepository.TrashMaterials(ctx, course_id, trashedMaterials)
+		if err != nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Message:    err.Error(),
+			}, err
+		}
+		if !trashed {
+			return &response.HttpResponse{
+				StatusCode: http.StatusConflict,
+				Message:    "Course materials were changed meanwhile, please retry",
+			}, nil
+		}
 	}
 
-	_, err = c.DBRepository.DeleteMaterials(ctx, course_id, data.MaterialIDs)
-	if err != nil {
-		return &response.HttpResponse{
-			StatusCode: http.StatusInternalServerError,
-			Message:    err.Error(),
-		}, err
+	for _, material := range trashedMaterials {
+		materialId := material.MaterialID
+		c.audit(ctx, models.ActionMaterialDelete, course.ID, &materialId, []models.FieldChange{{Field: "deleted_at", To: timeNow}})
 	}
 
 	return &response.HttpResponse{
@@ -408,7 +606,7 @@ func (c CourseService) DeleteMaterials(ctx context.Context, course_id string, da
 
 func (c CourseService) DeleteCourse(ctx context.Context, course_id string) (*response.HttpResponse, error) {
 
-	authorization := ctx.Value("authorization").(*middleware.Authorization)
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
 
 	//1. Fetch Course
 	course, err := c.DBRepository.FetchById(ctx, course_id, []string{
@@ -420,7 +618,7 @@ func (c CourseService) DeleteCourse(ctx context.Context, course_id string) (*res
 		}, err
 	}
 
-	validated, err := c.AuthorizeResourceOwner(&course, *authorization)
+	validated, err := c.AuthorizeResourceOwner(&course, *authorization, models.ActionCourseDelete)
 	if err != nil {
 		return &response.HttpResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -435,28 +633,23 @@ func (c CourseService) DeleteCourse(ctx context.Context, course_id string) (*res
 		}, nil
 	}
 
-	//2. Delete All Material Video From Storage Service
-	for _, material := range course.Materials {
-		_ = c.StorageService.Delete(material.Key)
-		//if err != nil {
-		//	return false, err
-		//}
-	}
-
-	//3. Delete Course's Thumbnail
-	_ = c.StorageService.Delete(course.ImageKey)
-	//if err != nil {
-	//	return false, err
-	//}
-
-	//4. Delete Course Data from Database
-	_, err = c.DBRepository.DeleteCourse(ctx, course_id)
+	//2. Move Course Into Trash, its files are kept until retention purge
+	deletedAt := time.Now()
+	trashed, err := c.DBRepository.TrashCourse(ctx, course_id, deletedAt)
 	if err != nil {
 		return &response.HttpResponse{
 			StatusCode: http.StatusInternalServerError,
 			Message:    err.Error(),
 		}, err
 	}
+	if !trashed {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not found", course_id),
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionCourseDelete, course.ID, nil, []models.FieldChange{{Field: "deleted_at", To: deletedAt}})
 
 	return &response.HttpResponse{
 		StatusCode: http.StatusOK,
@@ -465,22 +658,449 @@ func (c CourseService) DeleteCourse(ctx context.Context, course_id string) (*res
 
 }
 
-func (s CourseService) replaceVideoUrl(url string) string {
-	var regex, _ = regexp.Compile(`https://acourse-course-videos.s3.ap-southeast-1.amazonaws.com`)
-	return regex.ReplaceAllString(url, "https://d1wvipirze38am.cloudfront.net")
+//Undo recorded side effects & attach the outcome to operation error
+func (s CourseService) compensate(rollback *Saga, operationError *models.OperationError) *models.OperationError {
+
+	operationError.Compensated, operationError.CompensationErrors = rollback.Compensate()
+
+	return operationError
 }
 
-func (s CourseService) getVideoDuration(file *multipart.FileHeader) (int, error) {
+//Generate course & material urls from their object keys
+func (s CourseService) signCourseUrls(course *models.Course) {
 
-	duration, err := s.MediaInfoService.GetVideoDuration(file)
+	var err error
+
+	course.ImageUrl, err = s.UrlSigner.SignUrl(course.ImageKey)
 	if err != nil {
-		return 0, err
+		log.Println(err.Error())
+	}
+
+	for i := range course.Materials {
+		s.signMaterialUrls(&course.Materials[i])
 	}
+}
+
+func (s CourseService) signMaterialUrls(material *models.Material) {
 
-	_duration, err := strconv.Atoi(duration)
+	var err error
+
+	material.Url, err = s.UrlSigner.SignUrl(material.Key)
 	if err != nil {
-		return 0, err
+		log.Println(err.Error())
+	}
+
+	//Renditions & segments are referenced relatively by the master playlist
+	material.PlaylistUrl, err = s.UrlSigner.SignPrefixUrl(keyDirectory(material.PlaylistKey), material.PlaylistKey)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	material.PosterUrl, err = s.UrlSigner.SignUrl(material.PosterKey)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	//Sprite is referenced relatively by the thumbnail track
+	material.ThumbnailsUrl, err = s.UrlSigner.SignPrefixUrl(keyDirectory(material.ThumbnailsKey), material.ThumbnailsKey)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	for i := range material.Captions {
+		material.Captions[i].Url, err = s.UrlSigner.SignUrl(material.Captions[i].Key)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+//Students only see materials which can be played & quizzes without answers, owners & whoever may preview the course see every material with its status.
+//Collaborators are listed for them only as well
+func (s CourseService) hideUnplayableMaterials(ctx context.Context, course *models.Course) {
+
+	if s.canPreview(ctx, course) {
+		return
+	}
+
+	course.Collaborators = nil
+
+	playable := make([]models.Material, 0, len(course.Materials))
+	for _, material := range course.Materials {
+		if !material.IsPlayable() {
+			continue
+		}
+		if material.Quiz != nil {
+			material.Quiz = material.Quiz.WithoutAnswers()
+		}
+		playable = append(playable, material)
+	}
+	course.Materials = playable
+}
+
+//Courses belong to the requester, creating one for another user is impersonation which is audited whether it's allowed or not
+func (s CourseService) resolveCourseOwner(ctx context.Context, authorization middleware.Authorization, requestedOwner int64) (int64, error) {
+
+	requesterId, err := strconv.ParseInt(authorization.UserID, 10, 64)
+	if err != nil {
+		return 0, &models.OperationError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    fmt.Sprintf("User %v can't own courses", authorization.UserID),
+		}
+	}
+
+	if requestedOwner == 0 || requestedOwner == requesterId {
+		return requesterId, nil
+	}
+
+	allowed := s.AccessPolicy.Allows(authorization, models.ActionCourseImpersonate, "")
+
+	event := newAuditEvent(ctx, models.ActionCourseImpersonate)
+	event.OnBehalfOf = strconv.FormatInt(requestedOwner, 10)
+	event.Outcome = models.AuditOutcomeDenied
+	if allowed {
+		event.Outcome = models.AuditOutcomeAllowed
+	}
+
+	//Impersonation isn't allowed unless it's on record
+	err = s.AuditRepository.Record(ctx, event)
+	if err != nil {
+		log.Printf("Failed recording impersonation of %v by %v, %v", event.OnBehalfOf, event.Actor, err.Error())
+		return 0, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "Failed recording audit event",
+		}
+	}
+
+	if !allowed {
+		return 0, &models.OperationError{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "You don't have any permission to create courses for other users",
+		}
+	}
+
+	return requestedOwner, nil
+}
+
+//Whether the requester may see the course as it's edited, anonymous requests never may
+func (s CourseService) canPreview(ctx context.Context, course *models.Course) bool {
+
+	authorization, _ := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+	if authorization == nil {
+		return false
+	}
+
+	allowed, _ := s.AuthorizeResourceOwner(course, *authorization, models.ActionCoursePreview)
+	return allowed
+}
+
+//New material video has to be transcoded before it's playable
+func (s CourseService) prepareTranscoding(material *models.Material) {
+
+	if s.TranscodingService == nil || material.Key == "" || material.MaterialKind() != models.MaterialKindVideo {
+		return
+	}
+
+	material.Status = models.MaterialStatusPending
+	material.StatusError = ""
+	material.PlaylistKey = ""
+	material.PosterKey = ""
+	material.ThumbnailsKey = ""
+}
+
+//Queue pending materials once the course is saved
+func (s CourseService) enqueueTranscoding(course *models.Course) {
+
+	if s.TranscodingService == nil {
+		return
+	}
+
+	for _, material := range course.Materials {
+		if material.Status != models.MaterialStatusPending {
+			continue
+		}
+
+		s.TranscodingService.Enqueue(models.TranscodeJob{
+			CourseID:     course.ID.Hex(),
+			CoursePrefix: course.CourseID + "/",
+			MaterialID:   material.MaterialID.Hex(),
+			Key:          material.Key,
+		})
+	}
+}
+
+//Remove material video, its HLS renditions & previews, captions are kept when only the video is replaced
+func (s CourseService) deleteMaterialVideoObjects(ctx context.Context, courseId string, material models.Material) {
+
+	var keys []string
+	if material.Key != "" {
+		keys = append(keys, material.Key)
+	}
+
+	s.deleteDraftObjects(ctx, courseId, keys, material.StoragePrefixes())
+}
+
+//Upload material file, videos & audio are probed in the same pass and files rejected by media policy are removed again
+func (s CourseService) uploadVideo(file *multipart.FileHeader, kind string, prefix string) (response.S3Response, *models.MediaInfo, error) {
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return response.S3Response{}, nil, err
+	}
+	defer openedFile.Close()
+
+	//Documents are checked by their extension & signature, they have no media info
+	if kind == models.MaterialKindDocument {
+		reader := bufio.NewReader(openedFile)
+		head, _ := reader.Peek(512)
+
+		err = models.ValidateDocument(file.Filename, head)
+		if err != nil {
+			return response.S3Response{}, nil, err
+		}
+
+		uploaded, err := s.StorageService.UploadReader(reader, file.Filename, prefix)
+		return uploaded, nil, err
+	}
+
+	probe, err := s.MediaInfoService.NewProbe()
+	if err != nil {
+		return response.S3Response{}, nil, err
+	}
+
+	defer func(probe contracts.MediaProbe) {
+		err := probe.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(probe)
+
+	uploaded, err := s.StorageService.UploadReader(io.TeeReader(openedFile, probe), file.Filename, prefix)
+	if err != nil || !uploaded.Success {
+		return uploaded, nil, err
+	}
+
+	var mediaInfo *models.MediaInfo
+	info, err := probe.Info()
+	if err != nil {
+		log.Println(err.Error())
+	} else {
+		mediaInfo = &info
+	}
+
+	err = s.MediaPolicy.ValidateKind(kind, mediaInfo)
+	if err != nil {
+		deleteErr := s.StorageService.Delete(uploaded.Key)
+		if deleteErr != nil {
+			log.Println(deleteErr.Error())
+		}
+		return uploaded, nil, err
+	}
+
+	return uploaded, mediaInfo, nil
+}
+
+//Material file comes either from a finished resumable upload or from request files
+func (s CourseService) materialVideo(files []*multipart.FileHeader, fileIndex int, kind string, upload *models.Upload, prefix string) (*response.S3Response, *models.MediaInfo, error) {
+
+	if upload != nil {
+		return &response.S3Response{
+			Filename: upload.Filename,
+			Success:  true,
+			Filepath: upload.Filepath,
+			Key:      upload.Key,
+		}, upload.MediaInfo, nil
+	}
+
+	if fileIndex < 0 || fileIndex >= len(files) {
+		return nil, nil, nil
+	}
+
+	uploaded, mediaInfo, err := s.uploadVideo(files[fileIndex], kind, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &uploaded, mediaInfo, nil
+}
+
+//Kind of the material each request file belongs to
+func materialFileKinds(materials []requests.CreateMaterialRequest, totalFiles int) []string {
+
+	kinds := make([]string, totalFiles)
+
+	for i, fileIndex := range requests.MaterialFileIndexes(materials) {
+		if fileIndex >= 0 && fileIndex < totalFiles {
+			kinds[fileIndex] = materials[i].MaterialKind()
+		}
+	}
+
+	return kinds
+}
+
+//Apply kind specific content, media durations are set once their file is attached
+func (s CourseService) applyMaterialContent(material *models.Material, data requests.CreateMaterialRequest) {
+
+	if data.Url != "" {
+		material.LinkUrl = data.Url
+	}
+	if data.Content != "" {
+		material.Content = data.Content
+	}
+	if data.Quiz != nil {
+		material.Quiz = data.Quiz
+	}
+
+	if models.MaterialKindIsMedia(material.MaterialKind()) {
+		return
+	}
+
+	//Other kinds take the given duration, articles & quizzes are estimated again when their content changes
+	if data.Duration != nil {
+		material.Duration = time.Duration(*data.Duration) * time.Second
+	} else if data.Content != "" || data.Quiz != nil {
+		material.Duration = material.EstimatedDuration()
+	}
+}
+
+//Attach stored file to material, videos & audio take their duration from the probed media info
+func (s CourseService) attachMaterialFile(material *models.Material, key string, mediaInfo *models.MediaInfo) {
+
+	material.Key = key
+
+	if models.MaterialKindIsMedia(material.MaterialKind()) {
+		material.SetMediaInfo(mediaInfo)
+	}
+
+	s.prepareTranscoding(material)
+}
+
+//Claim finished resumable uploads, nil for materials without upload id. Every claim is recorded on rollback,
+//uploads claimed by a concurrent request are a conflict
+func (s CourseService) resolveUploads(ctx context.Context, materials []requests.CreateMaterialRequest, rollback *Saga) ([]*models.Upload, *response.HttpResponse) {
+
+	uploads := make([]*models.Upload, len(materials))
+
+	for i, material := range materials {
+
+		if material.UploadID == "" {
+			continue
+		}
+
+		upload, err := s.UploadService.Resolve(ctx, material.UploadID)
+		if err != nil {
+			statusCode := http.StatusBadRequest
+			if errors.Is(err, models.ErrUploadClaimed) {
+				statusCode = http.StatusConflict
+			}
+			return nil, &response.HttpResponse{
+				StatusCode: statusCode,
+				Message:    fmt.Sprintf("Upload %v can't be used, %v", material.UploadID, err.Error()),
+			}
+		}
+		rollback.RecordClaim(s.UploadService, upload.ID)
+
+		//Upload was probed before its material kind was known
+		err = s.validateStoredFile(material.MaterialKind(), upload.Filename, nil, upload.MediaInfo)
+		if err != nil {
+			return nil, &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Upload %v can't be used, %v", material.UploadID, err.Error()),
+			}
+		}
+		uploads[i] = &upload
+	}
+
+	return uploads, nil
+}
+
+//Uploads attached to materials are no longer tracked as resumable uploads
+func (s CourseService) releaseUploads(uploads []*models.Upload) {
+	for _, upload := range uploads {
+		if upload == nil {
+			continue
+		}
+
+		err := s.UploadService.Release(upload.ID)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+//Upload videos concurrently, results keep the files order
+func (s CourseService) uploadVideos(files []*multipart.FileHeader, kinds []string, prefix string) ([]response.S3Response, []*models.MediaInfo) {
+
+	var wg sync.WaitGroup
+	uploaded := make([]response.S3Response, len(files))
+	mediaInfos := make([]*models.MediaInfo, len(files))
+
+	for i, file := range files {
+
+		wg.Add(1)
+
+		go func(i int, file *multipart.FileHeader) {
+			defer wg.Done()
+
+			result, mediaInfo, err := s.uploadVideo(file, kinds[i], prefix)
+			if err != nil {
+				result.Filename = file.Filename
+				result.Success = false
+				result.Message = err.Error()
+			}
+
+			result.Order = i
+			uploaded[i] = result
+			mediaInfos[i] = mediaInfo
+		}(i, file)
+	}
+
+	wg.Wait()
+
+	return uploaded, mediaInfos
+}
+
+//Probe media info of object that is already stored
+func (s CourseService) getObjectMediaInfo(objectKey string) (*models.MediaInfo, error) {
+
+	object, err := s.StorageService.GetObject(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	mediaInfo, err := s.MediaInfoService.GetReaderInfo(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mediaInfo, nil
+}
+
+//Validate file which is already stored against its material kind
+func (s CourseService) validateStoredFile(kind string, filename string, head []byte, mediaInfo *models.MediaInfo) error {
+
+	if kind == models.MaterialKindDocument {
+		return models.ValidateDocument(filename, head)
+	}
+
+	return s.MediaPolicy.ValidateKind(kind, mediaInfo)
+}
+
+//First bytes of stored object, used to check document signatures
+func (s CourseService) getObjectHead(objectKey string) ([]byte, error) {
+
+	object, err := s.StorageService.GetObject(objectKey)
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(object, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
 	}
 
-	return _duration / 1000, nil
+	return head[:n], nil
 }
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Thumbnail sprite sheet layout, interval grows for long videos so one sheet is enough
+const (
+	thumbnailWidth       = 160
+	thumbnailHeight      = 90
+	thumbnailColumns     = 10
+	maxThumbnails        = 100
+	minThumbnailInterval = 5 * time.Second
+)
+
+//Transcode videos by running local ffmpeg & ffprobe binaries
+type FfmpegTranscoder struct {
+	ffmpegPath      string
+	ffprobePath     string
+	renditions      []models.Rendition
+	segmentDuration int
+}
+
+func ConstructFfmpegTranscoder(ffmpegPath string, ffprobePath string, renditions []models.Rendition, segmentDuration int) contracts.Transcoder {
+	return &FfmpegTranscoder{
+		ffmpegPath:      ffmpegPath,
+		ffprobePath:     ffprobePath,
+		renditions:      renditions,
+		segmentDuration: segmentDuration,
+	}
+}
+
+//Audio is mapped into every rendition only when the source has it
+func (f FfmpegTranscoder) hasAudio(ctx context.Context, input string) (bool, error) {
+
+	output, err := exec.CommandContext(ctx, f.ffprobePath,
+		"-v", "error", "-select_streams", "a", "-show_entries", "stream=index", "-of", "csv=p=0", input).Output()
+	if err != nil {
+		return false, err
+	}
+
+	return len(bytes.TrimSpace(output)) > 0, nil
+}
+
+//Produce <outputDir>/master.m3u8 & one <outputDir>/<rendition>/index.m3u8 playlist with segments per rendition
+func (f FfmpegTranscoder) TranscodeHls(ctx context.Context, input string, outputDir string) error {
+
+	if len(f.renditions) == 0 {
+		return errors.New("No HLS rendition is configured")
+	}
+
+	audio, err := f.hasAudio(ctx, input)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Failed probing %v, %v", filepath.Base(input), err.Error()))
+	}
+
+	//1. Split video into every rendition size
+	split := fmt.Sprintf("[0:v]split=%d", len(f.renditions))
+	var scales []string
+	for i, rendition := range f.renditions {
+		split += fmt.Sprintf("[v%d]", i)
+		scales = append(scales, fmt.Sprintf("[v%d]scale=-2:%d[v%dout]", i, rendition.Height, i))
+	}
+
+	args := []string{"-i", input,
+		"-filter_complex", split + ";" + strings.Join(scales, ";")}
+
+	//2. Encode each rendition, keyframes are aligned so renditions can be switched between segments
+	var streamMap []string
+	for i, rendition := range f.renditions {
+
+		index := strconv.Itoa(i)
+
+		err := os.MkdirAll(filepath.Join(outputDir, rendition.Name), 0755)
+		if err != nil {
+			return err
+		}
+
+		args = append(args,
+			"-map", "[v"+index+"out]",
+			"-c:v:"+index, "libx264",
+			"-b:v:"+index, fmt.Sprintf("%dk", rendition.VideoBitrate),
+			"-maxrate:v:"+index, fmt.Sprintf("%dk", rendition.VideoBitrate*107/100),
+			"-bufsize:v:"+index, fmt.Sprintf("%dk", rendition.VideoBitrate*3/2),
+		)
+
+		if audio {
+			args = append(args,
+				"-map", "a:0",
+				"-c:a:"+index, "aac",
+				"-b:a:"+index, fmt.Sprintf("%dk", rendition.AudioBitrate),
+				"-ac:a:"+index, "2",
+			)
+			streamMap = append(streamMap, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, rendition.Name))
+		} else {
+			streamMap = append(streamMap, fmt.Sprintf("v:%d,name:%s", i, rendition.Name))
+		}
+	}
+
+	args = append(args,
+		"-preset", "veryfast",
+		"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(f.segmentDuration),
+		"-hls_playlist_type", "vod",
+		"-hls_flags", "independent_segments",
+		"-hls_segment_filename", filepath.Join(outputDir, "%v", "segment_%04d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(outputDir, "%v", "index.m3u8"),
+	)
+
+	//3. Run ffmpeg
+	err = f.run(ctx, args...)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(filepath.Join(outputDir, "master.m3u8"))
+	if err != nil {
+		return errors.New("ffmpeg did not produce master playlist")
+	}
+
+	return nil
+}
+
+func (f FfmpegTranscoder) probeDuration(ctx context.Context, input string) (time.Duration, error) {
+
+	output, err := exec.CommandContext(ctx, f.ffprobePath,
+		"-v", "error", "-show_entries", "format=duration", "-of", "csv=p=0", input).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid duration of %v", filepath.Base(input)))
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+func (f FfmpegTranscoder) run(ctx context.Context, args ...string) error {
+
+	var stderr bytes.Buffer
+	command := exec.CommandContext(ctx, f.ffmpegPath, append([]string{"-hide_banner", "-loglevel", "error", "-y"}, args...)...)
+	command.Stderr = &stderr
+
+	err := command.Run()
+	if err != nil {
+		return errors.New(fmt.Sprintf("ffmpeg failed, %v %v", err.Error(), strings.TrimSpace(stderr.String())))
+	}
+
+	return nil
+}
+
+//Produce <outputDir>/poster.jpg, <outputDir>/sprite.jpg & <outputDir>/thumbnails.vtt pointing into the sprite
+func (f FfmpegTranscoder) ExtractPreviews(ctx context.Context, input string, outputDir string) error {
+
+	duration, err := f.probeDuration(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if duration <= 0 {
+		return errors.New("Video has no duration")
+	}
+
+	err = os.MkdirAll(outputDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	//1. Poster frame is taken a bit into the video, the first frames are often black
+	posterOffset := duration / 10
+	if posterOffset > 10*time.Second {
+		posterOffset = 10 * time.Second
+	}
+
+	err = f.run(ctx,
+		"-ss", strconv.FormatFloat(posterOffset.Seconds(), 'f', 3, 64),
+		"-i", input,
+		"-frames:v", "1",
+		"-vf", "scale=1280:-2",
+		"-q:v", "3",
+		filepath.Join(outputDir, "poster.jpg"),
+	)
+	if err != nil {
+		return err
+	}
+
+	//2. Sprite sheet, every tile has the same size so cues can address it
+	interval := duration / maxThumbnails
+	if interval < minThumbnailInterval {
+		interval = minThumbnailInterval
+	}
+
+	count := int((duration + interval - 1) / interval)
+	rows := (count + thumbnailColumns - 1) / thumbnailColumns
+
+	err = f.run(ctx,
+		"-i", input,
+		"-vf", fmt.Sprintf("fps=1/%s,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,tile=%dx%d",
+			strconv.FormatFloat(interval.Seconds(), 'f', 3, 64),
+			thumbnailWidth, thumbnailHeight, thumbnailWidth, thumbnailHeight, thumbnailColumns, rows),
+		"-frames:v", "1",
+		"-q:v", "5",
+		filepath.Join(outputDir, "sprite.jpg"),
+	)
+	if err != nil {
+		return err
+	}
+
+	//3. WebVTT thumbnail track
+	return ioutil.WriteFile(filepath.Join(outputDir, "thumbnails.vtt"), []byte(thumbnailTrack("sprite.jpg", duration, interval, count)), 0644)
+}
+
+//Build WebVTT cues addressing sprite tiles with media fragments
+func thumbnailTrack(sprite string, duration time.Duration, interval time.Duration, count int) string {
+
+	var track strings.Builder
+	track.WriteString("WEBVTT\n")
+
+	for i := 0; i < count; i++ {
+
+		start := time.Duration(i) * interval
+		end := start + interval
+		if end > duration {
+			end = duration
+		}
+
+		x := (i % thumbnailColumns) * thumbnailWidth
+		y := (i / thumbnailColumns) * thumbnailHeight
+
+		fmt.Fprintf(&track, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), sprite, x, y, thumbnailWidth, thumbnailHeight)
+	}
+
+	return track.String()
+}
+
+func vttTimestamp(duration time.Duration) string {
+
+	milliseconds := duration.Milliseconds()
+
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, milliseconds%1000)
+}
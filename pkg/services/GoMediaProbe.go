@@ -0,0 +1,114 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/mediaprobe"
+	"acourse-course-service/pkg/models"
+	"io"
+	"log"
+	"mime/multipart"
+)
+
+//GoMediaProbeService reads MP4/MOV & Matroska/WebM headers natively, no libmediainfo needed
+type GoMediaProbeService struct {
+}
+
+//Parse bytes in a goroutine while they are written, nothing is spooled to disk
+type goMediaProbe struct {
+	writer *io.PipeWriter
+	done   chan struct{}
+	info   mediaprobe.Info
+	err    error
+}
+
+func (m GoMediaProbeService) NewProbe() (contracts.MediaProbe, error) {
+
+	reader, writer := io.Pipe()
+
+	probe := &goMediaProbe{writer: writer, done: make(chan struct{})}
+
+	go func() {
+		defer close(probe.done)
+		probe.info, probe.err = mediaprobe.Probe(reader)
+		//Unblock writer if probing stopped early
+		_ = reader.CloseWithError(probe.err)
+	}()
+
+	return probe, nil
+}
+
+func (p *goMediaProbe) Write(b []byte) (int, error) {
+	return p.writer.Write(b)
+}
+
+func (p *goMediaProbe) Info() (models.MediaInfo, error) {
+
+	_ = p.writer.Close()
+	<-p.done
+
+	if p.err != nil {
+		return models.MediaInfo{}, p.err
+	}
+
+	return models.MediaInfo{
+		Container:  p.info.Container,
+		Duration:   p.info.Duration,
+		Width:      p.info.Width,
+		Height:     p.info.Height,
+		FrameRate:  p.info.FrameRate,
+		VideoCodec: p.info.VideoCodec,
+		AudioCodec: p.info.AudioCodec,
+		Bitrate:    p.info.Bitrate,
+		FileSize:   p.info.Size,
+	}, nil
+}
+
+func (p *goMediaProbe) Close() error {
+	_ = p.writer.Close()
+	<-p.done
+	return nil
+}
+
+func (m GoMediaProbeService) GetReaderInfo(reader io.Reader) (models.MediaInfo, error) {
+
+	probe, err := m.NewProbe()
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+
+	defer func(probe contracts.MediaProbe) {
+		err := probe.Close()
+		if err != nil {
+			log.Printf("Failed closing probe, %v", err.Error())
+		}
+	}(probe)
+
+	_, err = io.Copy(probe, reader)
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+
+	return probe.Info()
+}
+
+func (m GoMediaProbeService) GetVideoInfo(file *multipart.FileHeader) (models.MediaInfo, error) {
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+
+	//Close file reading
+	defer func(openedFile multipart.File) {
+		err := openedFile.Close()
+		if err != nil {
+			log.Printf("Failed closing file, %v", err.Error())
+		}
+	}(openedFile)
+
+	return m.GetReaderInfo(openedFile)
+}
+
+func ConstructGoMediaProbeService() contracts.MediaInfoService {
+	return &GoMediaProbeService{}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+//Unknown key ids refetch the key set at most once per interval, so forged key ids can't flood the JWKS url
+const jwksRefetchInterval = time.Minute
+
+//Verification key of a JSON Web Key Set, key is *rsa.PublicKey, *ecdsa.PublicKey or []byte for HMAC
+type jsonWebKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+//JwksKeySet holds keys of a JSON Web Key Set, read once from a local file or fetched from url & cached for ttl
+type JwksKeySet struct {
+	url       string
+	ttl       time.Duration
+	client    *http.Client
+	mutex     sync.Mutex
+	keys      []jsonWebKey
+	fetchedAt time.Time
+}
+
+func ConstructFileJwksKeySet(path string) (contracts.VerificationKeySet, error) {
+
+	document, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := parseJwks(document)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JwksKeySet{keys: keys, fetchedAt: time.Now()}, nil
+}
+
+//Keys are fetched on first use, the url must be reachable by then
+func ConstructUrlJwksKeySet(url string, ttl time.Duration) contracts.VerificationKeySet {
+	return &JwksKeySet{
+		url:    url,
+		ttl:    ttl,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *JwksKeySet) VerificationKey(kid string, alg string) (interface{}, error) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	//1. Cached keys expire after ttl, rotated keys are fetched as soon as a token refers to them
+	if s.url != "" {
+		expired := time.Since(s.fetchedAt) > s.ttl
+		unknown := findJsonWebKey(s.keys, kid, alg) == nil && time.Since(s.fetchedAt) > jwksRefetchInterval
+
+		if expired || unknown {
+			err := s.fetch()
+			if err != nil {
+				//Stale keys keep verifying while the url is unreachable
+				log.Printf("Failed fetching JWKS %v, %v", s.url, err.Error())
+			}
+		}
+	}
+
+	//2. Key Of The Token
+	key := findJsonWebKey(s.keys, kid, alg)
+	if key == nil {
+		return nil, errors.New(fmt.Sprintf("No %v key %v in JWKS", alg, kid))
+	}
+
+	return key.key, nil
+}
+
+func (s *JwksKeySet) fetch() error {
+
+	s.fetchedAt = time.Now()
+
+	response, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("JWKS responded with status %d", response.StatusCode))
+	}
+
+	document, err := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+
+	keys, err := parseJwks(document)
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	return nil
+}
+
+//Key with the id which can verify the algorithm, tokens without key id match the only key for their algorithm
+func findJsonWebKey(keys []jsonWebKey, kid string, alg string) *jsonWebKey {
+
+	var found *jsonWebKey
+
+	for i := range keys {
+		if keys[i].alg != alg {
+			continue
+		}
+		if kid != "" && keys[i].kid == kid {
+			return &keys[i]
+		}
+		if kid == "" {
+			if found != nil {
+				return nil
+			}
+			found = &keys[i]
+		}
+	}
+
+	return found
+}
+
+//Signing keys of the set, key types this service can't verify with are skipped
+func parseJwks(document []byte) ([]jsonWebKey, error) {
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Alg string `json:"alg"`
+			Use string `json:"use"`
+			Crv string `json:"crv"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+			K   string `json:"k"`
+		} `json:"keys"`
+	}
+
+	err := json.Unmarshal(document, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]jsonWebKey, 0, len(set.Keys))
+
+	for _, entry := range set.Keys {
+
+		if entry.Use != "" && entry.Use != "sig" {
+			continue
+		}
+
+		//Algorithm follows the key type, so a key can't verify tokens of another algorithm
+		key := jsonWebKey{kid: entry.Kid}
+
+		switch entry.Kty {
+		case "RSA":
+			n, nErr := base64.RawURLEncoding.DecodeString(entry.N)
+			e, eErr := base64.RawURLEncoding.DecodeString(entry.E)
+			if nErr != nil || eErr != nil || len(n) == 0 || len(e) == 0 {
+				return nil, errors.New(fmt.Sprintf("JWKS key %v has invalid RSA parameters", entry.Kid))
+			}
+			key.alg = "RS256"
+			key.key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			if entry.Crv != "P-256" {
+				continue
+			}
+			x, xErr := base64.RawURLEncoding.DecodeString(entry.X)
+			y, yErr := base64.RawURLEncoding.DecodeString(entry.Y)
+			if xErr != nil || yErr != nil {
+				return nil, errors.New(fmt.Sprintf("JWKS key %v has invalid EC parameters", entry.Kid))
+			}
+			publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+				return nil, errors.New(fmt.Sprintf("JWKS key %v is not on curve P-256", entry.Kid))
+			}
+			key.alg = "ES256"
+			key.key = publicKey
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(entry.K)
+			if err != nil || len(secret) == 0 {
+				return nil, errors.New(fmt.Sprintf("JWKS key %v has invalid secret", entry.Kid))
+			}
+			key.alg = "HS256"
+			key.key = secret
+		default:
+			continue
+		}
+
+		if entry.Alg != "" && entry.Alg != key.alg {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
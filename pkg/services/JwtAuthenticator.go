@@ -0,0 +1,106 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Algorithms accepted for bearer tokens, every key of the set verifies exactly one of them
+var jwtAlgorithms = []string{"RS256", "ES256", "HS256"}
+
+//JwtAuthenticator verifies bearer tokens with keys of a JSON Web Key Set.
+//The user is the "sub" claim, "role" & "permission" claims map to the authorization as the gateway headers did
+type JwtAuthenticator struct {
+	keySet   contracts.VerificationKeySet
+	issuer   string
+	audience string
+	parser   *jwt.Parser
+}
+
+func ConstructJwtAuthenticator(keySet *contracts.VerificationKeySet, issuer string, audience string) (middleware.Authenticator, error) {
+
+	if issuer == "" || audience == "" {
+		return nil, errors.New("JWT issuer & audience have to be configured")
+	}
+
+	return &JwtAuthenticator{
+		keySet:   *keySet,
+		issuer:   issuer,
+		audience: audience,
+		//Time based claims are checked below, so exp is required & not skipped when it's missing
+		parser: jwt.NewParser(jwt.WithValidMethods(jwtAlgorithms), jwt.WithoutClaimsValidation()),
+	}, nil
+}
+
+func (a JwtAuthenticator) Authenticate(request *http.Request) (*middleware.Authorization, error) {
+
+	header := request.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, nil
+	}
+
+	//1. Verify Signature, the key has to belong to the algorithm of the token
+	claims := jwt.MapClaims{}
+	_, err := a.parser.ParseWithClaims(strings.TrimPrefix(header, "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.keySet.VerificationKey(kid, token.Method.Alg())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//2. Verify Claims
+	now := time.Now().Unix()
+
+	if !claims.VerifyExpiresAt(now, true) {
+		return nil, errors.New("Token is expired or has no expiration")
+	}
+	if !claims.VerifyNotBefore(now, false) {
+		return nil, errors.New("Token is not valid yet")
+	}
+	if !claims.VerifyIssuer(a.issuer, true) {
+		return nil, errors.New(fmt.Sprintf("Token is not issued by %v", a.issuer))
+	}
+	if !claims.VerifyAudience(a.audience, true) {
+		return nil, errors.New(fmt.Sprintf("Token is not meant for %v", a.audience))
+	}
+
+	//3. Map Claims
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("Token has no subject")
+	}
+
+	role, _ := claims["role"].(string)
+
+	return &middleware.Authorization{
+		UserID:     subject,
+		Role:       role,
+		Permission: jwtPermission(claims["permission"]),
+	}, nil
+}
+
+//Permission claim is either a string such as "cru" or a list such as ["create", "read", "update"], named after their first letter
+func jwtPermission(claim interface{}) string {
+
+	switch permission := claim.(type) {
+	case string:
+		return permission
+	case []interface{}:
+		var permissions []string
+		for _, value := range permission {
+			if value, ok := value.(string); ok && value != "" {
+				permissions = append(permissions, strings.ToLower(value[:1]))
+			}
+		}
+		return strings.Join(permissions, "")
+	}
+
+	return ""
+}
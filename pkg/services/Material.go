@@ -0,0 +1,406 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+func (c CourseService) FetchMaterial(ctx context.Context, courseId string, materialId string) (*response.HttpResponse, error) {
+
+	//Material visibility & urls follow the course response
+	course, err := c.FetchById(ctx, courseId, []string{})
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not found", courseId),
+		}, nil
+	}
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	material := c.findMaterial(course, materialId)
+	if material == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Material fetched successfully",
+		Data:       material,
+	}, nil
+}
+
+func (c CourseService) CreateMaterial(ctx context.Context, courseId string, request requests.CreateCourseMaterialRequest) (*response.HttpResponse, error) {
+
+	err := request.Validate()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	data := request.Material()
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialCreate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	section := course.FindSection(request.SectionID)
+	if request.SectionID != "" && section == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", request.SectionID),
+		}, nil
+	}
+
+	//2. Materials without order or ordered past the end are appended to their section,
+	//the others are inserted at their order & the following materials moved behind them
+	siblings := course.SectionMaterials(request.SectionID)
+	position := len(siblings)
+	if request.Order != nil && *request.Order < position {
+		position = *request.Order
+		if position < 0 {
+			position = 0
+		}
+	}
+
+	order := 0
+	if len(siblings) > 0 {
+		order = siblings[len(siblings)-1].Order + 1
+	}
+	if position < len(siblings) {
+		order = position
+	}
+
+	timeNow := time.Now()
+	material := models.Material{
+		MaterialID:  c.DBRepository.GenerateModelID(),
+		Name:        data.Name,
+		Kind:        data.MaterialKind(),
+		Description: data.Description,
+		Order:       order,
+		UpdatedAt:   &timeNow,
+		CreatedAt:   &timeNow,
+	}
+	if section != nil {
+		sectionObjectID := section.SectionID
+		material.SectionID = &sectionObjectID
+	}
+	c.applyMaterialContent(&material, data)
+
+	var placements []models.MaterialPlacement
+	if position < len(siblings) {
+		inserted := make([]models.Material, 0, len(siblings)+1)
+		inserted = append(inserted, siblings[:position]...)
+		inserted = append(inserted, material)
+		inserted = append(inserted, siblings[position:]...)
+		placements = c.materialPlacements(course, map[string][]models.Material{request.SectionID: inserted})
+	}
+
+	//3. Store Material File
+	rollback := &Saga{}
+
+	var upload *models.Upload
+	if models.MaterialKindHasFile(material.MaterialKind()) {
+		var failure *response.HttpResponse
+		upload, failure = c.storeMaterialFile(ctx, &material, course, request.File, request.UploadID, rollback)
+		if failure != nil {
+			return failure, nil
+		}
+	}
+
+	//4. Make Room, then Append Material while nothing else changed the course
+	var saved bool
+	if len(placements) > 0 {
+		saved, err = c.makeRoomForMaterial(ctx, course, siblings, placements, rollback)
+		if err == nil && saved {
+			saved, err = c.DBRepository.PushMaterialAt(ctx, courseId, course.Version+1, material)
+		}
+	} else {
+		saved, err = c.DBRepository.PushMaterial(ctx, courseId, material)
+	}
+	if err != nil || !saved {
+		operationError := &models.OperationError{
+			StatusCode: http.StatusConflict,
+			Message:    fmt.Sprintf("Course %v or its section was changed meanwhile, please retry", courseId),
+		}
+		if err != nil {
+			operationError.StatusCode, operationError.Message = http.StatusInternalServerError, err.Error()
+		}
+		operationError = c.compensate(rollback, operationError)
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionMaterialCreate, course.ID, &material.MaterialID, models.DiffFields(nil, &material, "material_id"))
+
+	c.releaseUploads([]*models.Upload{upload})
+	c.enqueueTranscoding(&models.Course{ID: course.ID, CourseID: course.CourseID, Materials: []models.Material{material}})
+
+	c.signMaterialUrls(&material)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusCreated,
+		Message:    "Material created successfully",
+		Data:       material,
+	}, nil
+}
+
+func (c CourseService) UpdateMaterial(ctx context.Context, courseId string, materialId string, request requests.UpdateMaterialRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	existingMaterial := c.findMaterial(course, materialId)
+	if existingMaterial == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	data := request.Material(*existingMaterial)
+	err := data.Validate()
+	if err == nil && (data.Name == "" || data.Description == "") {
+		err = errors.New(fmt.Sprintf("Material %v requires name & description", materialId))
+	}
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//2. Apply Changes
+	timeNow := time.Now()
+	material := *existingMaterial
+	material.Name = data.Name
+	material.Description = data.Description
+	material.UpdatedAt = &timeNow
+	c.applyMaterialContent(&material, data)
+
+	//3. Save Changed Fields Only, so concurrent changes of other materials are kept
+	saved, err := c.DBRepository.UpdateMaterial(ctx, courseId, materialId, nil, map[string]interface{}{
+		"name":        material.Name,
+		"description": material.Description,
+		"link_url":    material.LinkUrl,
+		"content":     material.Content,
+		"quiz":        material.Quiz,
+		"duration":    material.Duration,
+		"updated_at":  timeNow,
+	}, material.Duration-existingMaterial.Duration)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionMaterialUpdate, course.ID, &material.MaterialID, models.DiffFields(existingMaterial, &material))
+
+	c.signMaterialUrls(&material)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Material updated successfully",
+		Data:       material,
+	}, nil
+}
+
+func (c CourseService) ReplaceMaterialFile(ctx context.Context, courseId string, materialId string, request requests.ReplaceMaterialFileRequest) (*response.HttpResponse, error) {
+
+	err := request.Validate()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	existingMaterial := c.findMaterial(course, materialId)
+	if existingMaterial == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not found", materialId),
+		}, nil
+	}
+
+	if !models.MaterialKindHasFile(existingMaterial.MaterialKind()) {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Material %v is %v, it has no file", materialId, existingMaterial.MaterialKind()),
+		}, nil
+	}
+
+	//2. Store New File
+	rollback := &Saga{}
+	timeNow := time.Now()
+	material := *existingMaterial
+	material.UpdatedAt = &timeNow
+
+	upload, failure := c.storeMaterialFile(ctx, &material, course, request.File, request.UploadID, rollback)
+	if failure != nil {
+		return failure, nil
+	}
+
+	//3. Swap File, only when no other replacement won meanwhile
+	saved, err := c.DBRepository.UpdateMaterial(ctx, courseId, materialId, map[string]interface{}{"key": existingMaterial.Key}, map[string]interface{}{
+		"key":            material.Key,
+		"media_info":     material.MediaInfo,
+		"duration":       material.Duration,
+		"status":         material.Status,
+		"status_error":   material.StatusError,
+		"playlist_key":   material.PlaylistKey,
+		"poster_key":     material.PosterKey,
+		"thumbnails_key": material.ThumbnailsKey,
+		"updated_at":     timeNow,
+	}, material.Duration-existingMaterial.Duration)
+	if err == nil && !saved {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusConflict,
+			Message:    fmt.Sprintf("Material %v was changed meanwhile, please retry", materialId),
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, nil
+	}
+	if err != nil {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionMaterialUpdate, course.ID, &material.MaterialID, models.DiffFields(existingMaterial, &material))
+
+	//4. Remove Replaced File, captions are kept
+	c.deleteMaterialVideoObjects(ctx, courseId, *existingMaterial)
+
+	c.releaseUploads([]*models.Upload{upload})
+	c.enqueueTranscoding(&models.Course{ID: course.ID, CourseID: course.CourseID, Materials: []models.Material{material}})
+
+	c.signMaterialUrls(&material)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Material file replaced successfully",
+		Data:       material,
+	}, nil
+}
+
+//Move materials behind the inserted one, recorded on rollback by putting them back while the course wasn't changed further
+func (c CourseService) makeRoomForMaterial(ctx context.Context, course *models.Course, siblings []models.Material, placements []models.MaterialPlacement, rollback *Saga) (bool, error) {
+
+	courseId := course.ID.Hex()
+
+	saved, err := c.DBRepository.PlaceMaterials(ctx, courseId, course.Version, placements)
+	if err != nil || !saved {
+		return saved, err
+	}
+
+	previous := make([]models.MaterialPlacement, 0, len(placements))
+	for _, placement := range placements {
+		for _, sibling := range siblings {
+			if sibling.MaterialID == placement.MaterialID {
+				previous = append(previous, models.MaterialPlacement{MaterialID: sibling.MaterialID, SectionID: sibling.SectionID, Order: sibling.Order})
+			}
+		}
+	}
+
+	rollback.Record("placement of "+courseId, func() error {
+		restored, err := c.DBRepository.PlaceMaterials(ctx, courseId, course.Version+1, previous)
+		if err == nil && !restored {
+			err = errors.New(fmt.Sprintf("Course %v was changed meanwhile", courseId))
+		}
+		return err
+	})
+
+	return true, nil
+}
+
+//Attach file of a single material from request or finished resumable upload, direct uploads are recorded on rollback
+func (c CourseService) storeMaterialFile(ctx context.Context, material *models.Material, course *models.Course, file *multipart.FileHeader, uploadId string, rollback *Saga) (*models.Upload, *response.HttpResponse) {
+
+	kind := material.MaterialKind()
+
+	uploads, failure := c.resolveUploads(ctx, []requests.CreateMaterialRequest{{Kind: kind, UploadID: uploadId}}, rollback)
+	if failure != nil {
+		rollback.Compensate()
+		return nil, failure
+	}
+
+	var files []*multipart.FileHeader
+	if file != nil {
+		files = append(files, file)
+	}
+
+	uploaded, mediaInfo, err := c.materialVideo(files, 0, kind, uploads[0], course.CourseID+"/")
+	if err == nil && uploaded != nil && !uploaded.Success {
+		err = errors.New(uploaded.Message)
+	}
+	if err == nil && uploaded == nil {
+		err = errors.New(fmt.Sprintf("Material %v requires file or upload_id", material.Name))
+	}
+	if err != nil {
+		failedFile := models.FailedFile{Order: material.Order, Message: err.Error()}
+		if file != nil {
+			failedFile.Filename = file.Filename
+		}
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    "Failed uploading material file",
+			Data:       c.compensate(rollback, &models.OperationError{Message: err.Error(), FailedFiles: []models.FailedFile{failedFile}}),
+		}
+	}
+
+	if uploads[0] == nil {
+		rollback.RecordUpload(c.StorageService, uploaded.Key)
+	}
+
+	c.attachMaterialFile(material, uploaded.Key, mediaInfo)
+
+	return uploads[0], nil
+}
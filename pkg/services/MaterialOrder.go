@@ -0,0 +1,197 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+//Apply a new material order in one write, touched sections are renumbered densely from 0
+func (c CourseService) ReorderMaterials(ctx context.Context, courseId string, request requests.ReorderMaterialsRequest) (*response.HttpResponse, error) {
+
+	err := request.Validate()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	if request.SectionID != "" && course.FindSection(request.SectionID) == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", request.SectionID),
+		}, nil
+	}
+
+	//2. Build New Order
+	var groups map[string][]models.Material
+	if len(request.MaterialIDs) > 0 {
+		groups, failure = c.permutedMaterials(course, request.SectionID, request.MaterialIDs)
+	} else {
+		groups, failure = c.movedMaterials(course, request.Moves)
+	}
+	if failure != nil {
+		return failure, nil
+	}
+
+	//3. Save Every Changed Position At Once
+	placements := c.materialPlacements(course, groups)
+	if len(placements) > 0 {
+		saved, err := c.DBRepository.PlaceMaterials(ctx, courseId, course.Version, placements)
+		if err != nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Message:    err.Error(),
+			}, err
+		}
+		if !saved {
+			return &response.HttpResponse{
+				StatusCode: http.StatusConflict,
+				Message:    "Course materials were changed meanwhile, please retry",
+			}, nil
+		}
+
+		c.audit(ctx, models.AuditActionReorderMaterials, course.ID, nil, placementChanges(course, placements))
+	}
+
+	//4. Respond With Resulting Order
+	order := make(map[string][]string)
+	for sectionId, materials := range groups {
+		order[sectionId] = make([]string, 0, len(materials))
+		for _, material := range materials {
+			order[sectionId] = append(order[sectionId], material.MaterialID.Hex())
+		}
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Materials reordered successfully",
+		Data:       order,
+	}, nil
+}
+
+//Given ids have to be exactly the materials of the section, each of them once
+func (c CourseService) permutedMaterials(course *models.Course, sectionId string, materialIds []string) (map[string][]models.Material, *response.HttpResponse) {
+
+	current := course.SectionMaterials(sectionId)
+	byId := make(map[string]models.Material, len(current))
+	for _, material := range current {
+		byId[material.MaterialID.Hex()] = material
+	}
+
+	ordered := make([]models.Material, 0, len(materialIds))
+	seen := make(map[string]bool, len(materialIds))
+
+	for _, materialId := range materialIds {
+		if seen[materialId] {
+			return nil, &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is given more than once", materialId),
+			}
+		}
+
+		material, ok := byId[materialId]
+		if !ok {
+			return nil, &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is not part of the reordered section", materialId),
+			}
+		}
+
+		seen[materialId] = true
+		ordered = append(ordered, material)
+	}
+
+	if len(ordered) != len(current) {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Order is incomplete, %v of %v materials are given", len(ordered), len(current)),
+		}
+	}
+
+	return map[string][]models.Material{sectionId: ordered}, nil
+}
+
+//Apply moves one after another, a material moved next to an anchor joins the section of the anchor
+func (c CourseService) movedMaterials(course *models.Course, moves []requests.MaterialMoveRequest) (map[string][]models.Material, *response.HttpResponse) {
+
+	groups := make(map[string][]models.Material)
+	sectionOf := make(map[string]string)
+	touched := make(map[string]bool)
+
+	groupIds := []string{""}
+	for _, section := range course.Sections {
+		groupIds = append(groupIds, section.SectionID.Hex())
+	}
+	for _, sectionId := range groupIds {
+		groups[sectionId] = course.SectionMaterials(sectionId)
+		for _, material := range groups[sectionId] {
+			sectionOf[material.MaterialID.Hex()] = sectionId
+		}
+	}
+
+	for _, move := range moves {
+
+		anchorId := move.Before
+		if anchorId == "" {
+			anchorId = move.After
+		}
+
+		for _, materialId := range []string{move.MaterialID, anchorId} {
+			if _, ok := sectionOf[materialId]; !ok {
+				return nil, &response.HttpResponse{
+					StatusCode: http.StatusNotFound,
+					Message:    fmt.Sprintf("Material %v is not found", materialId),
+				}
+			}
+		}
+
+		//Take material out of its section
+		source := sectionOf[move.MaterialID]
+		var material models.Material
+		remaining := make([]models.Material, 0, len(groups[source]))
+		for _, existing := range groups[source] {
+			if existing.MaterialID.Hex() == move.MaterialID {
+				material = existing
+				continue
+			}
+			remaining = append(remaining, existing)
+		}
+		groups[source] = remaining
+
+		//Insert it next to anchor
+		target := sectionOf[anchorId]
+		placed := make([]models.Material, 0, len(groups[target])+1)
+		for _, existing := range groups[target] {
+			if existing.MaterialID.Hex() == anchorId && move.Before != "" {
+				placed = append(placed, material)
+			}
+			placed = append(placed, existing)
+			if existing.MaterialID.Hex() == anchorId && move.After != "" {
+				placed = append(placed, material)
+			}
+		}
+		groups[target] = placed
+
+		sectionOf[move.MaterialID] = target
+		touched[source] = true
+		touched[target] = true
+	}
+
+	result := make(map[string][]models.Material)
+	for sectionId := range touched {
+		result[sectionId] = groups[sectionId]
+	}
+
+	return result, nil
+}
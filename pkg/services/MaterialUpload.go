@@ -0,0 +1,269 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//Fetch course and make sure the access policy allows the requester the action on it
+func (c CourseService) fetchAuthorizedCourse(ctx context.Context, courseId string, action string, forbiddenMessage string) (*models.Course, *response.HttpResponse) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	course, err := c.DBRepository.FetchById(ctx, courseId, []string{})
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
+	}
+
+	validated, err := c.AuthorizeResourceOwner(&course, *authorization, action)
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
+	}
+
+	if !validated {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusUnauthorized,
+			Message:    forbiddenMessage,
+		}
+	}
+
+	return &course, nil
+}
+
+func (c CourseService) CreateMaterialUploads(ctx context.Context, courseId string, request requests.CreateMaterialUploadsRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialCreate, "You don't have any permission to upload to this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Presign Every File Under Course Prefix
+	var uploads []response.PresignedUpload
+
+	for _, file := range request.Files {
+		upload, err := c.StorageService.CreatePresignedUpload(file.Filename, course.CourseID+"/", file.ContentType, file.Size)
+		if err != nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    err.Error(),
+			}, nil
+		}
+		uploads = append(uploads, upload)
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Upload urls created successfully",
+		Data:       uploads,
+	}, nil
+}
+
+func (c CourseService) FinalizeMaterialUploads(ctx context.Context, courseId string, request requests.FinalizeMaterialUploadsRequest) (*response.HttpResponse, error) {
+
+	err := request.ValidateMaterials()
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionMaterialCreate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Validate Uploads Before Completing Any Of Them, new materials are videos unless kind is given
+	kinds := make([]string, len(request.Uploads))
+
+	for i, upload := range request.Uploads {
+
+		kinds[i] = upload.Kind
+		if kinds[i] == "" {
+			kinds[i] = models.MaterialKindVideo
+		}
+
+		if !strings.HasPrefix(upload.Key, course.CourseID+"/") {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Object %v does not belong to this course", upload.Key),
+			}, nil
+		}
+
+		if upload.MaterialID == "" {
+			continue
+		}
+
+		material := c.findMaterial(course, upload.MaterialID)
+		if material == nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Message:    fmt.Sprintf("Material %v is not found", upload.MaterialID),
+			}, nil
+		}
+
+		kinds[i] = material.MaterialKind()
+		if !models.MaterialKindHasFile(kinds[i]) || (upload.Kind != "" && upload.Kind != kinds[i]) {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is %v, upload can't be attached to it", upload.MaterialID, kinds[i]),
+			}, nil
+		}
+	}
+
+	//3. Complete Uploads & Attach Them To Materials, completed objects are removed if a later step fails
+	rollback := &Saga{}
+	timeNow := time.Now()
+	before := models.AuditFields(course)
+	var replacedMaterials []models.Material
+	var finalized []models.Material
+
+	for i, upload := range request.Uploads {
+
+		uploaded, err := c.StorageService.CompletePresignedUpload(upload.Key, upload.UploadID, upload.Parts)
+		if err != nil {
+			operationError := c.compensate(rollback, &models.OperationError{
+				StatusCode:  http.StatusBadRequest,
+				Message:     err.Error(),
+				FailedFiles: []models.FailedFile{{Filename: upload.Key, Message: err.Error()}},
+			})
+			return &response.HttpResponse{
+				StatusCode: operationError.StatusCode,
+				Message:    operationError.Message,
+				Data:       operationError,
+			}, nil
+		}
+		rollback.RecordUpload(c.StorageService, uploaded.Key)
+
+		//Videos & audio are probed, documents are checked by their signature
+		var mediaInfo *models.MediaInfo
+		var head []byte
+
+		if models.MaterialKindIsMedia(kinds[i]) {
+			mediaInfo, err = c.getObjectMediaInfo(uploaded.Key)
+		} else {
+			head, err = c.getObjectHead(uploaded.Key)
+		}
+		if err != nil {
+			log.Println(err.Error())
+		}
+
+		err = c.validateStoredFile(kinds[i], uploaded.Key, head, mediaInfo)
+		if err != nil {
+			operationError := c.compensate(rollback, &models.OperationError{
+				StatusCode:  http.StatusUnprocessableEntity,
+				Message:     err.Error(),
+				FailedFiles: []models.FailedFile{{Filename: upload.Key, Message: err.Error()}},
+			})
+			return &response.HttpResponse{
+				StatusCode: operationError.StatusCode,
+				Message:    operationError.Message,
+				Data:       operationError,
+			}, nil
+		}
+
+		if upload.MaterialID != "" {
+
+			material := c.findMaterial(course, upload.MaterialID)
+
+			replacedMaterials = append(replacedMaterials, *material)
+
+			course.SubTotalDuration(material.Duration)
+
+			c.attachMaterialFile(material, uploaded.Key, mediaInfo)
+			material.UpdatedAt = &timeNow
+
+			course.AddTotalDuration(material.Duration)
+
+			finalized = append(finalized, *material)
+		} else {
+
+			material := models.Material{
+				MaterialID:  c.DBRepository.GenerateModelID(),
+				Name:        upload.Name,
+				Kind:        kinds[i],
+				Description: upload.Description,
+				Order:       *upload.Order,
+				UpdatedAt:   &timeNow,
+				CreatedAt:   &timeNow,
+			}
+			c.attachMaterialFile(&material, uploaded.Key, mediaInfo)
+
+			course.AddTotalDuration(material.Duration)
+
+			course.Materials = append(course.Materials, material)
+			finalized = append(finalized, material)
+		}
+	}
+
+	course.UpdatedAt = &timeNow
+
+	//4. Save Course, only while it's still at the version it was read at
+	saved, err := c.DBRepository.Update(ctx, course, courseId)
+	if err == nil && !saved {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusConflict,
+			Message:    "Course was changed meanwhile, please retry",
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, nil
+	}
+	if err != nil {
+		operationError := c.compensate(rollback, &models.OperationError{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		})
+		return &response.HttpResponse{
+			StatusCode: operationError.StatusCode,
+			Message:    operationError.Message,
+			Data:       operationError,
+		}, err
+	}
+
+	c.audit(ctx, models.ActionMaterialCreate, course.ID, nil, models.DiffCourse(before, course))
+
+	//5. Remove Replaced Videos
+	for _, material := range replacedMaterials {
+		c.deleteMaterialVideoObjects(ctx, courseId, material)
+	}
+
+	c.enqueueTranscoding(course)
+
+	for i := range finalized {
+		c.signMaterialUrls(&finalized[i])
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Uploads finalized successfully",
+		Data:       finalized,
+	}, nil
+}
+
+func (c CourseService) findMaterial(course *models.Course, materialId string) *models.Material {
+	for i := 0; i < len(course.Materials); i++ {
+		if course.Materials[i].MaterialID.Hex() == materialId {
+			return &course.Materials[i]
+		}
+	}
+	return nil
+}
@@ -1,45 +1,183 @@
+//go:build !nomediainfo
+
 package services
 
 import (
 	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"errors"
+	"io"
 	"io/ioutil"
 	"log"
 	"mime/multipart"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 import "github.com/zhulik/go_mediainfo"
 
 type MediaInfoService struct {
 	mediainfo *mediainfo.MediaInfo
+	lock      *sync.Mutex
+}
+
+//Spool probed bytes into a temporary file so large videos are never kept in memory
+type mediaInfoProbe struct {
+	service MediaInfoService
+	file    *os.File
 }
 
-func (m MediaInfoService) GetVideoDuration(file *multipart.FileHeader) (string, error) {
+func (m MediaInfoService) NewProbe() (contracts.MediaProbe, error) {
 
-	//Get raw file bytes
-	openedFile, _ := file.Open()
-	filebytes, err := ioutil.ReadAll(openedFile)
+	tempFile, err := ioutil.TempFile("", "mediainfo-*")
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	return &mediaInfoProbe{service: m, file: tempFile}, nil
+}
+
+func (p *mediaInfoProbe) Write(b []byte) (int, error) {
+	return p.file.Write(b)
+}
+
+//Inform template printing one line per stream, fields are separated by "|"
+const mediaInfoTemplate = "General;G|%Format%|%Duration%|%FileSize%|%OverallBitRate%\\n\r\n" +
+	"Video;V|%Format%|%Width%|%Height%|%FrameRate%\\n\r\n" +
+	"Audio;A|%Format%\\n"
+
+//libmediainfo format names mapped to the short names used across the service
+var mediaInfoFormats = map[string]string{
+	"MPEG-4":     "mp4",
+	"QuickTime":  "mov",
+	"Matroska":   "matroska",
+	"WebM":       "webm",
+	"AVC":        "h264",
+	"HEVC":       "hevc",
+	"AV1":        "av1",
+	"VP8":        "vp8",
+	"VP9":        "vp9",
+	"AAC":        "aac",
+	"Opus":       "opus",
+	"Vorbis":     "vorbis",
+	"AC-3":       "ac3",
+	"E-AC-3":     "eac3",
+	"MPEG Audio": "mp3",
+	"FLAC":       "flac",
+}
+
+func (p *mediaInfoProbe) Info() (models.MediaInfo, error) {
+
+	//libmediainfo handle is not safe for concurrent use
+	p.service.lock.Lock()
+	defer p.service.lock.Unlock()
+
+	err := p.service.mediainfo.OpenFile(p.file.Name())
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+	defer p.service.mediainfo.Close()
+
+	p.service.mediainfo.Option("Inform", mediaInfoTemplate)
+
+	return parseMediaInfoReport(p.service.mediainfo.Inform())
+}
+
+func (p *mediaInfoProbe) Close() error {
+	_ = p.file.Close()
+	return os.Remove(p.file.Name())
+}
+
+func (m MediaInfoService) GetReaderInfo(reader io.Reader) (models.MediaInfo, error) {
+
+	probe, err := m.NewProbe()
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+
+	defer func(probe contracts.MediaProbe) {
+		err := probe.Close()
+		if err != nil {
+			log.Printf("Failed removing probe file, %v", err.Error())
+		}
+	}(probe)
+
+	_, err = io.Copy(probe, reader)
+	if err != nil {
+		return models.MediaInfo{}, err
+	}
+
+	return probe.Info()
+}
+
+func (m MediaInfoService) GetVideoInfo(file *multipart.FileHeader) (models.MediaInfo, error) {
+
+	openedFile, err := file.Open()
+	if err != nil {
+		return models.MediaInfo{}, err
 	}
 
 	//Close file reading
 	defer func(openedFile multipart.File) {
 		err := openedFile.Close()
 		if err != nil {
-			log.Fatalf("Failed closing file, %v", err.Error())
+			log.Printf("Failed closing file, %v", err.Error())
 		}
 	}(openedFile)
 
-	//Process Video
-	err = m.mediainfo.OpenMemory(filebytes)
-	if err != nil {
-		return "", err
+	return m.GetReaderInfo(openedFile)
+}
+
+func ConstructMediaInfoService(mediainfo *mediainfo.MediaInfo) contracts.MediaInfoService {
+	return &MediaInfoService{mediainfo: mediainfo, lock: &sync.Mutex{}}
+}
+
+//NewLibMediaInfoService opens a libmediainfo handle, builds tagged nomediainfo return an error instead
+func NewLibMediaInfoService() (contracts.MediaInfoService, error) {
+	return ConstructMediaInfoService(mediainfo.NewMediaInfo()), nil
+}
+
+//Parse report printed with mediaInfoTemplate, only the first video & audio streams are described
+func parseMediaInfoReport(report string) (models.MediaInfo, error) {
+
+	var info models.MediaInfo
+	var general bool
+
+	for _, line := range strings.Split(report, "\n") {
+
+		fields := strings.Split(strings.TrimSpace(line), "|")
+
+		switch {
+		case fields[0] == "G" && len(fields) == 5:
+			general = true
+			info.Container = mediaInfoFormat(fields[1])
+			milliseconds, _ := strconv.ParseFloat(fields[2], 64)
+			info.Duration = time.Duration(milliseconds * float64(time.Millisecond))
+			info.FileSize, _ = strconv.ParseInt(fields[3], 10, 64)
+			info.Bitrate, _ = strconv.ParseInt(fields[4], 10, 64)
+		case fields[0] == "V" && len(fields) == 5 && info.VideoCodec == "":
+			info.VideoCodec = mediaInfoFormat(fields[1])
+			info.Width, _ = strconv.Atoi(fields[2])
+			info.Height, _ = strconv.Atoi(fields[3])
+			info.FrameRate, _ = strconv.ParseFloat(fields[4], 64)
+		case fields[0] == "A" && len(fields) == 2 && info.AudioCodec == "":
+			info.AudioCodec = mediaInfoFormat(fields[1])
+		}
+	}
+
+	if !general || info.Container == "" {
+		return info, errors.New("mediainfo can't recognize the file format")
 	}
 
-	//panic("Duration >> " + m.mediainfo.Get("Duration"))
-	return m.mediainfo.Get("Duration"), nil
+	return info, nil
 }
 
-func ConstructMediaInfoService(mediainfo *mediainfo.MediaInfo) contracts.MediaInfoService {
-	return &MediaInfoService{mediainfo: mediainfo}
+func mediaInfoFormat(format string) string {
+	if name, ok := mediaInfoFormats[format]; ok {
+		return name
+	}
+	return strings.ToLower(format)
 }
@@ -0,0 +1,13 @@
+//go:build nomediainfo
+
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"errors"
+)
+
+//NewLibMediaInfoService is unavailable when built without cgo libmediainfo, use MEDIA_PROBE=native
+func NewLibMediaInfoService() (contracts.MediaInfoService, error) {
+	return nil, errors.New("built without libmediainfo support, set MEDIA_PROBE=native")
+}
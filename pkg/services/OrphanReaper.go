@@ -0,0 +1,145 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"context"
+	"log"
+	"strings"
+	"time"
+)
+
+//Find storage objects which are not referenced by any course & remove them
+type OrphanReaperService struct {
+	DBRepository     contracts.CourseDatabaseRepository
+	UploadRepository contracts.ResumableUploadRepository
+	StorageService   contracts.StorageService
+	gracePeriod      time.Duration
+}
+
+func ConstructOrphanReaperService(dbRepository *contracts.CourseDatabaseRepository, uploadRepository *contracts.ResumableUploadRepository, storageService *contracts.StorageService, gracePeriod time.Duration) contracts.OrphanReaperService {
+	return &OrphanReaperService{
+		DBRepository:     *dbRepository,
+		UploadRepository: *uploadRepository,
+		StorageService:   *storageService,
+		gracePeriod:      gracePeriod,
+	}
+}
+
+func (r OrphanReaperService) Reap(ctx context.Context, dryRun bool) (models.ReaperReport, error) {
+
+	report := models.ReaperReport{
+		DryRun:      dryRun,
+		GracePeriod: r.gracePeriod.String(),
+		Orphans:     make([]models.OrphanObject, 0),
+		StartedAt:   time.Now(),
+	}
+
+	//1. Collect referenced keys, soft deleted courses still own their objects.
+	//References are read before listing so objects attached in between are newer than the grace period.
+	//Completed resumable uploads own their object until a material takes it over, they're read before courses so an upload released in between is already attached.
+	uploads, err := r.UploadRepository.List()
+	if err != nil {
+		return report, err
+	}
+
+	courses, err := r.DBRepository.FetchAllWithDeleted(ctx, []string{})
+	if err != nil {
+		return report, err
+	}
+
+	//Published revisions & the ones which can be rolled back to keep their objects as well
+	revisions, err := r.DBRepository.FetchAllRevisions(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	references := models.NewStorageReferences()
+	for i := range courses {
+		references.Add(&courses[i])
+	}
+	for _, revision := range revisions {
+		if revision.Snapshot != nil {
+			references.Add(revision.Snapshot)
+		}
+	}
+	for _, upload := range uploads {
+		if upload.Key != "" {
+			references.AddKey(upload.Key)
+		}
+	}
+
+	//2. List every object, each first key segment is a course prefix (or resumable uploads)
+	objects, err := r.StorageService.ListObjects("")
+	if err != nil {
+		return report, err
+	}
+
+	//3. Objects without reference which are older than grace period are orphans
+	cutoff := report.StartedAt.Add(-r.gracePeriod)
+
+	for _, object := range objects {
+
+		report.Scanned++
+
+		if references.IsReferenced(object.Key) {
+			report.Referenced++
+			continue
+		}
+
+		if object.LastModified.After(cutoff) {
+			continue
+		}
+
+		orphan := models.OrphanObject{
+			Key:          object.Key,
+			Prefix:       strings.SplitN(object.Key, "/", 2)[0],
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		}
+
+		//4. Remove orphan unless it's a dry run
+		if !dryRun {
+			err := r.StorageService.Delete(object.Key)
+			if err != nil {
+				log.Printf("Failed deleting orphaned object %v, %v", object.Key, err.Error())
+				orphan.Error = err.Error()
+				report.Failed++
+			} else {
+				orphan.Deleted = true
+				report.Deleted++
+			}
+		}
+
+		report.OrphanSize += object.Size
+		report.Orphans = append(report.Orphans, orphan)
+	}
+
+	report.FinishedAt = time.Now()
+
+	return report, nil
+}
+
+//Run reaper periodically until context is cancelled
+func (r OrphanReaperService) Schedule(ctx context.Context, interval time.Duration, dryRun bool) {
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := r.Reap(ctx, dryRun)
+				if err != nil {
+					log.Printf("Orphan reaper failed, %v", err.Error())
+					continue
+				}
+				log.Printf("Orphan reaper scanned %d objects, found %d orphans (%d bytes), deleted %d, failed %d, dry run %v",
+					report.Scanned, len(report.Orphans), report.OrphanSize, report.Deleted, report.Failed, report.DryRun)
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func (c CourseService) ScheduleRelease(ctx context.Context, courseId string, request requests.ReleaseScheduleRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePublish, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	err := request.Validate(course.IsReleased, time.Now())
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	//2. Save Schedule, it replaces the previous one
+	return c.saveReleaseSchedule(ctx, course, models.AuditActionScheduleRelease, request.PublishAt, request.UnpublishAt, "Release scheduled successfully")
+}
+
+func (c CourseService) CancelReleaseSchedule(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePublish, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Clear Schedule
+	return c.saveReleaseSchedule(ctx, course, models.AuditActionCancelRelease, nil, nil, "Release schedule cancelled successfully")
+}
+
+//Schedule fields of a course, as they're audited
+type releaseSchedule struct {
+	PublishAt   *time.Time `json:"publish_at,omitempty"`
+	UnpublishAt *time.Time `json:"unpublish_at,omitempty"`
+}
+
+func (c CourseService) saveReleaseSchedule(ctx context.Context, course *models.Course, action string, publishAt *time.Time, unpublishAt *time.Time, message string) (*response.HttpResponse, error) {
+
+	saved, err := c.DBRepository.SetReleaseSchedule(ctx, course.ID.Hex(), publishAt, unpublishAt)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not found", course.ID.Hex()),
+		}, nil
+	}
+
+	c.audit(ctx, action, course.ID, nil, models.DiffFields(releaseSchedule{course.PublishAt, course.UnpublishAt}, releaseSchedule{publishAt, unpublishAt}))
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    message,
+		Data: map[string]interface{}{
+			"is_released":  course.IsReleased,
+			"publish_at":   publishAt,
+			"unpublish_at": unpublishAt,
+		},
+	}, nil
+}
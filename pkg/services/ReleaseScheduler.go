@@ -0,0 +1,93 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"context"
+	"log"
+	"time"
+)
+
+//Publish & unpublish courses once their scheduled time has come
+type ReleaseSchedulerService struct {
+	DBRepository    contracts.CourseDatabaseRepository
+	AuditRepository contracts.AuditRepository
+}
+
+func ConstructReleaseSchedulerService(dbRepository *contracts.CourseDatabaseRepository, auditRepository *contracts.AuditRepository) contracts.ReleaseSchedulerService {
+	return &ReleaseSchedulerService{
+		DBRepository:    *dbRepository,
+		AuditRepository: *auditRepository,
+	}
+}
+
+//Apply every due release change, returns how many changes were applied
+func (r ReleaseSchedulerService) ReleaseDue(ctx context.Context) (int, error) {
+
+	now := time.Now()
+
+	courses, err := r.DBRepository.FetchDueReleases(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+
+	for i := range courses {
+
+		isReleased := courses[i].IsReleased
+
+		//Publish & unpublish which are both due are applied in their scheduled order
+		for _, event := range courses[i].DueReleases(now) {
+
+			//Schedule changed by the owner meanwhile is left for the next run
+			released, err := r.DBRepository.ApplyScheduledRelease(ctx, courses[i].ID.Hex(), event)
+			if err != nil {
+				log.Printf("Failed applying %v of course %v, %v", event.Action, courses[i].ID.Hex(), err.Error())
+				break
+			}
+			if released {
+				applied++
+
+				changes := []models.FieldChange{{Field: "is_released", From: isReleased, To: event.Action == models.ReleaseActionPublish}}
+				action := models.AuditActionScheduledUnpublish
+				if event.Action == models.ReleaseActionPublish {
+					action = models.AuditActionScheduledPublish
+					changes = append(changes, models.FieldChange{Field: "publish_at", From: *event.ScheduledAt})
+				} else {
+					changes = append(changes, models.FieldChange{Field: "unpublish_at", From: *event.ScheduledAt})
+				}
+				isReleased = event.Action == models.ReleaseActionPublish
+
+				auditSystemMutation(ctx, r.AuditRepository, action, courses[i].ID, nil, changes)
+			}
+		}
+	}
+
+	return applied, nil
+}
+
+//Run scheduler periodically until context is cancelled
+func (r ReleaseSchedulerService) Schedule(ctx context.Context, interval time.Duration) {
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				applied, err := r.ReleaseDue(ctx)
+				if err != nil {
+					log.Printf("Release scheduler failed, %v", err.Error())
+					continue
+				}
+				if applied > 0 {
+					log.Printf("Release scheduler applied %d release changes", applied)
+				}
+			}
+		}
+	}()
+}
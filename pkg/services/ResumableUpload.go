@@ -0,0 +1,327 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"time"
+)
+
+type ResumableUploadService struct {
+	UploadRepository contracts.ResumableUploadRepository
+	StorageService   contracts.StorageService
+	MediaInfoService contracts.MediaInfoService
+	MediaPolicy      models.MediaPolicy
+	maxSize          int64
+}
+
+func ConstructResumableUploadService(uploadRepository *contracts.ResumableUploadRepository, storageService *contracts.StorageService, mediaInfoService *contracts.MediaInfoService, mediaPolicy models.MediaPolicy, maxSize int64) contracts.ResumableUploadService {
+	return &ResumableUploadService{
+		UploadRepository: *uploadRepository,
+		StorageService:   *storageService,
+		MediaInfoService: *mediaInfoService,
+		MediaPolicy:      mediaPolicy,
+		maxSize:          maxSize,
+	}
+}
+
+func (r ResumableUploadService) MaxSize() int64 {
+	return r.maxSize
+}
+
+//Only the user who created the upload can touch it
+func (r ResumableUploadService) authorize(ctx context.Context, upload models.Upload) error {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	if upload.UserID != authorization.UserID {
+		return models.ErrUploadForbidden
+	}
+	return nil
+}
+
+func (r ResumableUploadService) Create(ctx context.Context, length int64, metadata map[string]string) (models.Upload, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	if length < 0 {
+		return models.Upload{}, errors.New("Upload length must not be negative")
+	}
+
+	if length > r.maxSize {
+		return models.Upload{}, models.ErrUploadTooLarge
+	}
+
+	//1. Generate upload id
+	randomBytes := make([]byte, 16)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return models.Upload{}, err
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+
+	timeNow := time.Now()
+
+	upload := models.Upload{
+		ID:        hex.EncodeToString(randomBytes),
+		UserID:    authorization.UserID,
+		Length:    length,
+		Offset:    0,
+		Metadata:  metadata,
+		Filename:  filename,
+		UpdatedAt: &timeNow,
+		CreatedAt: &timeNow,
+	}
+
+	//2. Save upload state
+	err = r.UploadRepository.Create(upload)
+	if err != nil {
+		return models.Upload{}, err
+	}
+
+	//3. Empty upload is already complete
+	if length == 0 {
+		return r.complete(upload)
+	}
+
+	return upload, nil
+}
+
+func (r ResumableUploadService) Find(ctx context.Context, uploadId string) (models.Upload, error) {
+
+	upload, err := r.UploadRepository.Find(uploadId)
+	if err != nil {
+		return upload, err
+	}
+
+	err = r.authorize(ctx, upload)
+	if err != nil {
+		return models.Upload{}, err
+	}
+
+	return upload, nil
+}
+
+func (r ResumableUploadService) WriteChunk(ctx context.Context, uploadId string, offset int64, reader io.Reader) (models.Upload, error) {
+
+	unlock, err := r.UploadRepository.Lock(uploadId)
+	if err != nil {
+		return models.Upload{}, err
+	}
+	defer unlock()
+
+	upload, err := r.Find(ctx, uploadId)
+	if err != nil {
+		return upload, err
+	}
+
+	if upload.Offset != offset {
+		return upload, models.ErrUploadOffsetMismatch
+	}
+
+	//1. Append chunk, progress is kept even if the connection drops midway
+	if !upload.Completed && upload.Offset < upload.Length {
+
+		written, writeErr := r.UploadRepository.WriteChunk(uploadId, io.LimitReader(reader, upload.Length-upload.Offset))
+
+		timeNow := time.Now()
+		upload.Offset += written
+		upload.UpdatedAt = &timeNow
+
+		err = r.UploadRepository.Save(upload)
+		if err != nil {
+			return upload, err
+		}
+
+		if writeErr != nil {
+			return upload, writeErr
+		}
+	}
+
+	//2. Hand the finished file to storage
+	if upload.Offset == upload.Length && !upload.Completed {
+		return r.complete(upload)
+	}
+
+	return upload, nil
+}
+
+func (r ResumableUploadService) complete(upload models.Upload) (models.Upload, error) {
+
+	data, err := r.UploadRepository.Open(upload.ID)
+	if err != nil {
+		return upload, err
+	}
+	defer data.Close()
+
+	probe, err := r.MediaInfoService.NewProbe()
+	if err != nil {
+		return upload, err
+	}
+
+	defer func(probe contracts.MediaProbe) {
+		err := probe.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}(probe)
+
+	uploaded, err := r.StorageService.UploadReader(io.TeeReader(data, probe), upload.Filename, "uploads/"+upload.ID+"/")
+	if err != nil {
+		return upload, err
+	}
+
+	if !uploaded.Success {
+		return upload, errors.New(uploaded.Message)
+	}
+
+	//Non video uploads have no media info
+	mediaInfo, err := probe.Info()
+	if err == nil {
+		upload.MediaInfo = &mediaInfo
+	}
+
+	//Rejected files are dropped entirely, retrying the same upload can't succeed. Kind is given in upload metadata, videos by default
+	kind := upload.Metadata["kind"]
+	if kind == "" {
+		kind = models.MaterialKindVideo
+	}
+
+	err = r.MediaPolicy.ValidateKind(kind, upload.MediaInfo)
+	if err != nil {
+		r.reject(upload, uploaded.Key)
+		return upload, err
+	}
+
+	timeNow := time.Now()
+	upload.Completed = true
+	upload.Key = uploaded.Key
+	upload.Filepath = uploaded.Filepath
+	upload.UpdatedAt = &timeNow
+
+	err = r.UploadRepository.Save(upload)
+	if err != nil {
+		return upload, err
+	}
+
+	err = r.UploadRepository.RemoveData(upload.ID)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	return upload, nil
+}
+
+func (r ResumableUploadService) reject(upload models.Upload, key string) {
+
+	err := r.StorageService.Delete(key)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	err = r.UploadRepository.Delete(upload.ID)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (r ResumableUploadService) Terminate(ctx context.Context, uploadId string) error {
+
+	unlock, err := r.UploadRepository.Lock(uploadId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	upload, err := r.Find(ctx, uploadId)
+	if err != nil {
+		return err
+	}
+
+	//Object is about to be owned by a material
+	if upload.Claimed {
+		return models.ErrUploadClaimed
+	}
+
+	if upload.Completed && upload.Key != "" {
+		err = r.StorageService.Delete(upload.Key)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return r.UploadRepository.Delete(uploadId)
+}
+
+//Claim completed upload for a material, a claimed upload can't be resolved again until it's unclaimed or released
+func (r ResumableUploadService) Resolve(ctx context.Context, uploadId string) (models.Upload, error) {
+
+	unlock, err := r.UploadRepository.Lock(uploadId)
+	if err != nil {
+		return models.Upload{}, err
+	}
+	defer unlock()
+
+	upload, err := r.Find(ctx, uploadId)
+	if err != nil {
+		return upload, err
+	}
+
+	//Retry handing the file to storage if it failed when the last chunk arrived
+	if !upload.Completed && upload.Offset == upload.Length {
+		upload, err = r.complete(upload)
+		if err != nil {
+			return upload, err
+		}
+	}
+
+	if !upload.Completed {
+		return upload, models.ErrUploadIncomplete
+	}
+
+	if upload.Claimed {
+		return upload, models.ErrUploadClaimed
+	}
+
+	upload.Claimed = true
+	err = r.UploadRepository.Save(upload)
+	if err != nil {
+		return upload, err
+	}
+
+	return upload, nil
+}
+
+//Give claimed upload back when attaching it failed
+func (r ResumableUploadService) Unclaim(uploadId string) error {
+
+	unlock, err := r.UploadRepository.Lock(uploadId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	upload, err := r.UploadRepository.Find(uploadId)
+	if err != nil {
+		return err
+	}
+
+	upload.Claimed = false
+
+	return r.UploadRepository.Save(upload)
+}
+
+//Forget upload state once its object is owned by a material
+func (r ResumableUploadService) Release(uploadId string) error {
+	return r.UploadRepository.Delete(uploadId)
+}
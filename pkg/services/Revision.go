@@ -0,0 +1,314 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (c CourseService) PublishCourse(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePublish, "You don't have any permission to publish this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Promote Draft
+	revision, err := c.publishRevision(ctx, course, authorization.UserID)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if revision == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusConflict,
+			Message:    "Course was published meanwhile, please retry",
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionCoursePublish, course.ID, nil, []models.FieldChange{{Field: "published_revision", From: course.PublishedRevision, To: revision.Revision}})
+
+	revision.Snapshot = nil
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusCreated,
+		Message:    "Course published successfully",
+		Data:       revision,
+	}, nil
+}
+
+func (c CourseService) ListRevisions(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePreview, "You don't have any permission to view this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Fetch Revisions Without Their Snapshots
+	revisions, err := c.DBRepository.FetchRevisions(ctx, courseId, false)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	for i := range revisions {
+		revisions[i].IsPublished = revisions[i].Revision == course.PublishedRevision
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Revisions fetched successfully",
+		Data:       revisions,
+	}, nil
+}
+
+func (c CourseService) FetchRevision(ctx context.Context, courseId string, revisionNumber string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePreview, "You don't have any permission to view this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Fetch Revision
+	revision, failure := c.findRevision(ctx, course, revisionNumber)
+	if failure != nil {
+		return failure, nil
+	}
+
+	revision.Snapshot.ArrangeMaterials()
+	c.signCourseUrls(revision.Snapshot)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Revision fetched successfully",
+		Data:       revision,
+	}, nil
+}
+
+func (c CourseService) RollbackRevision(ctx context.Context, courseId string, revisionNumber string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCoursePublish, "You don't have any permission to publish this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Fetch Revision
+	revision, failure := c.findRevision(ctx, course, revisionNumber)
+	if failure != nil {
+		return failure, nil
+	}
+
+	//3. Serve The Revision, the draft is kept as it is
+	saved, err := c.DBRepository.SetPublishedRevision(ctx, courseId, revision.Revision)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not found", courseId),
+		}, nil
+	}
+
+	c.audit(ctx, models.AuditActionRollback, course.ID, nil, []models.FieldChange{{Field: "published_revision", From: course.PublishedRevision, To: revision.Revision}})
+
+	revision.IsPublished = true
+	revision.Snapshot = nil
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    fmt.Sprintf("Course rolled back to revision %d", revision.Revision),
+		Data:       revision,
+	}, nil
+}
+
+//Snapshot the draft as the next revision & serve it, nil revision means another publish won meanwhile
+func (c CourseService) publishRevision(ctx context.Context, course *models.Course, userId string) (*models.CourseRevision, error) {
+
+	timeNow := time.Now()
+	revision := models.CourseRevision{
+		ID:          c.DBRepository.GenerateModelID(),
+		CourseID:    course.ID,
+		Revision:    course.LatestRevision + 1,
+		PublishedBy: userId,
+		Snapshot:    course.Snapshot(),
+		CreatedAt:   &timeNow,
+	}
+
+	//1. Store Snapshot, revision numbers are unique so concurrent publishes can't both store theirs
+	err := c.DBRepository.CreateRevision(ctx, revision)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//2. Serve Snapshot, stored snapshot is removed again when the course changed meanwhile
+	published, err := c.DBRepository.PublishRevision(ctx, course.ID.Hex(), course.LatestRevision, revision.Revision)
+	if err != nil || !published {
+		deleteErr := c.DBRepository.DeleteRevision(ctx, revision.ID)
+		if deleteErr != nil {
+			log.Printf("Failed deleting revision %v, %v", revision.ID.Hex(), deleteErr.Error())
+		}
+		return nil, err
+	}
+
+	course.PublishedRevision = revision.Revision
+	course.LatestRevision = revision.Revision
+	revision.IsPublished = true
+
+	return &revision, nil
+}
+
+//Store revision 1 of a course which isn't saved yet & serve it, removing the revision is recorded on rollback
+func (c CourseService) createFirstRevision(ctx context.Context, course *models.Course, userId string, rollback *Saga) error {
+
+	timeNow := time.Now()
+	revision := models.CourseRevision{
+		ID:          c.DBRepository.GenerateModelID(),
+		CourseID:    course.ID,
+		Revision:    1,
+		PublishedBy: userId,
+		Snapshot:    course.Snapshot(),
+		CreatedAt:   &timeNow,
+	}
+
+	err := c.DBRepository.CreateRevision(ctx, revision)
+	if err != nil {
+		return err
+	}
+	rollback.Record("revision "+revision.ID.Hex(), func() error {
+		return c.DBRepository.DeleteRevision(ctx, revision.ID)
+	})
+
+	course.PublishedRevision = revision.Revision
+	course.LatestRevision = revision.Revision
+
+	return nil
+}
+
+func (c CourseService) findRevision(ctx context.Context, course *models.Course, revisionNumber string) (*models.CourseRevision, *response.HttpResponse) {
+
+	number, err := strconv.Atoi(revisionNumber)
+	if err != nil || number < 1 {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Revision %v is not a valid revision number", revisionNumber),
+		}
+	}
+
+	revision, err := c.DBRepository.FetchRevision(ctx, course.ID.Hex(), number)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && revision.Snapshot == nil) {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Revision %v is not found", revisionNumber),
+		}
+	}
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
+	}
+
+	revision.IsPublished = revision.Revision == course.PublishedRevision
+
+	return &revision, nil
+}
+
+//Students are served the published revision while owners & previewers see the draft.
+//Courses stored before revisions existed have no published revision & are served as they are
+func (c CourseService) servePublishedRevisions(ctx context.Context, courses ...*models.Course) error {
+
+	published := make(map[primitive.ObjectID]int)
+	for _, course := range courses {
+		if course.PublishedRevision > 0 && !c.canPreview(ctx, course) {
+			published[course.ID] = course.PublishedRevision
+		}
+	}
+
+	if len(published) == 0 {
+		return nil
+	}
+
+	revisions, err := c.DBRepository.FetchPublishedRevisions(ctx, published)
+	if err != nil {
+		return err
+	}
+
+	for _, course := range courses {
+		if _, serve := published[course.ID]; !serve {
+			continue
+		}
+
+		revision, found := revisions[course.ID]
+		if !found || revision.Snapshot == nil {
+			return errors.New(fmt.Sprintf("Revision %d of course %v is not found", course.PublishedRevision, course.ID.Hex()))
+		}
+
+		course.ApplyRevision(revision.Snapshot)
+	}
+
+	return nil
+}
+
+//Remove objects the draft no longer refers to, objects of stored revisions are kept until the revision is gone.
+//Failures are left for orphan reaper
+func (c CourseService) deleteDraftObjects(ctx context.Context, courseId string, keys []string, prefixes []string) {
+
+	revisions, err := c.DBRepository.FetchRevisions(ctx, courseId, true)
+	if err != nil {
+		log.Printf("Failed fetching revisions of %v, objects are left for orphan reaper, %v", courseId, err.Error())
+		return
+	}
+
+	references := models.NewStorageReferences()
+	for _, revision := range revisions {
+		if revision.Snapshot != nil {
+			references.Add(revision.Snapshot)
+		}
+	}
+
+	for _, key := range keys {
+		if references.IsReferenced(key) {
+			continue
+		}
+		err := c.StorageService.Delete(key)
+		if err != nil {
+			log.Printf("Failed deleting %v, %v", key, err.Error())
+		}
+	}
+
+	for _, prefix := range prefixes {
+		if references.IsPrefixReferenced(prefix) {
+			continue
+		}
+		err := c.StorageService.DeletePrefix(prefix)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"log"
+	"sync"
+)
+
+//Compensating action which undoes a single side effect
+type compensation struct {
+	name   string
+	action func() error
+}
+
+//Saga records a compensating action for every side effect of a multi step operation,
+//on failure they're executed in reverse order. Safe to record from multiple goroutines.
+type Saga struct {
+	lock          sync.Mutex
+	compensations []compensation
+}
+
+func (s *Saga) Record(name string, action func() error) {
+	s.lock.Lock()
+	s.compensations = append(s.compensations, compensation{name: name, action: action})
+	s.lock.Unlock()
+}
+
+//Run every compensating action, returns the compensated side effects & the ones that failed
+func (s *Saga) Compensate() ([]string, []string) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var compensated []string
+	var failed []string
+
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		err := s.compensations[i].action()
+		if err != nil {
+			log.Printf("Failed compensating %v, %v", s.compensations[i].name, err.Error())
+			failed = append(failed, s.compensations[i].name+": "+err.Error())
+			continue
+		}
+		compensated = append(compensated, s.compensations[i].name)
+	}
+
+	s.compensations = nil
+
+	return compensated, failed
+}
+
+//Record giving a claimed resumable upload back as compensation
+func (s *Saga) RecordClaim(uploadService contracts.ResumableUploadService, uploadId string) {
+	s.Record("claim "+uploadId, func() error {
+		return uploadService.Unclaim(uploadId)
+	})
+}
+
+//Record deletion of an uploaded object as compensation
+func (s *Saga) RecordUpload(storageService contracts.StorageService, objectKey string) {
+	s.Record("upload "+objectKey, func() error {
+		return storageService.Delete(objectKey)
+	})
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func (c CourseService) ListSections(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	//Material visibility & urls follow the course response
+	course, err := c.FetchById(ctx, courseId, []string{})
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	sections := course.Sections
+	if sections == nil {
+		sections = []models.Section{}
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Sections fetched successfully",
+		Data:       sections,
+	}, nil
+}
+
+func (c CourseService) CreateSection(ctx context.Context, courseId string, request requests.CreateSectionRequest) (*response.HttpResponse, error) {
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    "Section name is required",
+		}, nil
+	}
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	//2. Sections without order are appended after the last one
+	order := 0
+	for _, section := range course.Sections {
+		if section.Order >= order {
+			order = section.Order + 1
+		}
+	}
+	if request.Order != nil {
+		order = *request.Order
+	}
+
+	timeNow := time.Now()
+	section := models.Section{
+		SectionID:   c.DBRepository.GenerateModelID(),
+		Name:        name,
+		Description: request.Description,
+		Order:       order,
+		UpdatedAt:   &timeNow,
+		CreatedAt:   &timeNow,
+	}
+
+	//3. Save Section
+	saved, err := c.DBRepository.CreateSection(ctx, courseId, section)
+	if err == nil && !saved {
+		err = errors.New(fmt.Sprintf("Course %v is not found", courseId))
+	}
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	c.audit(ctx, models.AuditActionCreateSection, course.ID, nil, sectionChanges(section.SectionID, nil, section))
+
+	section.Materials = []models.Material{}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusCreated,
+		Message:    "Section created successfully",
+		Data:       section,
+	}, nil
+}
+
+func (c CourseService) UpdateSection(ctx context.Context, courseId string, sectionId string, request requests.UpdateSectionRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	section := course.FindSection(sectionId)
+	if section == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", sectionId),
+		}, nil
+	}
+
+	//2. Only given fields are written
+	timeNow := time.Now()
+	before := models.AuditFields(section)
+	fields := map[string]interface{}{"updated_at": timeNow}
+	section.UpdatedAt = &timeNow
+
+	if name := strings.TrimSpace(request.Name); name != "" {
+		fields["name"] = name
+		section.Name = name
+	}
+	if request.Description != nil {
+		fields["description"] = *request.Description
+		section.Description = *request.Description
+	}
+	if request.Order != nil {
+		fields["order"] = *request.Order
+		section.Order = *request.Order
+	}
+
+	//3. Save Section
+	saved, err := c.DBRepository.UpdateSectionFields(ctx, courseId, sectionId, fields)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", sectionId),
+		}, nil
+	}
+
+	c.audit(ctx, models.AuditActionUpdateSection, course.ID, nil, sectionChanges(section.SectionID, before, section))
+
+	for i := range section.Materials {
+		c.signMaterialUrls(&section.Materials[i])
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Section updated successfully",
+		Data:       section,
+	}, nil
+}
+
+func (c CourseService) DeleteSection(ctx context.Context, courseId string, sectionId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to remove this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	section := course.FindSection(sectionId)
+	if section == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", sectionId),
+		}, nil
+	}
+
+	//2. Materials of the section are kept, they follow the materials outside of any section
+	groups := map[string][]models.Material{
+		"": append(course.SectionMaterials(""), course.SectionMaterials(sectionId)...),
+	}
+
+	//3. Remove Section & Move Its Materials In One Write
+	placements := c.materialPlacements(course, groups)
+	saved, err := c.DBRepository.DeleteSection(ctx, courseId, course.Version, sectionId, placements)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !saved {
+		return &response.HttpResponse{
+			StatusCode: http.StatusConflict,
+			Message:    "Course materials were changed meanwhile, please retry",
+		}, nil
+	}
+
+	c.audit(ctx, models.AuditActionDeleteSection, course.ID, nil, append(sectionChanges(section.SectionID, section, nil), placementChanges(course, placements)...))
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Section deleted successfully",
+	}, nil
+}
+
+func (c CourseService) AddSectionMaterials(ctx context.Context, courseId string, sectionId string, request requests.SectionMaterialsRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.FindSection(sectionId) == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", sectionId),
+		}, nil
+	}
+
+	//2. Move Materials Into Section
+	return c.moveMaterials(ctx, course, models.AuditActionPlaceMaterials, sectionId, request), nil
+}
+
+func (c CourseService) RemoveSectionMaterials(ctx context.Context, courseId string, sectionId string, request requests.SectionMaterialsRequest) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedCourse(ctx, courseId, models.ActionCourseUpdate, "You don't have any permission to edit this resources")
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.FindSection(sectionId) == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Section %v is not found", sectionId),
+		}, nil
+	}
+
+	for _, materialId := range request.MaterialIDs {
+		material := c.findMaterial(course, materialId)
+		if material != nil && c.materialSection(course, *material) != sectionId {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is not in section %v", materialId, sectionId),
+			}, nil
+		}
+	}
+
+	//2. Move Materials Out Of Any Section
+	return c.moveMaterials(ctx, course, models.AuditActionUnplaceMaterials, "", request), nil
+}
+
+//Place materials in the given order inside target section, every section they leave is renumbered as well
+func (c CourseService) moveMaterials(ctx context.Context, course *models.Course, action string, targetSectionId string, request requests.SectionMaterialsRequest) *response.HttpResponse {
+
+	//1. Resolve Materials
+	moved := make([]models.Material, 0, len(request.MaterialIDs))
+	movedIds := make(map[string]bool)
+
+	for _, materialId := range request.MaterialIDs {
+		if movedIds[materialId] {
+			return &response.HttpResponse{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("Material %v is given more than once", materialId),
+			}
+		}
+
+		material := c.findMaterial(course, materialId)
+		if material == nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusNotFound,
+				Message:    fmt.Sprintf("Material %v is not found", materialId),
+			}
+		}
+
+		movedIds[materialId] = true
+		moved = append(moved, *material)
+	}
+
+	//2. Remaining Materials Of Every Touched Section
+	groups := map[string][]models.Material{targetSectionId: nil}
+	for _, material := range moved {
+		groups[c.materialSection(course, material)] = nil
+	}
+
+	for sectionId := range groups {
+		remaining := make([]models.Material, 0)
+		for _, material := range course.SectionMaterials(sectionId) {
+			if !movedIds[material.MaterialID.Hex()] {
+				remaining = append(remaining, material)
+			}
+		}
+		groups[sectionId] = remaining
+	}
+
+	//3. Insert Moved Materials At Position
+	target := groups[targetSectionId]
+	position := len(target)
+	if request.Position != nil && *request.Position < position {
+		position = *request.Position
+	}
+
+	placed := make([]models.Material, 0, len(target)+len(moved))
+	placed = append(placed, target[:position]...)
+	placed = append(placed, moved...)
+	placed = append(placed, target[position:]...)
+	groups[targetSectionId] = placed
+
+	//4. Save Placements
+	placements := c.materialPlacements(course, groups)
+	if len(placements) > 0 {
+		saved, err := c.DBRepository.PlaceMaterials(ctx, course.ID.Hex(), course.Version, placements)
+		if err != nil {
+			return &response.HttpResponse{
+				StatusCode: http.StatusInternalServerError,
+				Message:    err.Error(),
+			}
+		}
+		if !saved {
+			return &response.HttpResponse{
+				StatusCode: http.StatusConflict,
+				Message:    "Course materials were changed meanwhile, please retry",
+			}
+		}
+
+		c.audit(ctx, action, course.ID, nil, placementChanges(course, placements))
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Materials moved successfully",
+	}
+}
+
+//Section of the material, empty when it isn't in any existing section
+func (c CourseService) materialSection(course *models.Course, material models.Material) string {
+	if material.SectionID == nil || course.FindSection(material.SectionID.Hex()) == nil {
+		return ""
+	}
+	return material.SectionID.Hex()
+}
+
+//Renumber every group densely from 0, only materials whose section or order changes are written
+func (c CourseService) materialPlacements(course *models.Course, groups map[string][]models.Material) []models.MaterialPlacement {
+
+	placements := make([]models.MaterialPlacement, 0)
+
+	for sectionId, materials := range groups {
+
+		section := course.FindSection(sectionId)
+
+		for order, material := range materials {
+
+			placement := models.MaterialPlacement{MaterialID: material.MaterialID, Order: order}
+			if section != nil {
+				sectionObjectID := section.SectionID
+				placement.SectionID = &sectionObjectID
+			}
+
+			sameSection := (placement.SectionID == nil && material.SectionID == nil) ||
+				(placement.SectionID != nil && material.SectionID != nil && *placement.SectionID == *material.SectionID)
+			if sameSection && material.Order == order {
+				continue
+			}
+
+			placements = append(placements, placement)
+		}
+	}
+
+	return placements
+}
@@ -3,6 +3,9 @@ package services
 import (
 	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/http/response"
+	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 )
 
@@ -18,6 +21,15 @@ func (s StorageService) UploadFile(file *multipart.FileHeader, prefix string) (r
 	return res, nil
 }
 
+func (s StorageService) UploadReader(reader io.Reader, filename string, prefix string) (response.S3Response, error) {
+	return s.StorageRepository.UploadReader(reader, filename, prefix)
+}
+
+//Store reader under exact object key
+func (s StorageService) PutObject(reader io.Reader, objectKey string, contentType string) (response.S3Response, error) {
+	return s.StorageRepository.PutObject(reader, objectKey, contentType)
+}
+
 func (s StorageService) Delete(objectKey string) error {
 	err := s.StorageRepository.DeleteObject(&objectKey)
 	if err != nil {
@@ -35,6 +47,49 @@ func (s StorageService) UploadFiles(files []*multipart.FileHeader, prefix string
 	return res, nil
 }
 
+func (s StorageService) CreatePresignedUpload(filename string, prefix string, contentType string, size int64) (response.PresignedUpload, error) {
+	return s.StorageRepository.CreatePresignedUpload(filename, prefix, contentType, size)
+}
+
+func (s StorageService) CompletePresignedUpload(objectKey string, uploadId string, parts []response.UploadedPart) (response.S3Response, error) {
+	return s.StorageRepository.CompletePresignedUpload(objectKey, uploadId, parts)
+}
+
+func (s StorageService) GetObject(objectKey string) (io.ReadCloser, error) {
+	return s.StorageRepository.GetObject(objectKey)
+}
+
+func (s StorageService) ListObjects(prefix string) ([]response.StorageObject, error) {
+	return s.StorageRepository.ListObjects(prefix)
+}
+
+//Delete every object under prefix, remaining objects are left for orphan reaper
+func (s StorageService) DeletePrefix(prefix string) error {
+
+	if prefix == "" {
+		return errors.New("Prefix must not be empty")
+	}
+
+	objects, err := s.StorageRepository.ListObjects(prefix)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, object := range objects {
+		err := s.Delete(object.Key)
+		if err != nil {
+			failed = append(failed, object.Key)
+		}
+	}
+
+	if len(failed) > 0 {
+		return errors.New(fmt.Sprintf("Failed deleting %v objects under %v", len(failed), prefix))
+	}
+
+	return nil
+}
+
 func ConstructStorageService(storageRepository *contracts.StorageRepository) contracts.StorageService {
 	return &StorageService{StorageRepository: *storageRepository}
 }
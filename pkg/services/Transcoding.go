@@ -0,0 +1,329 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+//Transcode material videos into HLS renditions & extract their previews in background workers
+type TranscodingService struct {
+	DBRepository   contracts.CourseDatabaseRepository
+	StorageService contracts.StorageService
+	Transcoder     contracts.Transcoder
+	workDir        string
+	queue          chan models.TranscodeJob
+	sweepInterval  time.Duration
+}
+
+func ConstructTranscodingService(dbRepository *contracts.CourseDatabaseRepository, storageService *contracts.StorageService, transcoder *contracts.Transcoder, workDir string, queueSize int) contracts.TranscodingService {
+	return &TranscodingService{
+		DBRepository:   *dbRepository,
+		StorageService: *storageService,
+		Transcoder:     *transcoder,
+		workDir:        workDir,
+		queue:          make(chan models.TranscodeJob, queueSize),
+		sweepInterval:  5 * time.Minute,
+	}
+}
+
+//Queue job without blocking the request, when the queue is full the material stays pending until the next sweep
+func (t TranscodingService) Enqueue(job models.TranscodeJob) {
+	t.tryEnqueue(job)
+}
+
+func (t TranscodingService) tryEnqueue(job models.TranscodeJob) bool {
+	select {
+	case t.queue <- job:
+		return true
+	default:
+		log.Printf("Transcoding queue is full, material %v stays pending", job.MaterialID)
+		return false
+	}
+}
+
+//Start workers & periodically queue materials left pending while the queue was full
+func (t TranscodingService) Start(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-t.queue:
+					t.process(ctx, job)
+				}
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(t.sweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := t.queuePending(ctx, false)
+				if err != nil {
+					log.Println(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+//Queue materials which were left pending or interrupted while processing
+func (t TranscodingService) Recover(ctx context.Context) error {
+	return t.queuePending(ctx, true)
+}
+
+//Interrupted jobs are reset only on recovery, while running they belong to a worker
+func (t TranscodingService) queuePending(ctx context.Context, resetInterrupted bool) error {
+
+	courses, err := t.DBRepository.FetchAllWithDeleted(ctx, []string{})
+	if err != nil {
+		return err
+	}
+
+	full := false
+
+	for i := range courses {
+
+		if courses[i].DeletedAt != nil {
+			continue
+		}
+
+		for _, material := range courses[i].Materials {
+
+			if material.DeletedAt != nil || material.Key == "" {
+				continue
+			}
+
+			if material.Status != models.MaterialStatusPending && (!resetInterrupted || material.Status != models.MaterialStatusProcessing) {
+				continue
+			}
+
+			//Interrupted jobs start over
+			if material.Status == models.MaterialStatusProcessing {
+				_, err := t.DBRepository.UpdateMaterialFields(ctx, courses[i].ID.Hex(), material.MaterialID.Hex(),
+					map[string]interface{}{"key": material.Key, "status": models.MaterialStatusProcessing},
+					map[string]interface{}{"status": models.MaterialStatusPending})
+				if err != nil {
+					log.Println(err.Error())
+					continue
+				}
+			}
+
+			//Once the queue is full remaining materials stay pending for the next sweep
+			if full {
+				continue
+			}
+
+			full = !t.tryEnqueue(models.TranscodeJob{
+				CourseID:     courses[i].ID.Hex(),
+				CoursePrefix: courses[i].CourseID + "/",
+				MaterialID:   material.MaterialID.Hex(),
+				Key:          material.Key,
+			})
+		}
+	}
+
+	return nil
+}
+
+func (t TranscodingService) process(ctx context.Context, job models.TranscodeJob) {
+
+	transcodeErr := t.transcode(ctx, job)
+	if transcodeErr == nil {
+		return
+	}
+
+	log.Printf("Failed transcoding material %v, %v", job.MaterialID, transcodeErr.Error())
+
+	_, err := t.DBRepository.UpdateMaterialFields(ctx, job.CourseID, job.MaterialID,
+		map[string]interface{}{"key": job.Key, "status": models.MaterialStatusProcessing},
+		map[string]interface{}{"status": models.MaterialStatusFailed, "status_error": transcodeErr.Error()})
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func (t TranscodingService) transcode(ctx context.Context, job models.TranscodeJob) error {
+
+	//1. Claim the job, material may be removed, already claimed or its video replaced
+	claimed, err := t.DBRepository.UpdateMaterialFields(ctx, job.CourseID, job.MaterialID,
+		map[string]interface{}{"key": job.Key, "status": models.MaterialStatusPending},
+		map[string]interface{}{"status": models.MaterialStatusProcessing, "status_error": ""})
+	if err != nil {
+		return err
+	}
+
+	if !claimed {
+		log.Printf("Skipping transcode of material %v, it's no longer pending", job.MaterialID)
+		return nil
+	}
+
+	//2. Download source video into work directory
+	workDir, err := ioutil.TempDir(t.workDir, "transcode-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	source := filepath.Join(workDir, "source"+path.Ext(job.Key))
+
+	err = t.download(job.Key, source)
+	if err != nil {
+		return err
+	}
+
+	//3. Previews are optional, failing to extract them doesn't stop transcoding
+	err = t.previews(ctx, job, source, filepath.Join(workDir, "previews"))
+	if err != nil {
+		log.Printf("Failed extracting previews of material %v, %v", job.MaterialID, err.Error())
+	}
+
+	//4. Transcode into HLS renditions
+	outputDir := filepath.Join(workDir, "hls")
+
+	err = t.Transcoder.TranscodeHls(ctx, source, outputDir)
+	if err != nil {
+		return err
+	}
+
+	//5. Upload renditions next to the video under course prefix
+	prefix := fmt.Sprintf("%shls/%s/%d/", job.CoursePrefix, job.MaterialID, time.Now().Unix())
+
+	err = t.upload(outputDir, prefix)
+	if err != nil {
+		t.cleanup(prefix)
+		return err
+	}
+
+	//6. Attach playlist as long as the material still uses the transcoded video
+	attached, err := t.DBRepository.UpdateMaterialFields(ctx, job.CourseID, job.MaterialID,
+		map[string]interface{}{"key": job.Key, "status": models.MaterialStatusProcessing},
+		map[string]interface{}{"status": models.MaterialStatusReady, "status_error": "", "playlist_key": prefix + "master.m3u8"})
+	if err != nil || !attached {
+		t.cleanup(prefix)
+		return err
+	}
+
+	log.Printf("Material %v transcoded into %v", job.MaterialID, prefix)
+
+	return nil
+}
+
+//Extract poster frame & thumbnail sprite, then attach them to the material
+func (t TranscodingService) previews(ctx context.Context, job models.TranscodeJob, source string, outputDir string) error {
+
+	err := t.Transcoder.ExtractPreviews(ctx, source, outputDir)
+	if err != nil {
+		return err
+	}
+
+	prefix := fmt.Sprintf("%spreviews/%s/%d/", job.CoursePrefix, job.MaterialID, time.Now().Unix())
+
+	err = t.upload(outputDir, prefix)
+	if err != nil {
+		t.cleanup(prefix)
+		return err
+	}
+
+	attached, err := t.DBRepository.UpdateMaterialFields(ctx, job.CourseID, job.MaterialID,
+		map[string]interface{}{"key": job.Key, "status": models.MaterialStatusProcessing},
+		map[string]interface{}{"poster_key": prefix + "poster.jpg", "thumbnails_key": prefix + "thumbnails.vtt"})
+	if err != nil || !attached {
+		t.cleanup(prefix)
+		return err
+	}
+
+	return nil
+}
+
+func (t TranscodingService) download(objectKey string, target string) error {
+
+	object, err := t.StorageService.GetObject(objectKey)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	destination, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(destination, object)
+	if err != nil {
+		_ = destination.Close()
+		return err
+	}
+
+	return destination.Close()
+}
+
+func (t TranscodingService) upload(outputDir string, prefix string) error {
+
+	return filepath.Walk(outputDir, func(current string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relative, err := filepath.Rel(outputDir, current)
+		if err != nil {
+			return err
+		}
+
+		file, err := os.Open(current)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		uploaded, err := t.StorageService.PutObject(file, prefix+filepath.ToSlash(relative), mediaContentType(current))
+		if err != nil {
+			return err
+		}
+
+		if !uploaded.Success {
+			return errors.New(uploaded.Message)
+		}
+
+		return nil
+	})
+}
+
+func (t TranscodingService) cleanup(prefix string) {
+	err := t.StorageService.DeletePrefix(prefix)
+	if err != nil {
+		log.Println(err.Error())
+	}
+}
+
+func mediaContentType(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
+	case ".jpg":
+		return "image/jpeg"
+	case ".vtt":
+		return "text/vtt"
+	}
+	return ""
+}
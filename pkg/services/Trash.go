@@ -0,0 +1,215 @@
+package services
+
+import (
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/response"
+	"acourse-course-service/pkg/models"
+	"context"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (c CourseService) FetchTrash(ctx context.Context) (*response.HttpResponse, error) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	userId, err := strconv.ParseInt(authorization.UserID, 10, 64)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}, nil
+	}
+
+	courses, err := c.DBRepository.FetchTrash(ctx, userId)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+
+	//Trashed courses are restored with their trashed materials, so those are listed on their own only for live courses
+	trash := models.Trash{Courses: []*models.Course{}, Materials: []models.TrashedMaterial{}}
+
+	for i := range courses {
+		if courses[i].DeletedAt != nil {
+			c.signCourseUrls(&courses[i])
+			trash.Courses = append(trash.Courses, &courses[i])
+			continue
+		}
+
+		for _, material := range courses[i].TrashedMaterials {
+			c.signMaterialUrls(&material)
+			trash.Materials = append(trash.Materials, models.TrashedMaterial{
+				CourseID:   courses[i].ID,
+				CourseName: courses[i].Name,
+				Material:   material,
+			})
+		}
+	}
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Trash fetched successfully",
+		Data:       trash,
+	}, nil
+}
+
+func (c CourseService) RestoreCourse(ctx context.Context, courseId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Trashed Course
+	course, failure := c.fetchAuthorizedTrash(ctx, courseId, models.ActionCourseRestore)
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.DeletedAt == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Course %v is not in trash", courseId),
+		}, nil
+	}
+
+	//2. Restore Course
+	restored, err := c.DBRepository.RestoreCourse(ctx, courseId)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !restored {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not in trash anymore", courseId),
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionCourseRestore, course.ID, nil, []models.FieldChange{{Field: "deleted_at", From: *course.DeletedAt}})
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Course restored successfully",
+	}, nil
+}
+
+func (c CourseService) RestoreMaterial(ctx context.Context, courseId string, materialId string) (*response.HttpResponse, error) {
+
+	//1. Fetch & Authorize Course
+	course, failure := c.fetchAuthorizedTrash(ctx, courseId, models.ActionMaterialRestore)
+	if failure != nil {
+		return failure, nil
+	}
+
+	if course.DeletedAt != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Course %v is in trash, restore the course first", courseId),
+		}, nil
+	}
+
+	var material *models.Material
+	for i := range course.TrashedMaterials {
+		if course.TrashedMaterials[i].MaterialID.Hex() == materialId {
+			material = &course.TrashedMaterials[i]
+		}
+	}
+	if material == nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not in trash", materialId),
+		}, nil
+	}
+
+	//2. Material goes back to the end of its section, unless the section was removed meanwhile
+	timeNow := time.Now()
+	before := models.AuditFields(material)
+	material.DeletedAt = nil
+	material.UpdatedAt = &timeNow
+	if material.SectionID != nil && course.FindSection(material.SectionID.Hex()) == nil {
+		material.SectionID = nil
+	}
+
+	sectionId := ""
+	if material.SectionID != nil {
+		sectionId = material.SectionID.Hex()
+	}
+	material.Order = 0
+	if siblings := course.SectionMaterials(sectionId); len(siblings) > 0 {
+		material.Order = siblings[len(siblings)-1].Order + 1
+	}
+
+	restored, err := c.DBRepository.RestoreMaterial(ctx, courseId, *material)
+	if err != nil {
+		return &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}, err
+	}
+	if !restored {
+		return &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Material %v is not in trash anymore", materialId),
+		}, nil
+	}
+
+	c.audit(ctx, models.ActionMaterialRestore, course.ID, &material.MaterialID, models.DiffFields(before, material))
+
+	c.signMaterialUrls(material)
+
+	return &response.HttpResponse{
+		StatusCode: http.StatusOK,
+		Message:    "Material restored successfully",
+		Data:       material,
+	}, nil
+}
+
+//Same as fetchAuthorizedCourse, but trashed courses & materials are included
+func (c CourseService) fetchAuthorizedTrash(ctx context.Context, courseId string, action string) (*models.Course, *response.HttpResponse) {
+
+	authorization := ctx.Value(middleware.AuthorizationKey).(*middleware.Authorization)
+
+	if _, err := primitive.ObjectIDFromHex(courseId); err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("Course id %v is invalid", courseId),
+		}
+	}
+
+	course, err := c.DBRepository.FetchByIdWithDeleted(ctx, courseId)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("Course %v is not found", courseId),
+		}
+	}
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
+	}
+
+	validated, err := c.AuthorizeResourceOwner(course, *authorization, action)
+	if err != nil {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusInternalServerError,
+			Message:    err.Error(),
+		}
+	}
+
+	if !validated {
+		return nil, &response.HttpResponse{
+			StatusCode: http.StatusUnauthorized,
+			Message:    "You don't have any permission to restore this resources",
+		}
+	}
+
+	return course, nil
+}
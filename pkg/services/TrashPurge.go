@@ -0,0 +1,168 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"time"
+)
+
+//Permanently remove trashed courses & materials once their retention period is over
+type TrashPurgeService struct {
+	DBRepository    contracts.CourseDatabaseRepository
+	StorageService  contracts.StorageService
+	AuditRepository contracts.AuditRepository
+	retention       time.Duration
+}
+
+func ConstructTrashPurgeService(dbRepository *contracts.CourseDatabaseRepository, storageService *contracts.StorageService, auditRepository *contracts.AuditRepository, retention time.Duration) contracts.TrashPurgeService {
+	return &TrashPurgeService{
+		DBRepository:    *dbRepository,
+		StorageService:  *storageService,
+		AuditRepository: *auditRepository,
+		retention:       retention,
+	}
+}
+
+func (p TrashPurgeService) Purge(ctx context.Context) (models.PurgeReport, error) {
+
+	report := models.PurgeReport{
+		Retention: p.retention.String(),
+		StartedAt: time.Now(),
+	}
+
+	cutoff := report.StartedAt.Add(-p.retention)
+
+	courses, err := p.DBRepository.FetchAllWithDeleted(ctx, []string{})
+	if err != nil {
+		return report, err
+	}
+
+	//Objects of trashed materials may still be published by a revision of their course
+	revisions, err := p.DBRepository.FetchAllRevisions(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	revisionReferences := make(map[primitive.ObjectID]*models.StorageReferences)
+	for _, revision := range revisions {
+		if revision.Snapshot == nil {
+			continue
+		}
+		if revisionReferences[revision.CourseID] == nil {
+			revisionReferences[revision.CourseID] = models.NewStorageReferences()
+		}
+		revisionReferences[revision.CourseID].Add(revision.Snapshot)
+	}
+
+	for i := range courses {
+
+		course := &courses[i]
+
+		//1. Whole course, documents are removed first so objects of a course restored meanwhile are kept
+		if models.IsPastRetention(course.DeletedAt, cutoff) {
+			purged, err := p.DBRepository.PurgeCourse(ctx, course.ID.Hex(), cutoff)
+			if err != nil {
+				log.Printf("Failed purging course %v, %v", course.ID.Hex(), err.Error())
+				continue
+			}
+			if purged {
+				report.PurgedCourses++
+				auditSystemMutation(ctx, p.AuditRepository, models.AuditActionPurgeCourse, course.ID, nil, []models.FieldChange{{Field: "deleted_at", From: *course.DeletedAt}})
+				report.FailedObjects += p.deleteObjects(course.StorageKeys(), course.StoragePrefixes(), models.NewStorageReferences())
+
+				//Objects only revisions referred to are left for orphan reaper
+				err := p.DBRepository.DeleteRevisions(ctx, course.ID.Hex())
+				if err != nil {
+					log.Printf("Failed purging revisions of course %v, %v", course.ID.Hex(), err.Error())
+				}
+			}
+			continue
+		}
+
+		//2. Trashed materials of live courses
+		references := revisionReferences[course.ID]
+		if references == nil {
+			references = models.NewStorageReferences()
+		}
+
+		for _, material := range course.TrashedMaterials {
+
+			if !models.IsPastRetention(material.DeletedAt, cutoff) {
+				continue
+			}
+
+			purged, err := p.DBRepository.PurgeTrashedMaterial(ctx, course.ID.Hex(), material.MaterialID, cutoff)
+			if err != nil {
+				log.Printf("Failed purging material %v, %v", material.MaterialID.Hex(), err.Error())
+				continue
+			}
+			if purged {
+				report.PurgedMaterials++
+				materialId := material.MaterialID
+				auditSystemMutation(ctx, p.AuditRepository, models.AuditActionPurgeMaterial, course.ID, &materialId, []models.FieldChange{{Field: "deleted_at", From: *material.DeletedAt}})
+				report.FailedObjects += p.deleteObjects(material.StorageKeys(), material.StoragePrefixes(), references)
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now()
+
+	return report, nil
+}
+
+//Objects still referenced are kept, objects failed to delete are left for orphan reaper
+func (p TrashPurgeService) deleteObjects(keys []string, prefixes []string, references *models.StorageReferences) int {
+
+	failed := 0
+
+	for _, key := range keys {
+		if references.IsReferenced(key) {
+			continue
+		}
+		err := p.StorageService.Delete(key)
+		if err != nil {
+			log.Printf("Failed deleting %v, %v", key, err.Error())
+			failed++
+		}
+	}
+
+	for _, prefix := range prefixes {
+		if references.IsPrefixReferenced(prefix) {
+			continue
+		}
+		err := p.StorageService.DeletePrefix(prefix)
+		if err != nil {
+			log.Printf("Failed deleting %v, %v", prefix, err.Error())
+			failed++
+		}
+	}
+
+	return failed
+}
+
+//Run purge periodically until context is cancelled
+func (p TrashPurgeService) Schedule(ctx context.Context, interval time.Duration) {
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report, err := p.Purge(ctx)
+				if err != nil {
+					log.Printf("Trash purge failed, %v", err.Error())
+					continue
+				}
+				log.Printf("Trash purge removed %d courses & %d materials older than %v, %d objects failed",
+					report.PurgedCourses, report.PurgedMaterials, report.Retention, report.FailedObjects)
+			}
+		}
+	}()
+}
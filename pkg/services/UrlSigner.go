@@ -0,0 +1,154 @@
+package services
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/signature"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/cloudfront/sign"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Escape each key segment, keys contain spaces & colons from the upload timestamp
+func objectKeyUrl(baseUrl string, objectKey string) string {
+
+	segments := strings.Split(objectKey, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return strings.TrimSuffix(baseUrl, "/") + "/" + strings.Join(segments, "/")
+}
+
+//Directory of an object key, shared with the objects it references relatively
+func keyDirectory(objectKey string) string {
+	return path.Dir(objectKey) + "/"
+}
+
+//Rewrite object keys to a plain CDN (or bucket) host
+type CdnUrlSigner struct {
+	baseUrl string
+}
+
+func ConstructCdnUrlSigner(baseUrl string) contracts.UrlSigner {
+	return &CdnUrlSigner{baseUrl: baseUrl}
+}
+
+func (s CdnUrlSigner) SignUrl(objectKey string) (string, error) {
+	if objectKey == "" {
+		return "", nil
+	}
+	return objectKeyUrl(s.baseUrl, objectKey), nil
+}
+
+func (s CdnUrlSigner) SignPrefixUrl(prefix string, objectKey string) (string, error) {
+	return s.SignUrl(objectKey)
+}
+
+//CloudFront signed url using canned policy
+type CloudFrontUrlSigner struct {
+	baseUrl string
+	signer  *sign.URLSigner
+	ttl     time.Duration
+}
+
+func ConstructCloudFrontUrlSigner(baseUrl string, keyPairId string, privateKeyPath string, ttl time.Duration) (contracts.UrlSigner, error) {
+
+	if keyPairId == "" {
+		return nil, errors.New("CloudFront key pair id is not configured")
+	}
+
+	privateKey, err := sign.LoadPEMPrivKeyFile(privateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloudFrontUrlSigner{
+		baseUrl: baseUrl,
+		signer:  sign.NewURLSigner(keyPairId, privateKey),
+		ttl:     ttl,
+	}, nil
+}
+
+func (s CloudFrontUrlSigner) SignUrl(objectKey string) (string, error) {
+	if objectKey == "" {
+		return "", nil
+	}
+	return s.signer.Sign(objectKeyUrl(s.baseUrl, objectKey), time.Now().Add(s.ttl))
+}
+
+//Custom policy with a wildcard resource, players have to pass its query to requests of the referenced objects
+func (s CloudFrontUrlSigner) SignPrefixUrl(prefix string, objectKey string) (string, error) {
+
+	if objectKey == "" {
+		return "", nil
+	}
+
+	policy := &sign.Policy{
+		Statements: []sign.Statement{{
+			Resource: objectKeyUrl(s.baseUrl, prefix) + "*",
+			Condition: sign.Condition{
+				DateLessThan: sign.NewAWSEpochTime(time.Now().Add(s.ttl)),
+			},
+		}},
+	}
+
+	return s.signer.SignWithPolicy(objectKeyUrl(s.baseUrl, objectKey), policy)
+}
+
+//HMAC signed url verified by local storage handler
+type HmacUrlSigner struct {
+	baseUrl string
+	secret  string
+	ttl     time.Duration
+}
+
+func ConstructHmacUrlSigner(baseUrl string, secret string, ttl time.Duration) (contracts.UrlSigner, error) {
+
+	if secret == "" {
+		return nil, errors.New("Local storage secret is not configured")
+	}
+
+	return &HmacUrlSigner{
+		baseUrl: baseUrl,
+		secret:  secret,
+		ttl:     ttl,
+	}, nil
+}
+
+func (s HmacUrlSigner) SignUrl(objectKey string) (string, error) {
+
+	if objectKey == "" {
+		return "", nil
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", signature.Sign(s.secret, http.MethodGet, objectKey, expires))
+
+	return objectKeyUrl(s.baseUrl, objectKey) + "?" + query.Encode(), nil
+}
+
+//Expiration & signature are path segments, relative references resolved from the url carry them as well
+func (s HmacUrlSigner) SignPrefixUrl(prefix string, objectKey string) (string, error) {
+
+	if objectKey == "" {
+		return "", nil
+	}
+
+	if !strings.HasPrefix(objectKey, prefix) || !strings.HasSuffix(prefix, "/") {
+		return "", errors.New(fmt.Sprintf("Object %v is not under prefix %v", objectKey, prefix))
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	token := strings.Join([]string{signature.PrefixSegment, expires, signature.SignPrefix(s.secret, prefix, expires)}, "/")
+
+	return objectKeyUrl(s.baseUrl, token+"/"+objectKey), nil
+}
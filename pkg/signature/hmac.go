@@ -0,0 +1,36 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+//Sign payload using HMAC-SHA256, each payload segment is separated by a newline
+func Sign(secret string, payload ...string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(payload, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+//Verify signature against payload in constant time
+func Verify(secret string, signature string, payload ...string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, payload...)), []byte(signature))
+}
+
+//First path segment of prefix signed urls, followed by expiration & signature segments and the object key
+const PrefixSegment = "_signed"
+
+//Sign read access to every object under prefix
+func SignPrefix(secret string, prefix string, expires string) string {
+	return Sign(secret, http.MethodGet, "prefix", prefix, expires)
+}
+
+func VerifyPrefix(secret string, signature string, prefix string, expires string) bool {
+	return Verify(secret, signature, http.MethodGet, "prefix", prefix, expires)
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/services"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testKeys struct {
+	rsa    *rsa.PrivateKey
+	ecdsa  *ecdsa.PrivateKey
+	secret []byte
+}
+
+func encode(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func setupAuthenticator(t *testing.T) (middleware.Authenticator, testKeys) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	keys := testKeys{rsa: rsaKey, ecdsa: ecdsaKey, secret: []byte("a shared secret of the gateway")}
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kid": "rsa", "kty": "RSA", "use": "sig", "n": "%s", "e": "%s"},
+		{"kid": "ec", "kty": "EC", "crv": "P-256", "x": "%s", "y": "%s"},
+		{"kid": "hmac", "kty": "oct", "alg": "HS256", "k": "%s"}
+	]}`,
+		encode(rsaKey.N.Bytes()), encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+		encode(ecdsaKey.X.FillBytes(make([]byte, 32))), encode(ecdsaKey.Y.FillBytes(make([]byte, 32))),
+		encode(keys.secret))
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, []byte(jwks), 0600))
+
+	keySet, err := services.ConstructFileJwksKeySet(path)
+	assert.NoError(t, err)
+
+	var verificationKeySet contracts.VerificationKeySet = keySet
+	authenticator, err := services.ConstructJwtAuthenticator(&verificationKeySet, "https://auth.acourse.test", "course-service")
+	assert.NoError(t, err)
+
+	return authenticator, keys
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":        "42",
+		"role":       "instructor",
+		"permission": "cru",
+		"iss":        "https://auth.acourse.test",
+		"aud":        "course-service",
+		"exp":        time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func bearerRequest(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) *http.Request {
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	assert.NoError(t, err)
+
+	request, _ := http.NewRequest(http.MethodGet, "/course/list", nil)
+	request.Header.Set("Authorization", "Bearer "+signed)
+	return request
+}
+
+func TestJwtAuthenticatorMapsClaims(t *testing.T) {
+
+	authenticator, keys := setupAuthenticator(t)
+
+	authorization, err := authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodRS256, "rsa", validClaims(), keys.rsa))
+	assert.NoError(t, err)
+	assert.Equal(t, &middleware.Authorization{UserID: "42", Role: "instructor", Permission: "cru"}, authorization)
+
+	claims := validClaims()
+	claims["permission"] = []interface{}{"create", "delete"}
+	authorization, err = authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodES256, "ec", claims, keys.ecdsa))
+	assert.NoError(t, err)
+	assert.Equal(t, "cd", authorization.Permission)
+
+	_, err = authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodHS256, "hmac", validClaims(), keys.secret))
+	assert.NoError(t, err)
+}
+
+func TestJwtAuthenticatorRejectsInvalidTokens(t *testing.T) {
+
+	authenticator, keys := setupAuthenticator(t)
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	withoutExpiry := validClaims()
+	delete(withoutExpiry, "exp")
+
+	notYetValid := validClaims()
+	notYetValid["nbf"] = time.Now().Add(time.Hour).Unix()
+
+	otherIssuer := validClaims()
+	otherIssuer["iss"] = "https://evil.test"
+
+	otherAudience := validClaims()
+	otherAudience["aud"] = []string{"billing-service"}
+
+	for name, claims := range map[string]jwt.MapClaims{
+		"expired": expired, "without expiry": withoutExpiry, "not yet valid": notYetValid,
+		"other issuer": otherIssuer, "other audience": otherAudience,
+	} {
+		_, err := authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodRS256, "rsa", claims, keys.rsa))
+		assert.Error(t, err, name)
+	}
+
+	//Key of another algorithm can't verify the token, even when its kid is given
+	_, err := authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodHS256, "rsa", validClaims(), keys.rsa.PublicKey.N.Bytes()))
+	assert.Error(t, err)
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = authenticator.Authenticate(bearerRequest(t, jwt.SigningMethodRS256, "rsa", validClaims(), otherKey))
+	assert.Error(t, err)
+}
+
+func TestGatewayHeadersNeedTrustedGateway(t *testing.T) {
+
+	authenticator, _ := setupAuthenticator(t)
+
+	request, _ := http.NewRequest(http.MethodGet, "/course/list", nil)
+	request.Header.Set("X-User-Id", "42")
+	request.Header.Set("X-User-Role", "admin")
+	request.Header.Set("X-User-Permission", "crud")
+
+	authorization, err := authenticator.Authenticate(request)
+	assert.NoError(t, err)
+	assert.Nil(t, authorization)
+
+	authorization, err = middleware.TrustedGatewayAuthenticator{}.Authenticate(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "42", authorization.UserID)
+}
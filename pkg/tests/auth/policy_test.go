@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	"acourse-course-service/pkg/services"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDefaultAccessPolicy(t *testing.T) {
+
+	policy, err := services.ConstructYamlAccessPolicy("../../../policy.yaml")
+	assert.Nil(t, err)
+
+	instructor := middleware.Authorization{UserID: "1", Role: "instructor", Permission: "cru"}
+	student := middleware.Authorization{UserID: "2", Role: "student", Permission: "r"}
+	admin := middleware.Authorization{UserID: "3", Role: "admin", Permission: "r"}
+
+	assert.True(t, policy.Allows(instructor, models.ActionCourseCreate, ""))
+	assert.True(t, policy.Allows(instructor, models.ActionCoursePublish, models.CourseRoleOwner))
+	assert.False(t, policy.Allows(instructor, models.ActionCoursePublish, ""))
+	assert.False(t, policy.Allows(instructor, models.ActionMaterialDelete, models.CourseRoleOwner))
+	assert.True(t, policy.MayPerform(instructor, models.ActionMaterialUpdate))
+
+	assert.True(t, policy.Allows(student, models.ActionCourseRead, ""))
+	assert.False(t, policy.MayPerform(student, models.ActionCourseCreate))
+
+	assert.True(t, policy.Allows(admin, models.ActionMaterialDelete, ""))
+
+	assert.False(t, policy.Allows(instructor, models.ActionCourseImpersonate, ""))
+	assert.True(t, policy.Allows(admin, models.ActionCourseImpersonate, ""))
+}
+
+func TestAccessPolicyCollaboratorRoles(t *testing.T) {
+
+	policy, err := services.ConstructYamlAccessPolicy("../../../policy.yaml")
+	assert.Nil(t, err)
+
+	collaborator := middleware.Authorization{UserID: "4", Role: "instructor", Permission: "crud"}
+
+	assert.True(t, policy.Allows(collaborator, models.ActionMaterialUpdate, models.CourseRoleEditor))
+	assert.True(t, policy.Allows(collaborator, models.ActionMaterialDelete, models.CourseRoleEditor))
+	assert.False(t, policy.Allows(collaborator, models.ActionCourseDelete, models.CourseRoleEditor))
+	assert.False(t, policy.Allows(collaborator, models.ActionCourseShare, models.CourseRoleEditor))
+	assert.False(t, policy.Allows(collaborator, models.ActionMaterialUpdate, models.CourseRoleViewer))
+	assert.True(t, policy.Allows(collaborator, models.ActionCoursePreview, models.CourseRoleViewer))
+	assert.True(t, policy.Allows(collaborator, models.ActionCourseTransfer, models.CourseRoleOwner))
+}
+
+func TestAccessPolicyRules(t *testing.T) {
+
+	policy, err := services.ParseAccessPolicy([]byte(`
+rules:
+  - roles: [editor]
+    scopes: [course:write]
+    actions: ["course:*"]
+    condition: owner
+`))
+	assert.Nil(t, err)
+
+	editor := middleware.Authorization{Role: "editor", Permission: "course:read, course:write"}
+
+	assert.True(t, policy.Allows(editor, models.ActionCourseDelete, models.CourseRoleOwner))
+	assert.False(t, policy.Allows(editor, models.ActionMaterialDelete, models.CourseRoleOwner))
+	assert.False(t, policy.Allows(middleware.Authorization{Role: "editor", Permission: "course:read"}, models.ActionCourseUpdate, models.CourseRoleOwner))
+	assert.False(t, policy.Allows(middleware.Authorization{Role: "student", Permission: "course:write"}, models.ActionCourseUpdate, models.CourseRoleOwner))
+
+	_, err = services.ParseAccessPolicy([]byte(`
+rules:
+  - actions: [course:update]
+    condition: owners
+`))
+	assert.NotNil(t, err)
+}
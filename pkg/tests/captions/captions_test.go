@@ -0,0 +1,68 @@
+package captions
+
+import (
+	"acourse-course-service/pkg/captions"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSrtIsNormalizedToVtt(t *testing.T) {
+
+	srt := "\xEF\xBB\xBF1\r\n00:00:01,000 --> 00:00:04,500\r\nHello, world\r\n\r\n2\r\n00:01:02,250 --> 00:01:03,000 X1:10 X2:20 Y1:0 Y2:5\r\nSecond line\r\nwraps\r\n"
+
+	cues, err := captions.Parse([]byte(srt))
+
+	assert.Nil(t, err)
+	assert.Len(t, cues, 2)
+	assert.Equal(t, time.Second, cues[0].Start)
+	assert.Equal(t, 4500*time.Millisecond, cues[0].End)
+	assert.Equal(t, "WEBVTT\n\n1\n00:00:01.000 --> 00:00:04.500\nHello, world\n\n2\n00:01:02.250 --> 00:01:03.000\nSecond line\nwraps\n", string(captions.RenderVtt(cues)))
+}
+
+func TestVttKeepsSettingsAndSkipsNotes(t *testing.T) {
+
+	vtt := "WEBVTT - lecture\n\nNOTE reviewed by editor\n\nintro\n00:05.000 --> 00:07.000 align:start line:0\nWelcome\n\n00:00.500 --> 00:01.000\nFirst\n"
+
+	cues, err := captions.Parse([]byte(vtt))
+
+	assert.Nil(t, err)
+	assert.Len(t, cues, 2)
+	assert.Equal(t, "First", cues[0].Text)
+	assert.Equal(t, "intro", cues[1].ID)
+	assert.Equal(t, "align:start line:0", cues[1].Settings)
+}
+
+func TestInvalidCaptions(t *testing.T) {
+
+	_, err := captions.Parse([]byte("WEBVTT\n"))
+	assert.NotNil(t, err)
+
+	_, err = captions.Parse([]byte("1\n00:00:01 --> 00:00:02\nMissing milliseconds\n"))
+	assert.NotNil(t, err)
+
+	cues, err := captions.Parse([]byte("WEBVTT\n\n00:00:03.000 --> 00:00:02.000\nBackwards\n"))
+	assert.Nil(t, err)
+	assert.NotNil(t, captions.Validate(cues, 0, 0))
+}
+
+func TestCueTimingAgainstMaterialDuration(t *testing.T) {
+
+	cues, err := captions.Parse([]byte("WEBVTT\n\n00:00:08.000 --> 00:00:10.400\nLast words\n"))
+	assert.Nil(t, err)
+
+	assert.Nil(t, captions.Validate(cues, 10*time.Second, time.Second))
+	assert.NotNil(t, captions.Validate(cues, 9*time.Second, time.Second))
+	//Unknown duration skips the check
+	assert.Nil(t, captions.Validate(cues, 0, time.Second))
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+
+	language, err := captions.NormalizeLanguage("pt-BR")
+	assert.Nil(t, err)
+	assert.Equal(t, "pt-br", language)
+
+	_, err = captions.NormalizeLanguage("../en")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,284 @@
+package materials
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/models"
+	"acourse-course-service/pkg/services"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"testing"
+	"time"
+)
+
+//Course repository serving one course at version 3, writes are captured and conditional ones fail once the stored course differs
+type materialCourses struct {
+	contracts.CourseDatabaseRepository
+	storedVersion int
+	storedKey     string
+	pushConflict  bool
+	placements    [][]models.MaterialPlacement
+	pushed        *models.Material
+	updated       map[string]interface{}
+}
+
+func (r *materialCourses) FetchById(ctx context.Context, id string, excludeFields []string) (models.Course, error) {
+	return course(), nil
+}
+
+func (r *materialCourses) GenerateModelID() primitive.ObjectID {
+	return primitive.NewObjectID()
+}
+
+func (r *materialCourses) PlaceMaterials(ctx context.Context, course_id string, version int, placements []models.MaterialPlacement) (bool, error) {
+	if version != r.storedVersion {
+		return false, nil
+	}
+	r.storedVersion++
+	r.placements = append(r.placements, placements)
+	return true, nil
+}
+
+func (r *materialCourses) PushMaterial(ctx context.Context, course_id string, material models.Material) (bool, error) {
+	r.storedVersion++
+	r.pushed = &material
+	return true, nil
+}
+
+func (r *materialCourses) PushMaterialAt(ctx context.Context, course_id string, version int, material models.Material) (bool, error) {
+	if r.pushConflict || version != r.storedVersion {
+		return false, nil
+	}
+	return r.PushMaterial(ctx, course_id, material)
+}
+
+func (r *materialCourses) UpdateMaterial(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}, durationDelta time.Duration) (bool, error) {
+	if key, ok := match["key"]; ok && key != r.storedKey {
+		return false, nil
+	}
+	if material_id != basics.Hex() {
+		return false, nil
+	}
+	r.updated = fields
+	return true, nil
+}
+
+func (r *materialCourses) FetchRevisions(ctx context.Context, course_id string, withSnapshot bool) ([]models.CourseRevision, error) {
+	return nil, nil
+}
+
+//Storage capturing deleted objects
+type materialStorage struct {
+	contracts.StorageService
+	deleted []string
+}
+
+func (s *materialStorage) Delete(objectKey string) error {
+	s.deleted = append(s.deleted, objectKey)
+	return nil
+}
+
+func (s *materialStorage) DeletePrefix(prefix string) error {
+	s.deleted = append(s.deleted, prefix)
+	return nil
+}
+
+//Resumable uploads which are all completed, claims are given back through unclaim or release
+type materialUploads struct {
+	contracts.ResumableUploadService
+	unclaimed []string
+	released  []string
+}
+
+func (u *materialUploads) Resolve(ctx context.Context, upload_id string) (models.Upload, error) {
+	return models.Upload{
+		ID:        upload_id,
+		Completed: true,
+		Claimed:   true,
+		Filename:  "lesson.mp4",
+		Key:       "uploads/" + upload_id + "/lesson.mp4",
+		MediaInfo: &models.MediaInfo{Duration: time.Minute},
+	}, nil
+}
+
+func (u *materialUploads) Unclaim(upload_id string) error {
+	u.unclaimed = append(u.unclaimed, upload_id)
+	return nil
+}
+
+func (u *materialUploads) Release(upload_id string) error {
+	u.released = append(u.released, upload_id)
+	return nil
+}
+
+func materialService(repository *materialCourses, storage *materialStorage, uploads *materialUploads) contracts.CourseService {
+
+	accessPolicy, _ := services.ConstructYamlAccessPolicy("../../../policy.yaml")
+
+	var dbRepository contracts.CourseDatabaseRepository = repository
+	var auditRepository contracts.AuditRepository = discardedAudit{}
+	var storageService contracts.StorageService = storage
+	var mediaInfoService contracts.MediaInfoService
+	var uploadService contracts.ResumableUploadService = uploads
+	urlSigner := services.ConstructCdnUrlSigner("https://cdn.example.com")
+	var transcodingService contracts.TranscodingService
+
+	return services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService, &uploadService, &urlSigner, &transcodingService, models.MediaPolicy{}, &accessPolicy, &auditRepository)
+}
+
+func materialContext() context.Context {
+	return context.WithValue(context.Background(), middleware.AuthorizationKey, &middleware.Authorization{UserID: "1", Role: "instructor", Permission: "crud"})
+}
+
+func TestCreateMaterialAtOrder(t *testing.T) {
+
+	order := func(order int) *int {
+		return &order
+	}
+
+	tests := []struct {
+		name         string
+		sectionId    string
+		order        *int
+		changed      bool
+		pushConflict bool
+		statusCode   int
+		createdOrder int
+		moved        map[primitive.ObjectID]int
+		restored     map[primitive.ObjectID]int
+	}{
+		{name: "appended without order", statusCode: http.StatusCreated, createdOrder: 3},
+		{name: "appended to a section", sectionId: sectionId.Hex(), statusCode: http.StatusCreated, createdOrder: 2},
+		{name: "ordered past the end", sectionId: sectionId.Hex(), order: order(7), statusCode: http.StatusCreated, createdOrder: 2},
+		{name: "inserted into a section", sectionId: sectionId.Hex(), order: order(1), statusCode: http.StatusCreated, createdOrder: 1, moved: map[primitive.ObjectID]int{advanced: 2}},
+		{name: "inserted first", order: order(0), statusCode: http.StatusCreated, createdOrder: 0, moved: map[primitive.ObjectID]int{intro: 1, setup: 2, summary: 3}},
+		{name: "course changed meanwhile", order: order(0), changed: true, statusCode: http.StatusConflict},
+		{name: "course changed after making room", sectionId: sectionId.Hex(), order: order(0), pushConflict: true, statusCode: http.StatusConflict, moved: map[primitive.ObjectID]int{basics: 1, advanced: 2}, restored: map[primitive.ObjectID]int{basics: 0, advanced: 1}},
+	}
+
+	for _, test := range tests {
+
+		repository := &materialCourses{storedVersion: 3, pushConflict: test.pushConflict}
+		if test.changed {
+			repository.storedVersion++
+		}
+		courseService := materialService(repository, &materialStorage{}, &materialUploads{})
+
+		res, err := courseService.CreateMaterial(materialContext(), primitive.NewObjectID().Hex(), requests.CreateCourseMaterialRequest{
+			Name:        "Cheatsheet",
+			Description: "Commands at a glance",
+			Kind:        models.MaterialKindLink,
+			Url:         "https://example.com/cheatsheet",
+			SectionID:   test.sectionId,
+			Order:       test.order,
+		})
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.statusCode, res.StatusCode, test.name)
+
+		//Placements made room first & were put back when the material couldn't follow
+		placed := make([]map[primitive.ObjectID]int, 0)
+		for _, placements := range repository.placements {
+			orders := make(map[primitive.ObjectID]int)
+			for _, placement := range placements {
+				orders[placement.MaterialID] = placement.Order
+			}
+			placed = append(placed, orders)
+		}
+		expected := make([]map[primitive.ObjectID]int, 0)
+		for _, orders := range []map[primitive.ObjectID]int{test.moved, test.restored} {
+			if orders != nil {
+				expected = append(expected, orders)
+			}
+		}
+		assert.Equal(t, expected, placed, test.name)
+
+		if test.statusCode != http.StatusCreated {
+			assert.Nil(t, repository.pushed, test.name)
+			continue
+		}
+
+		assert.Equal(t, test.createdOrder, repository.pushed.Order, test.name)
+		if test.sectionId == "" {
+			assert.Nil(t, repository.pushed.SectionID, test.name)
+		} else {
+			assert.Equal(t, test.sectionId, repository.pushed.SectionID.Hex(), test.name)
+		}
+	}
+}
+
+func TestUpdateMaterial(t *testing.T) {
+
+	name := "Basics revisited"
+
+	tests := []struct {
+		name       string
+		materialId string
+		statusCode int
+	}{
+		{"updated", basics.Hex(), http.StatusOK},
+		{"unknown material", primitive.NewObjectID().Hex(), http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+
+		repository := &materialCourses{storedVersion: 3}
+		courseService := materialService(repository, &materialStorage{}, &materialUploads{})
+
+		res, err := courseService.UpdateMaterial(materialContext(), primitive.NewObjectID().Hex(), test.materialId, requests.UpdateMaterialRequest{Name: &name})
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.statusCode, res.StatusCode, test.name)
+
+		if test.statusCode != http.StatusOK {
+			assert.Nil(t, repository.updated, test.name)
+			continue
+		}
+
+		//Only fields of the material are set, the rest of the course isn't rewritten
+		assert.Equal(t, name, repository.updated["name"], test.name)
+		assert.Equal(t, "First steps", repository.updated["description"], test.name)
+		assert.NotContains(t, repository.updated, "key", test.name)
+		assert.NotContains(t, repository.updated, "order", test.name)
+	}
+}
+
+func TestReplaceMaterialFile(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		storedKey  string
+		statusCode int
+	}{
+		{"replaced", "course/basics.mp4", http.StatusOK},
+		{"replaced meanwhile", "course/other.mp4", http.StatusConflict},
+	}
+
+	for _, test := range tests {
+
+		repository := &materialCourses{storedVersion: 3, storedKey: test.storedKey}
+		storage := &materialStorage{}
+		uploads := &materialUploads{}
+		courseService := materialService(repository, storage, uploads)
+
+		uploadId := "0123456789abcdef0123456789abcdef"
+		res, err := courseService.ReplaceMaterialFile(materialContext(), primitive.NewObjectID().Hex(), basics.Hex(), requests.ReplaceMaterialFileRequest{UploadID: uploadId})
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.statusCode, res.StatusCode, test.name)
+
+		if test.statusCode != http.StatusOK {
+			//Claimed upload is given back & stays for another attempt
+			assert.Equal(t, []string{uploadId}, uploads.unclaimed, test.name)
+			assert.Empty(t, uploads.released, test.name)
+			assert.Empty(t, storage.deleted, test.name)
+			continue
+		}
+
+		assert.Equal(t, "uploads/"+uploadId+"/lesson.mp4", repository.updated["key"], test.name)
+		assert.Equal(t, time.Minute, repository.updated["duration"], test.name)
+		assert.Equal(t, []string{uploadId}, uploads.released, test.name)
+		assert.Empty(t, uploads.unclaimed, test.name)
+		assert.Contains(t, storage.deleted, "course/basics.mp4", test.name)
+	}
+}
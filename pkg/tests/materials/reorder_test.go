@@ -0,0 +1,219 @@
+package materials
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/models"
+	"acourse-course-service/pkg/services"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"testing"
+)
+
+var (
+	sectionId = primitive.NewObjectID()
+	intro     = primitive.NewObjectID()
+	setup     = primitive.NewObjectID()
+	basics    = primitive.NewObjectID()
+	advanced  = primitive.NewObjectID()
+	summary   = primitive.NewObjectID()
+)
+
+//Course repository serving one course & capturing placements, other methods aren't used by reordering.
+//Stored version is increased when the course is changed after it was read
+type reorderCourses struct {
+	contracts.CourseDatabaseRepository
+	storedVersion int
+	placements    []models.MaterialPlacement
+}
+
+func (r *reorderCourses) FetchById(ctx context.Context, id string, excludeFields []string) (models.Course, error) {
+	return course(), nil
+}
+
+func (r *reorderCourses) PlaceMaterials(ctx context.Context, course_id string, version int, placements []models.MaterialPlacement) (bool, error) {
+	if version != r.storedVersion {
+		return false, nil
+	}
+	r.placements = placements
+	return true, nil
+}
+
+type discardedAudit struct{}
+
+func (a discardedAudit) Record(ctx context.Context, event models.AuditEvent) error {
+	return nil
+}
+
+func (a discardedAudit) Find(ctx context.Context, filter models.AuditFilter, limit int64, skip int64) ([]models.AuditEvent, error) {
+	return nil, nil
+}
+
+//Intro, setup & summary are outside of sections, basics & advanced are in one section
+func course() models.Course {
+	return models.Course{
+		ID:       primitive.NewObjectID(),
+		CourseID: "course",
+		UserID:   1,
+		Version:  3,
+		Sections: []models.Section{{SectionID: sectionId, Name: "Getting started"}},
+		Materials: []models.Material{
+			{MaterialID: intro, Order: 0},
+			{MaterialID: setup, Order: 1},
+			{MaterialID: summary, Order: 2},
+			{MaterialID: basics, Name: "Basics", Description: "First steps", Key: "course/basics.mp4", Order: 0, SectionID: &sectionId},
+			{MaterialID: advanced, Order: 1, SectionID: &sectionId},
+		},
+	}
+}
+
+func hexes(ids ...primitive.ObjectID) []string {
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, id.Hex())
+	}
+	return result
+}
+
+func TestReorderRequestValidation(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		request requests.ReorderMaterialsRequest
+		valid   bool
+	}{
+		{"nothing given", requests.ReorderMaterialsRequest{}, false},
+		{"material ids", requests.ReorderMaterialsRequest{MaterialIDs: []string{"a", "b"}}, true},
+		{"material ids of a section", requests.ReorderMaterialsRequest{SectionID: "s", MaterialIDs: []string{"a"}}, true},
+		{"moves", requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: "a", After: "b"}}}, true},
+		{"both given", requests.ReorderMaterialsRequest{MaterialIDs: []string{"a"}, Moves: []requests.MaterialMoveRequest{{MaterialID: "a", After: "b"}}}, false},
+		{"moves with section", requests.ReorderMaterialsRequest{SectionID: "s", Moves: []requests.MaterialMoveRequest{{MaterialID: "a", After: "b"}}}, false},
+		{"move without anchor", requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: "a"}}}, false},
+		{"move before & after", requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: "a", Before: "b", After: "c"}}}, false},
+		{"move relative to itself", requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: "a", Before: "a"}}}, false},
+	}
+
+	for _, test := range tests {
+		err := test.request.Validate()
+		assert.Equal(t, test.valid, err == nil, test.name)
+	}
+}
+
+func TestReorderMaterials(t *testing.T) {
+
+	accessPolicy, err := services.ConstructYamlAccessPolicy("../../../policy.yaml")
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.AuthorizationKey, &middleware.Authorization{UserID: "1", Role: "instructor", Permission: "crud"})
+
+	tests := []struct {
+		name       string
+		request    requests.ReorderMaterialsRequest
+		changed    bool
+		statusCode int
+		order      map[string][]string
+	}{
+		{
+			name:       "permutation",
+			request:    requests.ReorderMaterialsRequest{MaterialIDs: hexes(summary, intro, setup)},
+			statusCode: http.StatusOK,
+			order:      map[string][]string{"": hexes(summary, intro, setup)},
+		},
+		{
+			name:       "permutation of a section",
+			request:    requests.ReorderMaterialsRequest{SectionID: sectionId.Hex(), MaterialIDs: hexes(advanced, basics)},
+			statusCode: http.StatusOK,
+			order:      map[string][]string{sectionId.Hex(): hexes(advanced, basics)},
+		},
+		{
+			name:       "repeated material",
+			request:    requests.ReorderMaterialsRequest{MaterialIDs: hexes(intro, intro, setup)},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "material of another section",
+			request:    requests.ReorderMaterialsRequest{MaterialIDs: hexes(intro, setup, basics)},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "incomplete order",
+			request:    requests.ReorderMaterialsRequest{MaterialIDs: hexes(intro, setup)},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown section",
+			request:    requests.ReorderMaterialsRequest{SectionID: primitive.NewObjectID().Hex(), MaterialIDs: hexes(basics)},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "move into a section",
+			request:    requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: setup.Hex(), After: basics.Hex()}}},
+			statusCode: http.StatusOK,
+			order:      map[string][]string{"": hexes(intro, summary), sectionId.Hex(): hexes(basics, setup, advanced)},
+		},
+		{
+			name:       "moves applied in order",
+			request:    requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: summary.Hex(), Before: intro.Hex()}, {MaterialID: intro.Hex(), After: setup.Hex()}}},
+			statusCode: http.StatusOK,
+			order:      map[string][]string{"": hexes(summary, setup, intro)},
+		},
+		{
+			name:       "material added meanwhile",
+			request:    requests.ReorderMaterialsRequest{MaterialIDs: hexes(summary, intro, setup)},
+			changed:    true,
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:       "move next to unknown material",
+			request:    requests.ReorderMaterialsRequest{Moves: []requests.MaterialMoveRequest{{MaterialID: intro.Hex(), Before: primitive.NewObjectID().Hex()}}},
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+
+		repository := &reorderCourses{storedVersion: 3}
+		if test.changed {
+			repository.storedVersion++
+		}
+		var dbRepository contracts.CourseDatabaseRepository = repository
+		var auditRepository contracts.AuditRepository = discardedAudit{}
+		var storageService contracts.StorageService
+		var mediaInfoService contracts.MediaInfoService
+		var uploadService contracts.ResumableUploadService
+		var urlSigner contracts.UrlSigner
+		var transcodingService contracts.TranscodingService
+
+		courseService := services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService, &uploadService, &urlSigner, &transcodingService, models.MediaPolicy{}, &accessPolicy, &auditRepository)
+
+		res, err := courseService.ReorderMaterials(ctx, primitive.NewObjectID().Hex(), test.request)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.statusCode, res.StatusCode, test.name)
+
+		if test.statusCode != http.StatusOK {
+			assert.Empty(t, repository.placements, test.name)
+			continue
+		}
+		assert.Equal(t, test.order, res.Data, test.name)
+
+		//Touched sections are renumbered densely from 0
+		for sectionId, materialIds := range test.order {
+			for order, materialId := range materialIds {
+				for _, placement := range repository.placements {
+					if placement.MaterialID.Hex() != materialId {
+						continue
+					}
+					assert.Equal(t, order, placement.Order, test.name)
+					if sectionId == "" {
+						assert.Nil(t, placement.SectionID, test.name)
+					} else {
+						assert.Equal(t, sectionId, placement.SectionID.Hex(), test.name)
+					}
+				}
+			}
+		}
+	}
+}
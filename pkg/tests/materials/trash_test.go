@@ -0,0 +1,86 @@
+package materials
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	"acourse-course-service/pkg/services"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"testing"
+)
+
+var (
+	trashedCourseId = primitive.NewObjectID()
+	trashed         = primitive.NewObjectID()
+)
+
+//Course repository serving one course with a trashed material of the section, unknown ids aren't found
+type trashCourses struct {
+	contracts.CourseDatabaseRepository
+	restored *models.Material
+}
+
+func (r *trashCourses) FetchByIdWithDeleted(ctx context.Context, id string) (*models.Course, error) {
+	if id != trashedCourseId.Hex() {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	course := course()
+	course.ID = trashedCourseId
+	course.TrashedMaterials = []models.Material{{MaterialID: trashed, Order: 0, SectionID: &sectionId}}
+	return &course, nil
+}
+
+func (r *trashCourses) RestoreMaterial(ctx context.Context, course_id string, material models.Material) (bool, error) {
+	r.restored = &material
+	return true, nil
+}
+
+func TestRestoreMaterial(t *testing.T) {
+
+	accessPolicy, err := services.ConstructYamlAccessPolicy("../../../policy.yaml")
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), middleware.AuthorizationKey, &middleware.Authorization{UserID: "1", Role: "instructor", Permission: "crud"})
+
+	tests := []struct {
+		name       string
+		courseId   string
+		statusCode int
+	}{
+		{"restored", trashedCourseId.Hex(), http.StatusOK},
+		{"unknown course", primitive.NewObjectID().Hex(), http.StatusNotFound},
+		{"malformed course id", "not-an-id", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+
+		repository := &trashCourses{}
+		var dbRepository contracts.CourseDatabaseRepository = repository
+		var auditRepository contracts.AuditRepository = discardedAudit{}
+		var storageService contracts.StorageService
+		var mediaInfoService contracts.MediaInfoService
+		var uploadService contracts.ResumableUploadService
+		urlSigner := services.ConstructCdnUrlSigner("https://cdn.example.com")
+		var transcodingService contracts.TranscodingService
+
+		courseService := services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService, &uploadService, &urlSigner, &transcodingService, models.MediaPolicy{}, &accessPolicy, &auditRepository)
+
+		res, err := courseService.RestoreMaterial(ctx, test.courseId, trashed.Hex())
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.statusCode, res.StatusCode, test.name)
+
+		if test.statusCode != http.StatusOK {
+			assert.Nil(t, repository.restored, test.name)
+			continue
+		}
+
+		//Basics & advanced keep their places, restored material follows them
+		assert.Equal(t, sectionId, *repository.restored.SectionID, test.name)
+		assert.Equal(t, 2, repository.restored.Order, test.name)
+	}
+}
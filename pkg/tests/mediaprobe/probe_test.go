@@ -0,0 +1,160 @@
+package mediaprobe
+
+import (
+	"acourse-course-service/pkg/mediaprobe"
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+	"time"
+)
+
+func box(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(body)+8))
+	copy(header[4:], boxType)
+	return append(header, body...)
+}
+
+func u32(values ...uint32) []byte {
+	out := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(out[i*4:], value)
+	}
+	return out
+}
+
+//Version 0 mvhd/mdhd: version+flags, creation, modification, timescale, duration
+func timescaleBox(boxType string, timescale uint32, duration uint32) []byte {
+	return box(boxType, u32(0, 0, 0, timescale, duration), make([]byte, 80))
+}
+
+func videoTrack(codec string, width uint16, height uint16, timescale uint32, duration uint32, samples uint32) []byte {
+
+	entry := make([]byte, 78)
+	binary.BigEndian.PutUint16(entry[24:], width)
+	binary.BigEndian.PutUint16(entry[26:], height)
+
+	return box("trak",
+		box("tkhd", make([]byte, 84)),
+		box("mdia",
+			timescaleBox("mdhd", timescale, duration),
+			box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12)),
+			box("minf", box("stbl",
+				box("stsd", u32(0, 1), box(codec, entry)),
+				box("stts", u32(0, 1, samples, 1)),
+			)),
+		),
+	)
+}
+
+func audioTrack(codec string) []byte {
+	return box("trak",
+		box("mdia",
+			timescaleBox("mdhd", 48000, 480000),
+			box("hdlr", u32(0, 0), []byte("soun"), make([]byte, 12)),
+			box("minf", box("stbl", box("stsd", u32(0, 1), box(codec, make([]byte, 28))))),
+		),
+	)
+}
+
+//EBML element with a 1 byte size, enough for small fixtures
+func element(id []byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	return append(append(append([]byte{}, id...), byte(0x80|len(body))), body...)
+}
+
+func TestProbeMp4MoovAfterMdat(t *testing.T) {
+
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(0x200), []byte("isomavc1")),
+		box("mdat", make([]byte, 4096)),
+		box("moov",
+			timescaleBox("mvhd", 1000, 10500),
+			videoTrack("avc1", 1280, 720, 12800, 128000, 250),
+			audioTrack("mp4a"),
+		),
+	}, nil)
+
+	info, err := mediaprobe.Probe(bytes.NewReader(file))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "mp4", info.Container)
+	assert.Equal(t, 10500*time.Millisecond, info.Duration)
+	assert.Equal(t, 1280, info.Width)
+	assert.Equal(t, 720, info.Height)
+	assert.Equal(t, 25.0, info.FrameRate)
+	assert.Equal(t, "h264", info.VideoCodec)
+	assert.Equal(t, "aac", info.AudioCodec)
+	assert.Equal(t, int64(len(file)), info.Size)
+	assert.True(t, info.Bitrate > 0)
+}
+
+func TestProbeQuickTime(t *testing.T) {
+
+	file := bytes.Join([][]byte{
+		box("ftyp", []byte("qt  "), u32(0)),
+		box("moov", timescaleBox("mvhd", 600, 1200), videoTrack("hvc1", 1920, 1080, 600, 1200, 60)),
+	}, nil)
+
+	info, err := mediaprobe.Probe(bytes.NewReader(file))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "mov", info.Container)
+	assert.Equal(t, 2*time.Second, info.Duration)
+	assert.Equal(t, "hevc", info.VideoCodec)
+	assert.Equal(t, 1080, info.Height)
+}
+
+func TestProbeWebm(t *testing.T) {
+
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(5000))
+
+	file := bytes.Join([][]byte{
+		element([]byte{0x1A, 0x45, 0xDF, 0xA3}, element([]byte{0x42, 0x82}, []byte("webm"))),
+		//Segment with unknown size, like live recorded webm
+		{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		element([]byte{0x15, 0x49, 0xA9, 0x66},
+			element([]byte{0x2A, 0xD7, 0xB1}, []byte{0x0F, 0x42, 0x40}),
+			element([]byte{0x44, 0x89}, duration),
+		),
+		element([]byte{0x16, 0x54, 0xAE, 0x6B},
+			element([]byte{0xAE},
+				element([]byte{0x83}, []byte{1}),
+				element([]byte{0x86}, []byte("V_VP9")),
+				element([]byte{0x23, 0xE3, 0x83}, []byte{0x01, 0xFC, 0xA0, 0x55}),
+				element([]byte{0xE0},
+					element([]byte{0xB0}, []byte{0x02, 0x80}),
+					element([]byte{0xBA}, []byte{0x01, 0x68}),
+				),
+			),
+			element([]byte{0xAE},
+				element([]byte{0x83}, []byte{2}),
+				element([]byte{0x86}, []byte("A_OPUS")),
+			),
+		),
+		{0x1F, 0x43, 0xB6, 0x75, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+		make([]byte, 1024),
+	}, nil)
+
+	info, err := mediaprobe.Probe(bytes.NewReader(file))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "webm", info.Container)
+	assert.Equal(t, 5*time.Second, info.Duration)
+	assert.Equal(t, 640, info.Width)
+	assert.Equal(t, 360, info.Height)
+	assert.InDelta(t, 30.0, info.FrameRate, 0.01)
+	assert.Equal(t, "vp9", info.VideoCodec)
+	assert.Equal(t, "opus", info.AudioCodec)
+}
+
+func TestProbeUnsupported(t *testing.T) {
+
+	_, err := mediaprobe.Probe(bytes.NewReader([]byte("%PDF-1.7 not a video")))
+
+	assert.Equal(t, mediaprobe.ErrUnsupportedFormat, err)
+}
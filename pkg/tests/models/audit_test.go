@@ -0,0 +1,45 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestDiffCourseComparesMaterialsById(t *testing.T) {
+
+	timeNow := time.Now()
+	kept := models.Material{MaterialID: primitive.NewObjectID(), Name: "Intro", Order: 1}
+	removed := models.Material{MaterialID: primitive.NewObjectID(), Name: "Outro", Order: 2}
+
+	renamed := kept
+	renamed.Name = "Welcome"
+
+	before := models.Course{Name: "Go", Materials: []models.Material{kept, removed}}
+	after := models.Course{Name: "Go Basics", Materials: []models.Material{renamed}, UpdatedAt: &timeNow}
+
+	changes := models.DiffCourse(&before, &after)
+
+	fields := make(map[string]models.FieldChange)
+	for _, change := range changes {
+		fields[change.Field] = change
+	}
+
+	assert.Equal(t, "Go", fields["name"].From)
+	assert.Equal(t, "Go Basics", fields["name"].To)
+	assert.Equal(t, "Welcome", fields["materials."+kept.MaterialID.Hex()+".name"].To)
+	assert.Equal(t, "Outro", fields["materials."+removed.MaterialID.Hex()+".name"].From)
+	assert.Nil(t, fields["materials."+removed.MaterialID.Hex()+".name"].To)
+	assert.NotContains(t, fields, "updated_at")
+	assert.NotContains(t, fields, "materials."+kept.MaterialID.Hex()+".order")
+}
+
+func TestDiffFieldsOfUnchangedValue(t *testing.T) {
+
+	material := models.Material{MaterialID: primitive.NewObjectID(), Name: "Intro"}
+
+	assert.Empty(t, models.DiffFields(material, &material))
+	assert.NotEmpty(t, models.DiffFields(nil, &material, "material_id"))
+}
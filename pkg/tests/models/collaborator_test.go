@@ -0,0 +1,41 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCourseRoles(t *testing.T) {
+
+	course := models.Course{
+		UserID:        1,
+		Collaborators: []models.Collaborator{{UserID: 2, Role: models.CourseRoleEditor}, {UserID: 3, Role: models.CourseRoleViewer}},
+	}
+
+	assert.Equal(t, models.CourseRoleOwner, course.CourseRole(1))
+	assert.Equal(t, models.CourseRoleEditor, course.CourseRole(2))
+	assert.Equal(t, models.CourseRoleViewer, course.CourseRole(3))
+	assert.Equal(t, "", course.CourseRole(4))
+
+	assert.True(t, models.CourseRoleIncludes(models.CourseRoleOwner, models.CourseRoleEditor))
+	assert.False(t, models.CourseRoleIncludes(models.CourseRoleViewer, models.CourseRoleEditor))
+	assert.False(t, models.CourseRoleIncludes("", models.CourseRoleViewer))
+}
+
+func TestTransferOwnershipKeepsPreviousOwnerAsEditor(t *testing.T) {
+
+	course := models.Course{
+		UserID:        1,
+		Collaborators: []models.Collaborator{{UserID: 2, Role: models.CourseRoleEditor}, {UserID: 3, Role: models.CourseRoleViewer}},
+	}
+
+	course.TransferOwnership(2, "1", time.Now())
+
+	assert.Equal(t, int64(2), course.UserID)
+	assert.Equal(t, models.CourseRoleOwner, course.CourseRole(2))
+	assert.Equal(t, models.CourseRoleEditor, course.CourseRole(1))
+	assert.Equal(t, models.CourseRoleViewer, course.CourseRole(3))
+	assert.Len(t, course.Collaborators, 2)
+}
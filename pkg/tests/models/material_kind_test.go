@@ -0,0 +1,70 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+var quiz = models.Quiz{
+	PassingScore: 70,
+	Questions: []models.QuizQuestion{
+		{Question: "2 + 2", Options: []string{"3", "4"}, Answers: []int{1}, Explanation: "Basic math"},
+		{Question: "Primes", Options: []string{"2", "4", "5"}, Answers: []int{0, 2}},
+	},
+}
+
+func TestLegacyMaterialIsVideo(t *testing.T) {
+
+	material := models.Material{}
+
+	assert.Equal(t, models.MaterialKindVideo, material.MaterialKind())
+	assert.False(t, material.IsPlayable())
+}
+
+func TestMaterialsWithoutFileArePlayable(t *testing.T) {
+
+	link := models.Material{Kind: models.MaterialKindLink, LinkUrl: "https://example.com"}
+	document := models.Material{Kind: models.MaterialKindDocument}
+
+	assert.True(t, link.IsPlayable())
+	assert.False(t, document.IsPlayable())
+}
+
+func TestEstimatedDuration(t *testing.T) {
+
+	article := models.Material{Kind: models.MaterialKindArticle, Content: strings.Repeat("word ", 400)}
+	quizMaterial := models.Material{Kind: models.MaterialKindQuiz, Quiz: &quiz}
+
+	assert.Equal(t, 2*time.Minute, article.EstimatedDuration())
+	assert.Equal(t, 2*time.Minute, quizMaterial.EstimatedDuration())
+}
+
+func TestQuizValidation(t *testing.T) {
+
+	assert.Nil(t, quiz.Validate())
+
+	invalid := models.Quiz{Questions: []models.QuizQuestion{{Question: "Pick", Options: []string{"a", "b"}, Answers: []int{2}}}}
+	assert.NotNil(t, invalid.Validate())
+	assert.NotNil(t, models.Quiz{}.Validate())
+}
+
+func TestQuizWithoutAnswers(t *testing.T) {
+
+	hidden := quiz.WithoutAnswers()
+
+	assert.Len(t, hidden.Questions, 2)
+	assert.Nil(t, hidden.Questions[0].Answers)
+	assert.Empty(t, hidden.Questions[0].Explanation)
+	assert.Equal(t, []int{1}, quiz.Questions[0].Answers)
+}
+
+func TestValidateDocument(t *testing.T) {
+
+	assert.Nil(t, models.ValidateDocument("slides.PDF", []byte("%PDF-1.7")))
+	assert.Nil(t, models.ValidateDocument("notes.docx", nil))
+	assert.NotNil(t, models.ValidateDocument("fake.pdf", []byte("<html>")))
+	assert.NotNil(t, models.ValidateDocument("script.exe", nil))
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var hdVideo = models.MediaInfo{
+	Container:  "mp4",
+	Duration:   90 * time.Second,
+	Width:      1920,
+	Height:     1080,
+	FrameRate:  30,
+	VideoCodec: "h264",
+	AudioCodec: "aac",
+}
+
+func TestEmptyMediaPolicyAllowsEverything(t *testing.T) {
+
+	policy := models.MediaPolicy{}
+
+	assert.Nil(t, policy.Validate(&hdVideo))
+	assert.Nil(t, policy.Validate(nil))
+}
+
+func TestMediaPolicyRejectsLowResolution(t *testing.T) {
+
+	policy := models.MediaPolicy{MinWidth: 1280, MinHeight: 720}
+
+	lowResolution := hdVideo
+	lowResolution.Width, lowResolution.Height = 640, 360
+
+	var policyError *models.MediaPolicyError
+
+	assert.Nil(t, policy.Validate(&hdVideo))
+	assert.True(t, errors.As(policy.Validate(&lowResolution), &policyError))
+	assert.NotNil(t, policy.Validate(nil))
+}
+
+func TestMediaPolicyRejectsCodecs(t *testing.T) {
+
+	policy := models.MediaPolicy{
+		AllowedContainers:  []string{"mp4", "webm"},
+		AllowedVideoCodecs: []string{"H264", "vp9"},
+		AllowedAudioCodecs: []string{"aac", "opus"},
+	}
+
+	hevc := hdVideo
+	hevc.VideoCodec = "hevc"
+
+	mp3 := hdVideo
+	mp3.AudioCodec = "mp3"
+
+	silent := hdVideo
+	silent.AudioCodec = ""
+
+	assert.Nil(t, policy.Validate(&hdVideo))
+	assert.Nil(t, policy.Validate(&silent))
+	assert.NotNil(t, policy.Validate(&hevc))
+	assert.NotNil(t, policy.Validate(&mp3))
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestStorageReferenceMatching(t *testing.T) {
+
+	references := models.NewStorageReferences()
+	references.Add(&models.Course{
+		ImageKey: "course-1/cover.jpg",
+		Materials: []models.Material{{
+			Key:         "course-1/intro.mp4",
+			PlaylistKey: "course-1/hls/intro/master.m3u8",
+			PosterKey:   "course-1/previews/intro/poster.jpg",
+			Captions:    []models.Caption{{Key: "course-1/captions/intro.en.vtt"}},
+		}},
+		TrashedMaterials: []models.Material{{Key: "course-1/trashed.mp4"}},
+	})
+
+	tests := []struct {
+		key        string
+		referenced bool
+	}{
+		{"course-1/cover.jpg", true},
+		{"course-1/intro.mp4", true},
+		{"course-1/trashed.mp4", true},
+		{"course-1/captions/intro.en.vtt", true},
+		{"course-1/hls/intro/720p/segment-001.ts", true},
+		{"course-1/previews/intro/sprite.jpg", true},
+		{"course-1/intro.mp4.bak", false},
+		{"course-1/hls/intro-old/master.m3u8", false},
+		{"course-1/captions/intro.fr.vtt", false},
+		{"course-2/intro.mp4", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.referenced, references.IsReferenced(test.key), test.key)
+	}
+
+	prefixes := []struct {
+		prefix     string
+		referenced bool
+	}{
+		{"course-1/", true},
+		{"course-1/hls/intro/", true},
+		{"course-1/hls/intro/720p/", true},
+		{"course-1/hls/other/", false},
+		{"course-2/", false},
+	}
+
+	for _, test := range prefixes {
+		assert.Equal(t, test.referenced, references.IsPrefixReferenced(test.prefix), test.prefix)
+	}
+}
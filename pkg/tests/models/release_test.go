@@ -0,0 +1,44 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDueReleasesFollowSchedule(t *testing.T) {
+
+	now := time.Now()
+	publishAt := now.Add(-2 * time.Hour)
+	unpublishAt := now.Add(-time.Hour)
+
+	course := models.Course{PublishAt: &publishAt, UnpublishAt: &unpublishAt}
+
+	events := course.DueReleases(now)
+	assert.Len(t, events, 2)
+	assert.Equal(t, models.ReleaseActionPublish, events[0].Action)
+	assert.Equal(t, models.ReleaseActionUnpublish, events[1].Action)
+
+	later := now.Add(time.Hour)
+	course.UnpublishAt = &later
+	assert.Len(t, course.DueReleases(now), 1)
+}
+
+func TestManualReleaseDropsPendingSchedule(t *testing.T) {
+
+	now := time.Now()
+	publishAt := now.Add(time.Hour)
+	course := models.Course{PublishAt: &publishAt}
+
+	course.SetReleased(true, "7", now)
+
+	assert.True(t, course.IsReleased)
+	assert.Nil(t, course.PublishAt)
+	assert.Equal(t, &now, course.ReleasedAt)
+	assert.Len(t, course.ReleaseHistory, 1)
+	assert.Equal(t, models.ReleaseSourceManual, course.ReleaseHistory[0].Source)
+
+	course.SetReleased(true, "7", now)
+	assert.Len(t, course.ReleaseHistory, 1)
+}
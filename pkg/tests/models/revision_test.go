@@ -0,0 +1,80 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestApplyRevisionServesPublishedContent(t *testing.T) {
+
+	intro := primitive.NewObjectID()
+	added := primitive.NewObjectID()
+
+	published := models.Course{
+		Name:          "Go Basics",
+		TotalDuration: time.Minute,
+		Materials: []models.Material{
+			{MaterialID: intro, Name: "Intro", Key: "go/intro.mp4", Status: models.MaterialStatusProcessing, Duration: time.Minute},
+		},
+	}
+	snapshot := published.Snapshot()
+
+	draft := models.Course{
+		Name:              "Go Basics, Second Edition",
+		PublishedRevision: 1,
+		Materials: []models.Material{
+			{MaterialID: intro, Name: "Introduction", Key: "go/intro.mp4", Status: models.MaterialStatusReady, PlaylistKey: "go/hls/intro/master.m3u8", Duration: 2 * time.Minute},
+			{MaterialID: added, Name: "Channels", Key: "go/channels.mp4"},
+		},
+		TrashedMaterials: []models.Material{{MaterialID: primitive.NewObjectID()}},
+	}
+
+	draft.ApplyRevision(snapshot)
+
+	assert.Equal(t, "Go Basics", draft.Name)
+	assert.Len(t, draft.Materials, 1)
+	assert.Equal(t, "Intro", draft.Materials[0].Name)
+	assert.Equal(t, models.MaterialStatusReady, draft.Materials[0].Status)
+	assert.Equal(t, "go/hls/intro/master.m3u8", draft.Materials[0].PlaylistKey)
+	assert.Equal(t, 2*time.Minute, draft.TotalDuration)
+	assert.Equal(t, 1, draft.PublishedRevision)
+	assert.Len(t, draft.TrashedMaterials, 1)
+	assert.Nil(t, snapshot.TrashedMaterials)
+}
+
+func TestApplyRevisionKeepsPublishedFile(t *testing.T) {
+
+	intro := primitive.NewObjectID()
+
+	published := models.Course{
+		Materials: []models.Material{{MaterialID: intro, Key: "go/intro.mp4", Status: models.MaterialStatusReady}},
+	}
+	snapshot := published.Snapshot()
+
+	draft := models.Course{
+		Materials: []models.Material{{MaterialID: intro, Key: "go/intro-v2.mp4", Status: models.MaterialStatusPending}},
+	}
+
+	draft.ApplyRevision(snapshot)
+
+	assert.Equal(t, "go/intro.mp4", draft.Materials[0].Key)
+	assert.Equal(t, models.MaterialStatusReady, draft.Materials[0].Status)
+}
+
+func TestStorageReferences(t *testing.T) {
+
+	references := models.NewStorageReferences()
+	references.Add(&models.Course{
+		ImageKey:  "go/cover.jpg",
+		Materials: []models.Material{{Key: "go/intro.mp4", PlaylistKey: "go/hls/intro/1/master.m3u8"}},
+	})
+
+	assert.True(t, references.IsReferenced("go/intro.mp4"))
+	assert.True(t, references.IsReferenced("go/hls/intro/1/720p.m3u8"))
+	assert.False(t, references.IsReferenced("go/old.mp4"))
+	assert.True(t, references.IsPrefixReferenced("go/hls/intro/1/"))
+	assert.False(t, references.IsPrefixReferenced("go/hls/intro/0/"))
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+	"time"
+)
+
+func TestArrangeMaterialsNestsSections(t *testing.T) {
+
+	basics := models.Section{SectionID: primitive.NewObjectID(), Name: "Basics", Order: 0}
+	advanced := models.Section{SectionID: primitive.NewObjectID(), Name: "Advanced", Order: 1}
+	missing := primitive.NewObjectID()
+
+	course := models.Course{
+		Sections: []models.Section{advanced, basics},
+		Materials: []models.Material{
+			{Name: "Channels", Order: 0, SectionID: &advanced.SectionID, Duration: 3 * time.Minute},
+			{Name: "Variables", Order: 1, SectionID: &basics.SectionID, Duration: time.Minute},
+			{Name: "Welcome", Order: 5},
+			{Name: "Orphan", Order: 0, SectionID: &missing},
+			{Name: "Setup", Order: 0, SectionID: &basics.SectionID, Duration: 2 * time.Minute},
+		},
+	}
+
+	course.ArrangeMaterials()
+
+	var names []string
+	for _, material := range course.Materials {
+		names = append(names, material.Name)
+	}
+
+	assert.Equal(t, []string{"Orphan", "Welcome", "Setup", "Variables", "Channels"}, names)
+	assert.Equal(t, "Basics", course.Sections[0].Name)
+	assert.Len(t, course.Sections[0].Materials, 2)
+	assert.Equal(t, 3*time.Minute, course.Sections[0].Duration)
+	assert.Equal(t, "Channels", course.Sections[1].Materials[0].Name)
+	assert.Len(t, course.SectionMaterials(""), 2)
+}
+
+func TestArrangeMaterialsIsStable(t *testing.T) {
+
+	first := primitive.NewObjectID()
+	second := primitive.NewObjectID()
+
+	course := models.Course{
+		Materials: []models.Material{
+			{MaterialID: second, Name: "Second", Order: 1},
+			{MaterialID: first, Name: "First", Order: 1},
+		},
+	}
+
+	course.ArrangeMaterials()
+
+	assert.Equal(t, "First", course.Materials[0].Name)
+	assert.Equal(t, "Second", course.Materials[1].Name)
+}
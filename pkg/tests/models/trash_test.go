@@ -0,0 +1,32 @@
+package models
+
+import (
+	"acourse-course-service/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestTrashedMaterialsKeepTheirObjects(t *testing.T) {
+
+	course := models.Course{
+		ImageKey:         "course/image.png",
+		Materials:        []models.Material{{Key: "course/intro.mp4"}},
+		TrashedMaterials: []models.Material{{Key: "course/old.mp4", PlaylistKey: "course/hls/old/master.m3u8"}},
+	}
+
+	assert.ElementsMatch(t, []string{"course/image.png", "course/intro.mp4", "course/old.mp4"}, course.StorageKeys())
+	assert.Equal(t, []string{"course/hls/old/"}, course.StoragePrefixes())
+}
+
+func TestIsPastRetention(t *testing.T) {
+
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+	old := cutoff.Add(-time.Hour)
+	recent := cutoff.Add(time.Hour)
+
+	assert.True(t, models.IsPastRetention(&old, cutoff))
+	assert.True(t, models.IsPastRetention(&cutoff, cutoff))
+	assert.False(t, models.IsPastRetention(&recent, cutoff))
+	assert.False(t, models.IsPastRetention(nil, cutoff))
+}
@@ -0,0 +1,43 @@
+package requests
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFinalizeUploadsRejectsDuplicates(t *testing.T) {
+
+	order := 1
+
+	tests := []struct {
+		name    string
+		uploads []requests.FinalizeMaterialUploadRequest
+		valid   bool
+	}{
+		{"distinct uploads", []requests.FinalizeMaterialUploadRequest{
+			{MaterialID: "a", Key: "course/1.mp4", UploadID: "1"},
+			{Name: "Intro", Order: &order, Key: "course/2.mp4", UploadID: "2"},
+		}, true},
+		{"new material without order", []requests.FinalizeMaterialUploadRequest{
+			{Name: "Intro", Key: "course/1.mp4", UploadID: "1"},
+		}, false},
+		{"same material twice", []requests.FinalizeMaterialUploadRequest{
+			{MaterialID: "a", Key: "course/1.mp4", UploadID: "1"},
+			{MaterialID: "a", Key: "course/2.mp4", UploadID: "2"},
+		}, false},
+		{"same key twice", []requests.FinalizeMaterialUploadRequest{
+			{MaterialID: "a", Key: "course/1.mp4", UploadID: "1"},
+			{MaterialID: "b", Key: "course/1.mp4", UploadID: "2"},
+		}, false},
+		{"same upload id twice", []requests.FinalizeMaterialUploadRequest{
+			{MaterialID: "a", Key: "course/1.mp4", UploadID: "1"},
+			{MaterialID: "b", Key: "course/2.mp4", UploadID: "1"},
+		}, false},
+	}
+
+	for _, test := range tests {
+		err := requests.FinalizeMaterialUploadsRequest{Uploads: test.uploads}.ValidateMaterials()
+		assert.Equal(t, test.valid, err == nil, test.name)
+	}
+}
@@ -0,0 +1,28 @@
+package requests
+
+import (
+	"acourse-course-service/pkg/http/requests"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExpectVersionFromIfMatch(t *testing.T) {
+
+	version := 2
+	request := requests.UpdateCourseRequest{Version: &version}
+
+	assert.NoError(t, request.ExpectVersion(`"7"`))
+	assert.Equal(t, 7, *request.Version)
+
+	assert.NoError(t, request.ExpectVersion(`W/"8"`))
+	assert.Equal(t, 8, *request.Version)
+
+	assert.NoError(t, request.ExpectVersion(""))
+	assert.Equal(t, 8, *request.Version)
+
+	assert.NoError(t, request.ExpectVersion("*"))
+	assert.Nil(t, request.Version)
+
+	assert.Error(t, request.ExpectVersion("8"))
+	assert.Error(t, request.ExpectVersion(`"abc"`))
+}
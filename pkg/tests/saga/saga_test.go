@@ -0,0 +1,79 @@
+package saga
+
+import (
+	"acourse-course-service/pkg/contracts"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	"acourse-course-service/pkg/services"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSagaCompensatesInReverseOrder(t *testing.T) {
+
+	rollback := &services.Saga{}
+
+	var order []string
+	step := func(name string, err error) func() error {
+		return func() error {
+			order = append(order, name)
+			return err
+		}
+	}
+
+	rollback.Record("upload image", step("upload image", nil))
+	rollback.Record("upload video", step("upload video", errors.New("bucket unavailable")))
+	rollback.Record("create course", step("create course", nil))
+
+	compensated, failed := rollback.Compensate()
+
+	assert.Equal(t, []string{"create course", "upload video", "upload image"}, order)
+	assert.Equal(t, []string{"create course", "upload image"}, compensated)
+	assert.Equal(t, []string{"upload video: bucket unavailable"}, failed)
+
+	//Compensations run only once
+	compensated, failed = rollback.Compensate()
+	assert.Empty(t, compensated)
+	assert.Empty(t, failed)
+	assert.Len(t, order, 3)
+}
+
+func TestSagaRecordsConcurrentSideEffects(t *testing.T) {
+
+	rollback := &services.Saga{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rollback.Record("upload", func() error { return nil })
+		}()
+	}
+	wg.Wait()
+
+	compensated, failed := rollback.Compensate()
+	assert.Len(t, compensated, 20)
+	assert.Empty(t, failed)
+}
+
+func TestSagaRemovesRecordedUploads(t *testing.T) {
+
+	root := t.TempDir()
+	var storageRepository contracts.StorageRepository = storagerepo.ConstructLocalStorageRepository(root, "http://localhost/storage", "secret")
+	storageService := services.ConstructStorageService(&storageRepository)
+
+	uploaded, err := storageService.PutObject(strings.NewReader("content"), "course/intro.mp4", "video/mp4")
+	assert.NoError(t, err)
+
+	rollback := &services.Saga{}
+	rollback.RecordUpload(storageService, uploaded.Key)
+
+	compensated, failed := rollback.Compensate()
+	assert.Equal(t, []string{"upload course/intro.mp4"}, compensated)
+	assert.Empty(t, failed)
+	assert.NoFileExists(t, filepath.Join(root, "course", "intro.mp4"))
+}
@@ -4,9 +4,12 @@ import (
 	"acourse-course-service/pkg/contracts"
 	"acourse-course-service/pkg/database"
 	"acourse-course-service/pkg/http/controllers"
+	"acourse-course-service/pkg/http/middleware"
 	"acourse-course-service/pkg/http/requests"
+	"acourse-course-service/pkg/models"
 	repositories "acourse-course-service/pkg/repositories/database"
 	s3repo "acourse-course-service/pkg/repositories/storage"
+	uploadrepo "acourse-course-service/pkg/repositories/upload"
 	"acourse-course-service/pkg/services"
 	"bytes"
 	"context"
@@ -29,6 +32,12 @@ var (
 	s3StorageRepository contracts.StorageRepository
 	storageService      contracts.StorageService
 	mediaInfoService    contracts.MediaInfoService
+	uploadRepository    contracts.ResumableUploadRepository
+	uploadService       contracts.ResumableUploadService
+	urlSigner           contracts.UrlSigner
+	transcodingService  contracts.TranscodingService
+	accessPolicy        contracts.AccessPolicy
+	auditRepository     contracts.AuditRepository
 	courseService       contracts.CourseService
 	ctx                 context.Context
 	engine              *gin.Engine
@@ -58,7 +67,7 @@ func init() {
 	mongodb := db.Prepare()
 
 	//Setup MongoDB Repository
-	dbRepository = repositories.ConstructDBRepository(mongodb.GetConnection(), mongodb.GetCollection())
+	dbRepository = repositories.ConstructDBRepository(mongodb.GetConnection(), mongodb.GetCollection(), mongodb.GetRevisionCollection())
 
 	//Setup S3 Storage Repository
 	s3StorageRepository = s3repo.ConstructS3Repository(
@@ -66,8 +75,9 @@ func init() {
 		os.Getenv("AWS_SECRET_ACCESS_KEY"),
 		os.Getenv("AWS_BUCKET_NAME"),
 		os.Getenv("AWS_BUCKET_REGION"),
-		[]string{"video/mp4", "video/x-matroska", "image/jpeg"},
 		int64(100*1024*1024),
+		int64(8*1024*1024),
+		4,
 		3,
 	)
 
@@ -77,11 +87,27 @@ func init() {
 	//Setup MediaInfo Service
 	mediaInfoService = services.ConstructMediaInfoService(mediainfo.NewMediaInfo())
 
+	//Setup Resumable Upload Service
+	uploadRepository = uploadrepo.ConstructDiskUploadRepository(os.TempDir())
+	uploadService = services.ConstructResumableUploadService(&uploadRepository, &storageService, &mediaInfoService, models.MediaPolicy{}, int64(100*1024*1024))
+
+	//Setup Url Signer
+	urlSigner = services.ConstructCdnUrlSigner(os.Getenv("CDN_BASE_URL"))
+
+	//Setup Audit Repository
+	auditRepository = repositories.ConstructAuditRepository(mongodb.GetAuditCollection())
+
+	//Setup Access Policy
+	accessPolicy, err = services.ConstructYamlAccessPolicy("../../../policy.yaml")
+	if err != nil {
+		panic(err)
+	}
+
 	//Setup Course Services
-	courseService = services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService)
+	courseService = services.ConstructCourseService(&dbRepository, &storageService, &mediaInfoService, &uploadService, &urlSigner, &transcodingService, models.MediaPolicy{}, &accessPolicy, &auditRepository)
 
 	//Setup Course Devlivery/Http Controller
-	controllers.SetupCourseHandler(ctx, engine, courseService)
+	controllers.SetupCourseHandler(ctx, engine, courseService, []middleware.Authenticator{middleware.TrustedGatewayAuthenticator{}}, accessPolicy)
 
 }
 
@@ -110,7 +136,9 @@ func TestMaterialFilesNotMatch(t *testing.T) {
 	createCourseRequest.Materials = append(createCourseRequest.Materials, material1)
 	createCourseRequest.Materials = append(createCourseRequest.Materials, material2)
 
-	_, err := courseService.Create(ctx, createCourseRequest)
+	authContext := context.WithValue(ctx, middleware.AuthorizationKey, &middleware.Authorization{UserID: "100", Role: "instructor", Permission: "crud"})
+
+	_, err := courseService.Create(authContext, createCourseRequest)
 	if err != nil {
 		t.Logf("Jumlah File dan Material data tidak sama: %v", err.Error())
 	}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"acourse-course-service/pkg/http/response"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	"acourse-course-service/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalObjectKeysStayInsideRoot(t *testing.T) {
+
+	base := t.TempDir()
+	storage := storagerepo.ConstructLocalStorageRepository(filepath.Join(base, "root"), "http://localhost/storage", "secret")
+
+	tests := []struct {
+		key      string
+		valid    bool
+		resolved string
+	}{
+		{"course/video.mp4", true, "course/video.mp4"},
+		{"../escape.txt", true, "escape.txt"},
+		{"course/../../escape.txt", true, "escape.txt"},
+		{"/absolute.txt", true, "absolute.txt"},
+		{"", false, ""},
+		{".", false, ""},
+		{"course/..", false, ""},
+	}
+
+	for _, test := range tests {
+		_, err := storage.PutObject(strings.NewReader("content"), test.key, "text/plain")
+		if !test.valid {
+			assert.Error(t, err, test.key)
+			continue
+		}
+
+		assert.NoError(t, err, test.key)
+		assert.FileExists(t, filepath.Join(base, "root", filepath.FromSlash(test.resolved)), test.key)
+	}
+
+	assert.NoFileExists(t, filepath.Join(base, "escape.txt"))
+}
+
+func TestLocalPresignedUploadSignatures(t *testing.T) {
+
+	storage := storagerepo.ConstructLocalStorageRepository(filepath.Join(t.TempDir(), "root"), "http://localhost/storage", "secret")
+
+	upload, err := storage.CreatePresignedUpload("video.mp4", "course/", "video/mp4", 10)
+	assert.NoError(t, err)
+	assert.Len(t, upload.Parts, 1)
+
+	partUrl, err := url.Parse(upload.Parts[0].Url)
+	assert.NoError(t, err)
+	query := partUrl.Query()
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	sign := query.Get("signature")
+
+	past := time.Now().Add(-time.Minute).Unix()
+	pastSign := signature.Sign("secret", http.MethodPut, upload.Key, upload.UploadID, "1", strconv.FormatInt(past, 10))
+
+	tests := []struct {
+		name       string
+		key        string
+		uploadId   string
+		partNumber int64
+		expires    int64
+		sign       string
+		valid      bool
+	}{
+		{"signed part", upload.Key, upload.UploadID, 1, expires, sign, true},
+		{"other part", upload.Key, upload.UploadID, 2, expires, sign, false},
+		{"other key", "course/other.mp4", upload.UploadID, 1, expires, sign, false},
+		{"other upload", upload.Key, strings.Repeat("0", 32), 1, expires, sign, false},
+		{"extended expiry", upload.Key, upload.UploadID, 1, expires + 60, sign, false},
+		{"expired", upload.Key, upload.UploadID, 1, past, pastSign, false},
+		{"unsigned", upload.Key, upload.UploadID, 1, expires, "", false},
+	}
+
+	for _, test := range tests {
+		err := storage.VerifyUploadPart(test.key, test.uploadId, test.partNumber, test.expires, test.sign)
+		assert.Equal(t, test.valid, err == nil, test.name)
+	}
+
+	//Parts are only combined when their ETags match
+	etag, err := storage.WriteUploadPart(upload.UploadID, 1, strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+
+	_, err = storage.CompletePresignedUpload(upload.Key, upload.UploadID, []response.UploadedPart{{PartNumber: 1, ETag: `"0"`}})
+	assert.Error(t, err)
+
+	_, err = storage.CompletePresignedUpload("course/other.mp4", upload.UploadID, []response.UploadedPart{{PartNumber: 1, ETag: etag}})
+	assert.Error(t, err)
+
+	_, err = storage.CompletePresignedUpload(upload.Key, upload.UploadID, []response.UploadedPart{{PartNumber: 1, ETag: etag}})
+	assert.NoError(t, err)
+
+	object, err := storage.GetObject(upload.Key)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(object)
+	_ = object.Close()
+	assert.Equal(t, "0123456789", string(content))
+
+	_, err = storage.WriteUploadPart("../"+upload.UploadID, 1, strings.NewReader("0"))
+	assert.Error(t, err)
+}
+
+func TestLocalDownloadSignatures(t *testing.T) {
+
+	storage := storagerepo.ConstructLocalStorageRepository(t.TempDir(), "http://localhost/storage", "secret")
+	unsigned := storagerepo.ConstructLocalStorageRepository(t.TempDir(), "http://localhost/storage", "")
+
+	expires := time.Now().Add(time.Minute).Unix()
+	past := time.Now().Add(-time.Minute).Unix()
+	sign := func(key string, expires int64) string {
+		return signature.Sign("secret", http.MethodGet, key, strconv.FormatInt(expires, 10))
+	}
+
+	assert.NoError(t, storage.VerifyDownload("course/video.mp4", expires, sign("course/video.mp4", expires)))
+	assert.Error(t, storage.VerifyDownload("course/other.mp4", expires, sign("course/video.mp4", expires)))
+	assert.Error(t, storage.VerifyDownload("course/video.mp4", past, sign("course/video.mp4", past)))
+	assert.Error(t, storage.VerifyDownload("course/video.mp4", expires, signature.Sign("other", http.MethodGet, "course/video.mp4", strconv.FormatInt(expires, 10))))
+	assert.Error(t, unsigned.VerifyDownload("course/video.mp4", expires, signature.Sign("", http.MethodGet, "course/video.mp4", strconv.FormatInt(expires, 10))))
+}
+
+func TestLocalUploadsOfSameFilenameKeepApart(t *testing.T) {
+
+	storage := storagerepo.ConstructLocalStorageRepository(t.TempDir(), "http://localhost/storage", "secret")
+
+	first, err := storage.UploadReader(strings.NewReader("first"), "lesson.mp4", "course/")
+	assert.NoError(t, err)
+	second, err := storage.UploadReader(strings.NewReader("second"), "lesson.mp4", "course/")
+	assert.NoError(t, err)
+
+	assert.True(t, first.Success && second.Success)
+	assert.NotEqual(t, first.Key, second.Key)
+	assert.True(t, strings.HasSuffix(first.Key, "-lesson.mp4"), first.Key)
+
+	//Removing one upload leaves the other in place
+	assert.NoError(t, storage.DeleteObject(&first.Key))
+
+	object, err := storage.GetObject(second.Key)
+	assert.NoError(t, err)
+	content, _ := ioutil.ReadAll(object)
+	_ = object.Close()
+	assert.Equal(t, "second", string(content))
+}
@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	uploadrepo "acourse-course-service/pkg/repositories/upload"
+	"acourse-course-service/pkg/services"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Course repository serving fixed courses & revisions, other methods aren't used by the reaper
+type reaperCourses struct {
+	contracts.CourseDatabaseRepository
+	courses   []models.Course
+	revisions []models.CourseRevision
+}
+
+func (r *reaperCourses) FetchAllWithDeleted(ctx context.Context, excludeFields []string) ([]models.Course, error) {
+	return r.courses, nil
+}
+
+func (r *reaperCourses) FetchAllRevisions(ctx context.Context) ([]models.CourseRevision, error) {
+	return r.revisions, nil
+}
+
+func TestOrphanReaperKeepsReferencedAndRecentObjects(t *testing.T) {
+
+	root := t.TempDir()
+	var storageRepository contracts.StorageRepository = storagerepo.ConstructLocalStorageRepository(root, "http://localhost/storage", "secret")
+	storageService := services.ConstructStorageService(&storageRepository)
+
+	deletedAt := time.Now()
+	var dbRepository contracts.CourseDatabaseRepository = &reaperCourses{
+		courses: []models.Course{
+			{Materials: []models.Material{{Key: "course/live.mp4", PlaylistKey: "course/hls/live/master.m3u8"}}},
+			{DeletedAt: &deletedAt, Materials: []models.Material{{Key: "trashed/course.mp4"}}},
+		},
+		revisions: []models.CourseRevision{
+			{Snapshot: &models.Course{Materials: []models.Material{{Key: "course/published.mp4"}}}},
+			{},
+		},
+	}
+
+	//Completed resumable upload which isn't attached to a material yet
+	uploadRepository := uploadrepo.ConstructDiskUploadRepository(t.TempDir())
+	assert.NoError(t, uploadRepository.Create(models.Upload{ID: strings.Repeat("a", 32), Completed: true, Key: "uploads/" + strings.Repeat("a", 32) + "/lecture.mp4"}))
+	assert.NoError(t, uploadRepository.Create(models.Upload{ID: strings.Repeat("b", 32)}))
+
+	old := time.Now().Add(-48 * time.Hour)
+
+	objects := []struct {
+		key      string
+		modified time.Time
+		orphan   bool
+	}{
+		{"course/live.mp4", old, false},
+		{"course/hls/live/720p/segment-001.ts", old, false},
+		{"trashed/course.mp4", old, false},
+		{"course/published.mp4", old, false},
+		{"course/replaced.mp4", old, true},
+		{"course/hls/replaced/master.m3u8", old, true},
+		{"course/just-uploaded.mp4", time.Now(), false},
+		{"uploads/" + strings.Repeat("a", 32) + "/lecture.mp4", old, false},
+		{"uploads/" + strings.Repeat("c", 32) + "/released.mp4", old, true},
+	}
+
+	for _, object := range objects {
+		_, err := storageRepository.PutObject(strings.NewReader("content"), object.key, "")
+		assert.NoError(t, err)
+		assert.NoError(t, os.Chtimes(filepath.Join(root, filepath.FromSlash(object.key)), object.modified, object.modified))
+	}
+
+	reaper := services.ConstructOrphanReaperService(&dbRepository, &uploadRepository, &storageService, 24*time.Hour)
+
+	//Dry run reports orphans without removing them
+	report, err := reaper.Reap(context.Background(), true)
+	assert.NoError(t, err)
+	assert.Equal(t, len(objects), report.Scanned)
+	assert.Equal(t, 5, report.Referenced)
+	assert.Len(t, report.Orphans, 3)
+	assert.Equal(t, 0, report.Deleted)
+
+	report, err = reaper.Reap(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, report.Deleted)
+
+	for _, object := range objects {
+		path := filepath.Join(root, filepath.FromSlash(object.key))
+		if object.orphan {
+			assert.NoFileExists(t, path, object.key)
+		} else {
+			assert.FileExists(t, path, object.key)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"acourse-course-service/pkg/http/controllers"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	"acourse-course-service/pkg/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrefixSignedPlaylistReferences(t *testing.T) {
+
+	gin.SetMode(gin.TestMode)
+
+	root := t.TempDir()
+	storage := storagerepo.ConstructLocalStorageRepository(root, "http://localhost/storage", "secret")
+
+	engine := gin.New()
+	controllers.SetupLocalStorageHandler(engine, "/storage", root, storage, true)
+
+	objects := map[string]string{
+		"course/hls/material/1/master.m3u8":          "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=2800000\n720p/index.m3u8\n",
+		"course/hls/material/1/720p/index.m3u8":      "#EXTM3U\n#EXTINF:6.0,\nsegment_0000.ts\n#EXT-X-ENDLIST\n",
+		"course/hls/material/1/720p/segment_0000.ts": "segment",
+		"course/intro.mp4":                           "video",
+		"course/hls/material/2/master.m3u8":          "#EXTM3U\n",
+	}
+	for key, content := range objects {
+		_, err := storage.PutObject(strings.NewReader(content), key, "")
+		assert.NoError(t, err)
+	}
+
+	signer, err := services.ConstructHmacUrlSigner("http://localhost/storage", "secret", time.Hour)
+	assert.NoError(t, err)
+
+	_, err = signer.SignPrefixUrl("course/hls/material/2/", "course/hls/material/1/master.m3u8")
+	assert.Error(t, err)
+
+	signed, err := signer.SignPrefixUrl("course/hls/material/1/", "course/hls/material/1/master.m3u8")
+	assert.NoError(t, err)
+
+	fetch := func(target *url.URL) (int, string) {
+		res := httptest.NewRecorder()
+		engine.ServeHTTP(res, httptest.NewRequest(http.MethodGet, target.RequestURI(), nil))
+		return res.Code, res.Body.String()
+	}
+
+	//Follow the master playlist down to a segment the way a player resolves relative references
+	current, _ := url.Parse(signed)
+	for _, expected := range []string{"720p/index.m3u8", "segment_0000.ts"} {
+		status, body := fetch(current)
+		assert.Equal(t, http.StatusOK, status, current.String())
+		assert.Contains(t, body, expected)
+
+		current = current.ResolveReference(&url.URL{Path: expected})
+	}
+
+	status, body := fetch(current)
+	assert.Equal(t, http.StatusOK, status, current.String())
+	assert.Equal(t, "segment", body)
+
+	//Objects outside of the signed prefix are refused
+	base, _ := url.Parse(signed)
+	for _, reference := range []string{"../2/master.m3u8", "../../../intro.mp4"} {
+		status, _ = fetch(base.ResolveReference(&url.URL{Path: reference}))
+		assert.Equal(t, http.StatusForbidden, status, reference)
+	}
+
+	token := strings.Split(base.Path, "/")
+	expires, _ := strconv.ParseInt(token[3], 10, 64)
+
+	//Keys have to be clean, otherwise they could climb out of a signed directory
+	assert.NoError(t, storage.VerifyPrefixDownload("course/hls/material/1/master.m3u8", expires, token[4]))
+	assert.Error(t, storage.VerifyPrefixDownload("course/hls/material/1/../2/master.m3u8", expires, token[4]))
+
+	//Signature doesn't carry over to another expiration
+	token[3] = strconv.FormatInt(expires+3600, 10)
+	status, _ = fetch(&url.URL{Path: strings.Join(token, "/")})
+	assert.Equal(t, http.StatusForbidden, status)
+}
+
+func TestCloudFrontPrefixSignedUrls(t *testing.T) {
+
+	signer := constructCloudFrontSigner(t)
+
+	signed, err := signer.SignPrefixUrl("course/hls/material/1/", "course/hls/material/1/master.m3u8")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://cdn.example.com/course/hls/material/1/master.m3u8?"))
+
+	signedUrl, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, signedUrl.Query().Get("Policy"))
+	assert.Empty(t, signedUrl.Query().Get("Expires"))
+}
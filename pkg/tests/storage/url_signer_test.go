@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"acourse-course-service/pkg/contracts"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	"acourse-course-service/pkg/services"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCdnUrlRewriting(t *testing.T) {
+
+	signer := services.ConstructCdnUrlSigner("https://cdn.example.com/")
+
+	tests := []struct {
+		key      string
+		expected string
+	}{
+		{"", ""},
+		{"course/video.mp4", "https://cdn.example.com/course/video.mp4"},
+		{"course/2022-08-12T10:00:00+07:00-my video.mp4", "https://cdn.example.com/course/2022-08-12T10:00:00+07:00-my%20video.mp4"},
+		{"course/100%.mp4", "https://cdn.example.com/course/100%25.mp4"},
+	}
+
+	for _, test := range tests {
+		signed, err := signer.SignUrl(test.key)
+		assert.NoError(t, err, test.key)
+		assert.Equal(t, test.expected, signed, test.key)
+	}
+}
+
+func TestHmacSignedUrlsAreVerifiedByLocalStorage(t *testing.T) {
+
+	storage := storagerepo.ConstructLocalStorageRepository(t.TempDir(), "http://localhost/storage", "secret")
+
+	_, err := services.ConstructHmacUrlSigner("http://localhost/storage", "", time.Hour)
+	assert.Error(t, err)
+
+	signer, err := services.ConstructHmacUrlSigner("http://localhost/storage", "secret", time.Hour)
+	assert.NoError(t, err)
+
+	key := "course/2022-08-12T10:00:00+07:00-my video.mp4"
+	signed, err := signer.SignUrl(key)
+	assert.NoError(t, err)
+
+	signedUrl, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "/storage/"+key, signedUrl.Path)
+
+	expires, err := strconv.ParseInt(signedUrl.Query().Get("expires"), 10, 64)
+	assert.NoError(t, err)
+
+	assert.NoError(t, storage.VerifyDownload(key, expires, signedUrl.Query().Get("signature")))
+	assert.Error(t, storage.VerifyDownload("course/other.mp4", expires, signedUrl.Query().Get("signature")))
+
+	other, _ := services.ConstructHmacUrlSigner("http://localhost/storage", "other", time.Hour)
+	otherSigned, _ := other.SignUrl(key)
+	otherUrl, _ := url.Parse(otherSigned)
+	assert.Error(t, storage.VerifyDownload(key, expires, otherUrl.Query().Get("signature")))
+}
+
+func constructCloudFrontSigner(t *testing.T) contracts.UrlSigner {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	keyPath := filepath.Join(t.TempDir(), "cloudfront.pem")
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	assert.NoError(t, ioutil.WriteFile(keyPath, keyPem, 0600))
+
+	_, err = services.ConstructCloudFrontUrlSigner("https://cdn.example.com", "", keyPath, time.Hour)
+	assert.Error(t, err)
+
+	signer, err := services.ConstructCloudFrontUrlSigner("https://cdn.example.com", "KEYPAIR", keyPath, time.Hour)
+	assert.NoError(t, err)
+
+	return signer
+}
+
+func TestCloudFrontSignedUrls(t *testing.T) {
+
+	signer := constructCloudFrontSigner(t)
+
+	signed, err := signer.SignUrl("course/my video.mp4")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://cdn.example.com/course/my%20video.mp4?"))
+
+	signedUrl, err := url.Parse(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, "KEYPAIR", signedUrl.Query().Get("Key-Pair-Id"))
+	assert.NotEmpty(t, signedUrl.Query().Get("Signature"))
+
+	expires, err := strconv.ParseInt(signedUrl.Query().Get("Expires"), 10, 64)
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), expires, 5)
+}
@@ -0,0 +1,296 @@
+package transcoding
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/models"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	"acourse-course-service/pkg/services"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	courseId   = primitive.NewObjectID()
+	materialId = primitive.NewObjectID()
+)
+
+// Course repository holding fields of a single material, updates are applied only while their match holds
+type transcodeCourses struct {
+	contracts.CourseDatabaseRepository
+	lock     sync.Mutex
+	material map[string]interface{}
+	updates  []map[string]interface{}
+}
+
+func (r *transcodeCourses) UpdateMaterialFields(ctx context.Context, course_id string, material_id string, match map[string]interface{}, fields map[string]interface{}) (bool, error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.updates = append(r.updates, fields)
+
+	for field, value := range match {
+		if r.material[field] != value {
+			return false, nil
+		}
+	}
+	for field, value := range fields {
+		r.material[field] = value
+	}
+
+	return true, nil
+}
+
+func (r *transcodeCourses) FetchAllWithDeleted(ctx context.Context, excludeFields []string) ([]models.Course, error) {
+	return []models.Course{{
+		ID:        courseId,
+		CourseID:  "course",
+		Materials: []models.Material{{MaterialID: materialId, Key: r.field("key"), Status: r.field("status")}},
+	}}, nil
+}
+
+func (r *transcodeCourses) field(name string) string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	value, _ := r.material[name].(string)
+	return value
+}
+
+// Whether the playlist was offered for attaching, the last step of a transcode
+func (r *transcodeCourses) attempted() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, fields := range r.updates {
+		if _, ok := fields["playlist_key"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Transcoder writing fixed renditions & previews, the source has to be the downloaded video
+type fakeTranscoder struct {
+	lock       sync.Mutex
+	transcoded int
+	err        error
+	onPreviews func()
+}
+
+func (f *fakeTranscoder) TranscodeHls(ctx context.Context, input string, outputDir string) error {
+
+	f.lock.Lock()
+	f.transcoded++
+	f.lock.Unlock()
+
+	content, err := ioutil.ReadFile(input)
+	if err != nil || string(content) != "video" {
+		return errors.New("Source video isn't downloaded")
+	}
+
+	if f.err != nil {
+		return f.err
+	}
+
+	return writeFiles(outputDir, "master.m3u8", "720p/index.m3u8", "720p/segment-000.ts")
+}
+
+func (f *fakeTranscoder) ExtractPreviews(ctx context.Context, input string, outputDir string) error {
+	if f.onPreviews != nil {
+		f.onPreviews()
+	}
+	return writeFiles(outputDir, "poster.jpg", "thumbnails.vtt", "thumbnails.jpg")
+}
+
+func (f *fakeTranscoder) count() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.transcoded
+}
+
+func writeFiles(dir string, names ...string) error {
+	for _, name := range names {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(target, []byte(name), 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestTranscodingClaimsAttachesAndCleansUp(t *testing.T) {
+
+	tests := []struct {
+		name         string
+		status       string
+		recover      bool
+		err          error
+		replace      bool
+		resultStatus string
+		hls          bool
+		previews     bool
+	}{
+		{name: "transcoded", status: models.MaterialStatusPending, resultStatus: models.MaterialStatusReady, hls: true, previews: true},
+		{name: "interrupted job recovered", status: models.MaterialStatusProcessing, recover: true, resultStatus: models.MaterialStatusReady, hls: true, previews: true},
+		{name: "transcoding failed", status: models.MaterialStatusPending, err: errors.New("ffmpeg exited"), resultStatus: models.MaterialStatusFailed, previews: true},
+		{name: "video replaced meanwhile", status: models.MaterialStatusPending, replace: true, resultStatus: models.MaterialStatusPending},
+	}
+
+	for _, test := range tests {
+
+		root := t.TempDir()
+		var storageRepository contracts.StorageRepository = storagerepo.ConstructLocalStorageRepository(root, "http://localhost/storage", "secret")
+		storageService := services.ConstructStorageService(&storageRepository)
+
+		_, err := storageService.PutObject(strings.NewReader("video"), "course/lesson.mp4", "video/mp4")
+		assert.NoError(t, err, test.name)
+
+		repository := &transcodeCourses{material: map[string]interface{}{"key": "course/lesson.mp4", "status": test.status}}
+		transcoder := &fakeTranscoder{err: test.err}
+		if test.replace {
+			transcoder.onPreviews = func() {
+				repository.lock.Lock()
+				repository.material["key"], repository.material["status"] = "course/replaced.mp4", models.MaterialStatusPending
+				repository.lock.Unlock()
+			}
+		}
+
+		var dbRepository contracts.CourseDatabaseRepository = repository
+		var transcoderContract contracts.Transcoder = transcoder
+		transcodingService := services.ConstructTranscodingService(&dbRepository, &storageService, &transcoderContract, t.TempDir(), 4)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		transcodingService.Start(ctx, 1)
+
+		if test.recover {
+			assert.NoError(t, transcodingService.Recover(ctx), test.name)
+		} else {
+			transcodingService.Enqueue(models.TranscodeJob{CourseID: courseId.Hex(), CoursePrefix: "course/", MaterialID: materialId.Hex(), Key: "course/lesson.mp4"})
+		}
+
+		assert.Eventually(t, func() bool {
+			return repository.attempted() || repository.field("status") == models.MaterialStatusFailed
+		}, 5*time.Second, 10*time.Millisecond, test.name)
+
+		//Objects which aren't attached are removed right after the attempt
+		hlsPrefix := "course/hls/" + materialId.Hex() + "/"
+		previewsPrefix := "course/previews/" + materialId.Hex() + "/"
+
+		hlsObjects, previewObjects := 0, 0
+		if test.hls {
+			hlsObjects = 3
+		}
+		if test.previews {
+			previewObjects = 3
+		}
+
+		assert.Eventually(t, func() bool {
+			hls, _ := storageService.ListObjects(hlsPrefix)
+			previews, _ := storageService.ListObjects(previewsPrefix)
+			return len(hls) == hlsObjects && len(previews) == previewObjects
+		}, 5*time.Second, 10*time.Millisecond, test.name)
+
+		cancel()
+
+		assert.Equal(t, test.resultStatus, repository.field("status"), test.name)
+		if test.err != nil {
+			assert.Equal(t, test.err.Error(), repository.field("status_error"), test.name)
+		}
+		assert.Equal(t, 1, transcoder.count(), test.name)
+
+		playlistKey, posterKey := repository.field("playlist_key"), repository.field("poster_key")
+
+		if test.hls {
+			assert.True(t, strings.HasPrefix(playlistKey, hlsPrefix) && strings.HasSuffix(playlistKey, "/master.m3u8"), test.name)
+			assert.Empty(t, repository.field("status_error"), test.name)
+		} else {
+			assert.Empty(t, playlistKey, test.name)
+		}
+
+		if test.previews {
+			assert.True(t, strings.HasPrefix(posterKey, previewsPrefix) && strings.HasSuffix(posterKey, "/poster.jpg"), test.name)
+			assert.Equal(t, path.Dir(posterKey), path.Dir(repository.field("thumbnails_key")), test.name)
+		} else {
+			assert.Empty(t, posterKey, test.name)
+		}
+	}
+}
+
+func TestTranscodingSkipsJobsNoLongerPending(t *testing.T) {
+
+	root := t.TempDir()
+	var storageRepository contracts.StorageRepository = storagerepo.ConstructLocalStorageRepository(root, "http://localhost/storage", "secret")
+	storageService := services.ConstructStorageService(&storageRepository)
+
+	_, err := storageService.PutObject(strings.NewReader("video"), "course/lesson.mp4", "video/mp4")
+	assert.NoError(t, err)
+
+	repository := &transcodeCourses{material: map[string]interface{}{"key": "course/lesson.mp4", "status": models.MaterialStatusPending}}
+	transcoder := &fakeTranscoder{}
+
+	var dbRepository contracts.CourseDatabaseRepository = repository
+	var transcoderContract contracts.Transcoder = transcoder
+	transcodingService := services.ConstructTranscodingService(&dbRepository, &storageService, &transcoderContract, t.TempDir(), 4)
+
+	//Job of the replaced video & the same job queued twice, a single worker takes them in order
+	transcodingService.Enqueue(models.TranscodeJob{CourseID: courseId.Hex(), CoursePrefix: "course/", MaterialID: materialId.Hex(), Key: "course/replaced.mp4"})
+	transcodingService.Enqueue(models.TranscodeJob{CourseID: courseId.Hex(), CoursePrefix: "course/", MaterialID: materialId.Hex(), Key: "course/lesson.mp4"})
+	transcodingService.Enqueue(models.TranscodeJob{CourseID: courseId.Hex(), CoursePrefix: "course/", MaterialID: materialId.Hex(), Key: "course/lesson.mp4"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	transcodingService.Start(ctx, 1)
+
+	assert.Eventually(t, func() bool {
+		repository.lock.Lock()
+		defer repository.lock.Unlock()
+		return len(repository.updates) == 5
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, models.MaterialStatusReady, repository.field("status"))
+	assert.Equal(t, 1, transcoder.count())
+}
+
+func TestTranscodingQueueLeavesOverflowPending(t *testing.T) {
+
+	repository := &transcodeCourses{material: map[string]interface{}{"key": "course/lesson.mp4", "status": models.MaterialStatusPending}}
+	transcoder := &fakeTranscoder{}
+
+	var dbRepository contracts.CourseDatabaseRepository = repository
+	var storageService contracts.StorageService
+	var transcoderContract contracts.Transcoder = transcoder
+	transcodingService := services.ConstructTranscodingService(&dbRepository, &storageService, &transcoderContract, t.TempDir(), 1)
+
+	//Queue isn't drained, jobs past its size are dropped without blocking
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			transcodingService.Enqueue(models.TranscodeJob{CourseID: courseId.Hex(), MaterialID: materialId.Hex(), Key: "course/lesson.mp4"})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Enqueue blocked on a full queue")
+	}
+
+	assert.Equal(t, models.MaterialStatusPending, repository.field("status"))
+}
@@ -0,0 +1,175 @@
+package uploads
+
+import (
+	"acourse-course-service/pkg/contracts"
+	"acourse-course-service/pkg/http/middleware"
+	"acourse-course-service/pkg/models"
+	storagerepo "acourse-course-service/pkg/repositories/storage"
+	uploadrepo "acourse-course-service/pkg/repositories/upload"
+	"acourse-course-service/pkg/services"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func constructUploadService(t *testing.T, maxSize int64) contracts.ResumableUploadService {
+
+	dir := t.TempDir()
+
+	uploadRepository := uploadrepo.ConstructDiskUploadRepository(filepath.Join(dir, "uploads"))
+	var storageRepository contracts.StorageRepository = storagerepo.ConstructLocalStorageRepository(filepath.Join(dir, "storage"), "http://localhost/storage", "secret")
+	storageService := services.ConstructStorageService(&storageRepository)
+	mediaInfoService := services.ConstructGoMediaProbeService()
+
+	return services.ConstructResumableUploadService(&uploadRepository, &storageService, &mediaInfoService, models.MediaPolicy{}, maxSize)
+}
+
+func userContext(userId string) context.Context {
+	return context.WithValue(context.Background(), middleware.AuthorizationKey, &middleware.Authorization{UserID: userId})
+}
+
+func TestResumableUploadOffsets(t *testing.T) {
+
+	uploadService := constructUploadService(t, 100)
+	ctx := userContext("1")
+
+	upload, err := uploadService.Create(ctx, 10, map[string]string{"filename": "notes.pdf", "kind": models.MaterialKindDocument})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		offset    int64
+		chunk     string
+		err       error
+		expected  int64
+		completed bool
+	}{
+		{"first chunk", 0, "01234", nil, 5, false},
+		{"offset behind", 3, "34567", models.ErrUploadOffsetMismatch, 5, false},
+		{"offset ahead", 8, "89", models.ErrUploadOffsetMismatch, 5, false},
+		{"chunk past length is cut", 5, "56789-extra", nil, 10, true},
+		{"completed upload", 10, "", nil, 10, true},
+	}
+
+	for _, test := range tests {
+		upload, err = uploadService.WriteChunk(ctx, upload.ID, test.offset, strings.NewReader(test.chunk))
+		assert.Equal(t, test.err, err, test.name)
+		assert.Equal(t, test.expected, upload.Offset, test.name)
+		assert.Equal(t, test.completed, upload.Completed, test.name)
+	}
+
+	assert.True(t, strings.HasPrefix(upload.Key, "uploads/"+upload.ID+"/"))
+}
+
+func TestResumableUploadLimits(t *testing.T) {
+
+	uploadService := constructUploadService(t, 10)
+	ctx := userContext("1")
+
+	_, err := uploadService.Create(ctx, 11, map[string]string{})
+	assert.Equal(t, models.ErrUploadTooLarge, err)
+
+	empty, err := uploadService.Create(ctx, 0, map[string]string{"kind": models.MaterialKindDocument})
+	assert.NoError(t, err)
+	assert.True(t, empty.Completed)
+
+	upload, err := uploadService.Create(ctx, 10, map[string]string{})
+	assert.NoError(t, err)
+
+	_, err = uploadService.WriteChunk(userContext("2"), upload.ID, 0, strings.NewReader("0123456789"))
+	assert.Equal(t, models.ErrUploadForbidden, err)
+
+	_, err = uploadService.WriteChunk(ctx, "../"+upload.ID, 0, strings.NewReader("0123456789"))
+	assert.Equal(t, models.ErrUploadNotFound, err)
+
+	_, err = uploadService.Find(ctx, strings.Repeat("0", 32))
+	assert.Equal(t, models.ErrUploadNotFound, err)
+}
+
+func TestResumableUploadConcurrentChunks(t *testing.T) {
+
+	uploadService := constructUploadService(t, 100)
+	ctx := userContext("1")
+
+	upload, err := uploadService.Create(ctx, 10, map[string]string{})
+	assert.NoError(t, err)
+
+	//Chunks sent for the same offset are serialized, only one of them is appended
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = uploadService.WriteChunk(ctx, upload.ID, 0, strings.NewReader("01234"))
+		}(i)
+	}
+	wg.Wait()
+
+	appended := 0
+	for _, err := range errs {
+		if err == nil {
+			appended++
+		} else {
+			assert.Equal(t, models.ErrUploadOffsetMismatch, err)
+		}
+	}
+	assert.Equal(t, 1, appended)
+
+	upload, err = uploadService.Find(ctx, upload.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+}
+
+func TestResumableUploadClaims(t *testing.T) {
+
+	uploadService := constructUploadService(t, 100)
+	ctx := userContext("1")
+
+	upload, err := uploadService.Create(ctx, 5, map[string]string{"kind": models.MaterialKindDocument})
+	assert.NoError(t, err)
+
+	_, err = uploadService.Resolve(ctx, upload.ID)
+	assert.Equal(t, models.ErrUploadIncomplete, err)
+
+	_, err = uploadService.WriteChunk(ctx, upload.ID, 0, strings.NewReader("01234"))
+	assert.NoError(t, err)
+
+	//Concurrent requests attaching the same upload, only one of them claims it
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = uploadService.Resolve(ctx, upload.ID)
+		}(i)
+	}
+	wg.Wait()
+
+	claimed := 0
+	for _, err := range errs {
+		if err == nil {
+			claimed++
+		} else {
+			assert.Equal(t, models.ErrUploadClaimed, err)
+		}
+	}
+	assert.Equal(t, 1, claimed)
+
+	assert.Equal(t, models.ErrUploadClaimed, uploadService.Terminate(ctx, upload.ID))
+
+	//Failed attach gives the upload back
+	assert.NoError(t, uploadService.Unclaim(upload.ID))
+	_, err = uploadService.Resolve(ctx, upload.ID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, uploadService.Release(upload.ID))
+	_, err = uploadService.Resolve(ctx, upload.ID)
+	assert.Equal(t, models.ErrUploadNotFound, err)
+}